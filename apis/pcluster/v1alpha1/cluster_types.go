@@ -27,8 +27,163 @@ import (
 
 // ClusterParameters are the configurable fields of a Cluster.
 type ClusterParameters struct {
-	Region               string `json:"region"`
-	ClusterConfiguration string `json:"clusterConfiguration"`
+	// Region of the cluster. Defaults to the ProviderConfig's default
+	// region.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// ClusterConfiguration is the pcluster configuration of the cluster.
+	// +optional
+	ClusterConfiguration string `json:"clusterConfiguration,omitempty"`
+
+	// ClusterConfigurationRef references a ConfigMap key containing the
+	// pcluster configuration of the cluster. It is used when
+	// ClusterConfiguration is empty.
+	// +optional
+	ClusterConfigurationRef *ConfigMapKeySelector `json:"clusterConfigurationRef,omitempty"`
+
+	// ClusterConfigurationSecretRef references a Secret key containing the
+	// pcluster configuration of the cluster, for configurations that include
+	// sensitive values. Only one of ClusterConfiguration,
+	// ClusterConfigurationRef, ClusterConfigurationSecretRef, and
+	// ClusterConfigurationURL may be set.
+	// +optional
+	ClusterConfigurationSecretRef *xpv1.SecretKeySelector `json:"clusterConfigurationSecretRef,omitempty"`
+
+	// ClusterConfigurationURL is an https:// or s3:// URL of the pcluster
+	// configuration of the cluster, which is passed to pcluster to download
+	// itself, so large configurations needn't be stored in the Cluster. As
+	// the provider never sees the configuration, it can't be combined with
+	// ConfigValues, HeadNodeSubnet, ComputeSubnets, or RetainSharedStorage.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(https|s3)://`
+	ClusterConfigurationURL string `json:"clusterConfigurationUrl,omitempty"`
+
+	// ClusterConfigurationFileName is the name of the file the cluster
+	// configuration is written to for pcluster, which pcluster's messages
+	// refer to. Defaults to cluster-config.yaml.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_][a-zA-Z0-9._-]*$`
+	ClusterConfigurationFileName string `json:"clusterConfigurationFileName,omitempty"`
+
+	// ConfigValues are the values the cluster configuration is rendered with,
+	// as a Go template, e.g. {{ .subnet }}. The configuration is only treated
+	// as a template when ConfigValues or ConfigValuesSecretRef is set, and
+	// every value it refers to must be set.
+	// +optional
+	ConfigValues map[string]string `json:"configValues,omitempty"`
+
+	// ConfigValuesSecretRef references a Secret whose keys are also values
+	// the cluster configuration is rendered with, for sensitive values. A key
+	// may not also be set in ConfigValues.
+	// +optional
+	ConfigValuesSecretRef *xpv1.SecretReference `json:"configValuesSecretRef,omitempty"`
+
+	// HeadNodeSubnet overrides the subnet of the head node in the cluster
+	// configuration, so that one configuration can be deployed to different
+	// subnets.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^subnet-([0-9a-f]{8}|[0-9a-f]{17})$`
+	HeadNodeSubnet string `json:"headNodeSubnet,omitempty"`
+
+	// ComputeSubnets override the subnets of every queue in the cluster
+	// configuration.
+	// +optional
+	ComputeSubnets []string `json:"computeSubnets,omitempty"`
+
+	// RetainSharedStorage keeps the shared storage pcluster creates for the
+	// cluster, such as EBS volumes and EFS and FSx for Lustre file systems,
+	// when the cluster is deleted. The DeletionPolicy of each that doesn't
+	// set its own is set to Retain in the cluster configuration, so it takes
+	// effect when the cluster is created or updated; delete-cluster itself
+	// can't retain storage. Storage pcluster didn't create is never deleted.
+	// +optional
+	RetainSharedStorage bool `json:"retainSharedStorage,omitempty"`
+
+	// StrictPreflight makes the cluster fail to be created, rather than only
+	// be warned about, when the checks the provider makes before creating it
+	// find a problem, such as an instance type that no official image of the
+	// configured OS can run on in the cluster's region.
+	// +optional
+	StrictPreflight bool `json:"strictPreflight,omitempty"`
+
+	// Tags to apply to the cluster, in addition to any in the cluster
+	// configuration.
+	// +optional
+	Tags []Tag `json:"tags,omitempty"`
+
+	// SuppressValidators disables the given configuration validators, e.g.
+	// type:InstanceTypeBaseAMICompatibleValidator, or ALL.
+	// +optional
+	SuppressValidators []string `json:"suppressValidators,omitempty"`
+
+	// ValidationFailureLevel is the lowest level of configuration validation
+	// message that fails a create or update, e.g. WARNING to be strict. It is
+	// passed to pcluster's --validation-failure-level, so pcluster's default
+	// applies when unset.
+	// +optional
+	// +kubebuilder:validation:Enum=INFO;WARNING;ERROR
+	ValidationFailureLevel string `json:"validationFailureLevel,omitempty"`
+
+	// RollbackOnFailure controls whether the cluster's CloudFormation stack
+	// is rolled back if creation fails. Setting it to false preserves the
+	// failed resources for debugging. Defaults to pcluster's behavior.
+	// +optional
+	RollbackOnFailure *bool `json:"rollbackOnFailure,omitempty"`
+
+	// ForceUpdate makes pcluster apply updates it would otherwise reject,
+	// such as changes that normally require the compute fleet to be stopped.
+	// Forced updates may replace or interrupt running compute nodes and jobs,
+	// so use with care. Defaults to pcluster's behavior.
+	// +optional
+	ForceUpdate *bool `json:"forceUpdate,omitempty"`
+
+	// AllowDisruptiveChanges lists the disruptive changes updates may apply,
+	// by parameter, e.g. HeadNode.Networking.SubnetId. A parameter also allows
+	// changes to any parameter within it, and * allows every change.
+	// Disruptive changes replace the head node, require the compute fleet to
+	// be stopped, or may lose data, so updates that include any that aren't
+	// allowed are refused.
+	// +optional
+	AllowDisruptiveChanges []string `json:"allowDisruptiveChanges,omitempty"`
+
+	// ComputeFleetState is the desired state of the compute fleet. The fleet
+	// is started or stopped when its observed status differs, once any start
+	// or stop in progress has finished. The fleet is left as is when unset.
+	// +optional
+	// +kubebuilder:validation:Enum=RUNNING;STOPPED
+	ComputeFleetState string `json:"computeFleetState,omitempty"`
+
+	// ExtraArgs are appended to the pcluster create-cluster and
+	// update-cluster commands, for options the provider does not support
+	// yet, e.g. --validation-failure-level WARNING. Options the provider sets
+	// itself, such as --cluster-name, are rejected.
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// PollIntervalOverride is how often the cluster is checked for drift,
+	// overriding the provider's poll interval. It must be positive.
+	// +optional
+	PollIntervalOverride *metav1.Duration `json:"pollIntervalOverride,omitempty"`
+}
+
+// A ConfigMapKeySelector is a reference to a ConfigMap key in an arbitrary
+// namespace.
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap.
+	Namespace string `json:"namespace"`
+
+	// The key to select.
+	Key string `json:"key"`
+}
+
+// A Tag is a key/value pair applied to a cluster.
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
 
 // ClusterObservation are the observable fields of a Cluster.
@@ -36,18 +191,305 @@ type ClusterObservation struct {
 	ClusterName            string        `json:"clusterName,omitempty"`
 	CloudformationStackArn string        `json:"cloudformationStackArn,omitempty"`
 	ClusterStatus          string        `json:"clusterStatus,omitempty"`
+	ComputeFleetStatus     string        `json:"computeFleetStatus,omitempty"`
+	CreationTime           string        `json:"creationTime,omitempty"`
 	LastUpdatedTime        string        `json:"lastUpdatedTime,omitempty"`
 	Scheduler              SchedulerType `json:"scheduler,omitempty"`
+	HeadNode               HeadNode      `json:"headNode,omitempty"`
+	LoginNodes             []LoginNodes  `json:"loginNodes,omitempty"`
+
+	// CloudformationStackStatus is the status of the cluster's CloudFormation
+	// stack. It may differ from ClusterStatus, e.g. UPDATE_ROLLBACK_COMPLETE
+	// after an update failed and was rolled back.
+	CloudformationStackStatus string `json:"cloudformationStackStatus,omitempty"`
+
+	// Version is the pcluster version that created or last updated the
+	// cluster.
+	Version string `json:"version,omitempty"`
+
+	// VersionDrift is the cluster's version and the differing version of the
+	// provider's pcluster that were last reported, e.g. "3.4.0 to 3.7.0". It
+	// is empty while they match.
+	VersionDrift string `json:"versionDrift,omitempty"`
+
+	// ComputeFleetLastUpdatedTime is when the compute fleet status last
+	// changed. It is only reported once the cluster has been created.
+	ComputeFleetLastUpdatedTime string `json:"computeFleetLastUpdatedTime,omitempty"`
+
+	// ComputeInstanceCount is the number of the cluster's compute instances,
+	// in any state. It is zero while the compute fleet is stopped.
+	ComputeInstanceCount int `json:"computeInstanceCount,omitempty"`
+
+	// InstanceCounts counts the cluster's instances by node type and state.
+	InstanceCounts []InstanceCount `json:"instanceCounts,omitempty"`
+
+	// ConfigurationURL is where the configuration pcluster is running can be
+	// downloaded from. It is a presigned S3 URL that expires shortly after it
+	// is observed, so fetch it promptly or wait for it to be refreshed.
+	ConfigurationURL string `json:"configurationUrl,omitempty"`
+
+	// ImportedConfiguration is the configuration of an imported cluster, as
+	// reported by pcluster when the cluster was first observed.
+	ImportedConfiguration string `json:"importedConfiguration,omitempty"`
+
+	// ConfigHash is the SHA-256 hash of the configuration the provider last
+	// rendered for the cluster, including any overrides from its spec, or of
+	// its ClusterConfigurationURL. It changes only when the configuration
+	// given to pcluster does.
+	ConfigHash string `json:"configHash,omitempty"`
+
+	// UpdateChangeSet lists the changes an update would apply to the cluster.
+	// It is empty when the cluster is up to date.
+	UpdateChangeSet []Change `json:"updateChangeSet,omitempty"`
+
+	// PendingChanges previews the changes the provider will apply to bring
+	// the cluster in line with its spec, like a plan: each changed parameter
+	// with its current and requested values. It is refreshed each time the
+	// cluster is observed to be out of date, and cleared once it is up to
+	// date.
+	PendingChanges []Change `json:"pendingChanges,omitempty"`
+
+	// OutdatedTags lists the keys of the tags the cluster lacks, or has a
+	// different value for, when its configuration is otherwise up to date.
+	// Such tags are updated without validating the configuration again.
+	OutdatedTags []string `json:"outdatedTags,omitempty"`
+
+	// LogExport is the result of the most recent export of the cluster's
+	// logs.
+	LogExport *LogExport `json:"logExport,omitempty"`
+
+	// LogExportHandled is the bucket of the awspcluster.crossplane.io/export-logs
+	// annotation the most recent export was started for. Logs are exported
+	// again once the annotation names another bucket, or is removed and
+	// added again.
+	LogExportHandled string `json:"logExportHandled,omitempty"`
+
+	// LogEvents are the most recently fetched events of one of the cluster's
+	// log streams.
+	LogEvents *LogEvents `json:"logEvents,omitempty"`
+
+	// LogEventsHandled is the log stream of the
+	// awspcluster.crossplane.io/fetch-log-events annotation, followed by the
+	// time of the awspcluster.crossplane.io/fetch-log-events-since annotation
+	// if set, the most recent events were fetched for. Events are fetched
+	// again once either annotation changes, or is removed and added again.
+	LogEventsHandled string `json:"logEventsHandled,omitempty"`
+
+	// ForceReconcileHandled is the value of the
+	// awspcluster.crossplane.io/force-reconcile annotation when the cluster
+	// was last updated because of it.
+	ForceReconcileHandled string `json:"forceReconcileHandled,omitempty"`
+
+	// Progress estimates how far a create or update that is in progress has
+	// got, as the number of the cluster's CloudFormation resources that are
+	// complete, e.g. 12/18 resources. Only resources CloudFormation has
+	// started on are counted, so the total grows as the operation progresses.
+	Progress string `json:"progress,omitempty"`
+
+	// LastCommandFailure is the most recent failure of a pcluster command
+	// that creates or updates the cluster. It is cleared when such a command
+	// succeeds.
+	LastCommandFailure *CommandFailure `json:"lastCommandFailure,omitempty"`
+
+	// LastOperation is the most recent pcluster command that created,
+	// updated, or deleted the cluster, recording the last change made to it.
+	LastOperation *Operation `json:"lastOperation,omitempty"`
+
+	// Validation is the result of the most recent validation of a Cluster
+	// annotated awspcluster.crossplane.io/validate-only.
+	Validation *Validation `json:"validation,omitempty"`
+
+	// FailureReason is the reason given by the most recent CloudFormation
+	// stack event that explains why the cluster failed. It is only set while
+	// the cluster is in a failed state.
+	FailureReason string `json:"failureReason,omitempty"`
 }
 
+// An Operation is a pcluster command that changed the cluster. pcluster
+// doesn't report an ID for the requests it makes.
+type Operation struct {
+	// Command is the command, e.g. update-cluster.
+	Command string `json:"command"`
+
+	// Changes is the number of changes update-cluster applied.
+	// +optional
+	Changes int `json:"changes,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the command ran
+	// with.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	Time metav1.Time `json:"time"`
+}
+
+// A CommandFailure is the failure of a pcluster command.
+type CommandFailure struct {
+	Command string `json:"command"`
+
+	// ExitCode is the exit code of the command, or -1 if it did not exit,
+	// e.g. because it timed out.
+	ExitCode int `json:"exitCode"`
+
+	// Retryable is false if the command would fail again unless the
+	// cluster's spec or configuration changes, e.g. because the configuration
+	// failed validation. Such commands are not retried for a while unless the
+	// spec changes.
+	Retryable bool `json:"retryable"`
+
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the command failed
+	// with.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	Time metav1.Time `json:"time"`
+}
+
+// A Validation is the result of validating a cluster's configuration with
+// pcluster, without creating the cluster.
+type Validation struct {
+	// Valid is false if any validator reported an error, or a message at the
+	// Cluster's validation failure level.
+	Valid bool `json:"valid"`
+
+	Errors   []ValidationResult `json:"errors,omitempty"`
+	Warnings []ValidationResult `json:"warnings,omitempty"`
+
+	// ObservedGeneration is the generation of the spec that was validated.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	Time metav1.Time `json:"time"`
+}
+
+// A ValidationResult is a problem a pcluster validator found with a cluster's
+// configuration.
+type ValidationResult struct {
+	// Validator that found the problem, e.g. InstanceTypeValidator.
+	Validator string `json:"validator"`
+	Message   string `json:"message"`
+}
+
+// An InstanceCount is the number of a cluster's instances of a node type that
+// are in a state.
+type InstanceCount struct {
+	// NodeType is HeadNode, ComputeNode, or LoginNode.
+	NodeType string `json:"nodeType"`
+	State    string `json:"state"`
+	Count    int    `json:"count"`
+}
+
+// LogExport is the result of exporting a cluster's logs to S3.
+type LogExport struct {
+	Bucket string `json:"bucket"`
+
+	// InProgress is true while the logs are being exported, which may take
+	// several minutes.
+	InProgress bool `json:"inProgress,omitempty"`
+
+	URL     string `json:"url,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	// Time is when the export started, or finished once it has.
+	Time metav1.Time `json:"time"`
+}
+
+// LogEvents are the most recent events of a cluster's log stream. The events
+// are stored in a ConfigMap, as they are too large for the status.
+type LogEvents struct {
+	LogStreamName string `json:"logStreamName"`
+
+	// ConfigMapRef references the ConfigMap key the events are stored in.
+	ConfigMapRef *ConfigMapKeySelector `json:"configMapRef,omitempty"`
+
+	// Count is the number of events stored.
+	Count int `json:"count,omitempty"`
+
+	// Truncated is true if earlier events, or the ends of long events, were
+	// dropped.
+	Truncated bool `json:"truncated,omitempty"`
+
+	Message string      `json:"message,omitempty"`
+	Time    metav1.Time `json:"time"`
+}
+
+// A Change is a difference between the observed and desired configuration of
+// a cluster.
+type Change struct {
+	Parameter      string `json:"parameter"`
+	CurrentValue   string `json:"currentValue,omitempty"`
+	RequestedValue string `json:"requestedValue,omitempty"`
+}
+
+// SchedulerType is the observed state of a cluster's scheduler.
 type SchedulerType struct {
 	SchedulerType string `json:"type,omitempty"`
+
+	// Name and Version of the scheduler, as reported by pcluster.
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// Queues are the cluster's Slurm partitions or AWS Batch job queues, as
+	// configured.
+	Queues []SchedulerQueue `json:"queues,omitempty"`
+}
+
+// A SchedulerQueue is a Slurm partition or an AWS Batch job queue.
+type SchedulerQueue struct {
+	Name string `json:"name"`
+
+	// ComputeResources are the queue's compute resources. Each is an AWS
+	// Batch compute environment for AWS Batch queues.
+	ComputeResources []string `json:"computeResources,omitempty"`
+}
+
+// HeadNode is the observed state of a cluster's head node.
+type HeadNode struct {
+	InstanceID       string `json:"instanceId,omitempty"`
+	InstanceType     string `json:"instanceType,omitempty"`
+	State            string `json:"state,omitempty"`
+	PublicIPAddress  string `json:"publicIpAddress,omitempty"`
+	PrivateIPAddress string `json:"privateIpAddress,omitempty"`
+	LaunchTime       string `json:"launchTime,omitempty"`
+
+	// InstanceProfile and InstanceRole are the ARNs of the IAM instance
+	// profile or role of the head node, from HeadNode/Iam in the cluster's
+	// configuration. Both are empty when pcluster creates the head node's
+	// role itself.
+	InstanceProfile string `json:"instanceProfile,omitempty"`
+	InstanceRole    string `json:"instanceRole,omitempty"`
+}
+
+// LoginNodes is the observed state of a pool of login nodes.
+type LoginNodes struct {
+	PoolName string `json:"poolName,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Address  string `json:"address,omitempty"`
 }
 
+// A ManagementPolicy determines what the provider may do to a cluster.
+type ManagementPolicy string
+
+// Management policies.
+const (
+	// ManagementFullControl lets the provider create, update, and delete the
+	// cluster.
+	ManagementFullControl ManagementPolicy = "FullControl"
+
+	// ManagementObserveOnly lets the provider only observe the cluster. The
+	// cluster must already exist, and is never changed or deleted.
+	ManagementObserveOnly ManagementPolicy = "ObserveOnly"
+)
+
 // A ClusterSpec defines the desired state of a Cluster.
 type ClusterSpec struct {
 	xpv1.ResourceSpec `json:",inline"`
 	ForProvider       ClusterParameters `json:"forProvider"`
+
+	// ManagementPolicy determines what the provider may do to the cluster.
+	// +optional
+	// +kubebuilder:default=FullControl
+	// +kubebuilder:validation:Enum=FullControl;ObserveOnly
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
 }
 
 // A ClusterStatus represents the observed state of a Cluster.
@@ -62,6 +504,8 @@ type ClusterStatus struct {
 // +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
 // +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
 // +kubebuilder:printcolumn:name="CFSTATUS",type="string",JSONPath=".status.atProvider.clusterStatus"
+// +kubebuilder:printcolumn:name="STACKSTATUS",type="string",JSONPath=".status.atProvider.cloudformationStackStatus"
+// +kubebuilder:printcolumn:name="FLEETSTATUS",type="string",JSONPath=".status.atProvider.computeFleetStatus"
 // +kubebuilder:printcolumn:name="EXTERNAL-NAME",type="string",JSONPath=".metadata.annotations.crossplane\\.io/external-name"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:subresource:status
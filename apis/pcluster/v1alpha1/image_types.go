@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// ImageParameters are the configurable fields of an Image. Images cannot be
+// changed once built.
+type ImageParameters struct {
+	Region string `json:"region"`
+
+	// ImageConfiguration is the pcluster build-image configuration of the
+	// image.
+	ImageConfiguration string `json:"imageConfiguration"`
+}
+
+// ImageObservation are the observable fields of an Image.
+type ImageObservation struct {
+	ImageID                string `json:"imageId,omitempty"`
+	ImageBuildStatus       string `json:"imageBuildStatus,omitempty"`
+	CloudformationStackArn string `json:"cloudformationStackArn,omitempty"`
+
+	// AmiID is the ID of the AMI built for the image. It is only set once
+	// the build has completed.
+	AmiID string `json:"amiId,omitempty"`
+}
+
+// An ImageSpec defines the desired state of an Image.
+type ImageSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       ImageParameters `json:"forProvider"`
+}
+
+// An ImageStatus represents the observed state of an Image.
+type ImageStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          ImageObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// An Image is a custom AMI built by pcluster for use by clusters.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="BUILDSTATUS",type="string",JSONPath=".status.atProvider.imageBuildStatus"
+// +kubebuilder:printcolumn:name="AMI",type="string",JSONPath=".status.atProvider.amiId"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,awspcluster}
+type Image struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageSpec   `json:"spec"`
+	Status ImageStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ImageList contains a list of Image
+type ImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Image `json:"items"`
+}
+
+// Image type metadata.
+var (
+	ImageKind             = reflect.TypeOf(Image{}).Name()
+	ImageGroupKind        = schema.GroupKind{Group: Group, Kind: ImageKind}.String()
+	ImageKindAPIVersion   = ImageKind + "." + SchemeGroupVersion.String()
+	ImageGroupVersionKind = SchemeGroupVersion.WithKind(ImageKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Image{}, &ImageList{})
+}
@@ -29,15 +29,146 @@ import (
 type ProviderConfigSpec struct {
 	// Credentials required to authenticate to this provider.
 	Credentials ProviderCredentials `json:"credentials"`
+
+	// PclusterBinaryPath is the path to the pcluster executable to use. When
+	// unset pcluster is looked up on the PATH, which includes the virtual
+	// environment named by the PYTHON_VENV_PATH environment variable.
+	// +optional
+	PclusterBinaryPath string `json:"pclusterBinaryPath,omitempty"`
+
+	// CommandTimeout is the maximum time a single pcluster command may run
+	// before it is killed. Commands are only bound by the reconcile timeout
+	// when unset.
+	// +optional
+	CommandTimeout *metav1.Duration `json:"commandTimeout,omitempty"`
+
+	// MaxRetries is how many times a pcluster command that fails because AWS
+	// throttled it is retried. Defaults to 3.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxRetries *int `json:"maxRetries,omitempty"`
+
+	// RetryBaseDelay is how long to wait before retrying a throttled pcluster
+	// command. The delay doubles with each retry. Defaults to 1s.
+	// +optional
+	RetryBaseDelay *metav1.Duration `json:"retryBaseDelay,omitempty"`
+
+	// WaitForCompletion makes creating, updating, and deleting a cluster wait
+	// for the operation to finish, polling describe-cluster and emitting an
+	// event as the cluster's status changes, instead of returning once it has
+	// started. The wait is bound by CommandTimeout when set, and always by the
+	// reconcile timeout, which is 1 minute including observing the cluster,
+	// so it is capped at about 1 minute. Operations usually take longer; the
+	// operation carries on if the wait gives up.
+	// +optional
+	WaitForCompletion bool `json:"waitForCompletion,omitempty"`
+
+	// InProgressPollInterval is how often clusters are checked while they
+	// are being created, updated, or deleted, so their status reflects the
+	// operation's progress sooner. It's only used if it's shorter than the
+	// cluster's usual poll interval. Defaults to 30s.
+	// +optional
+	InProgressPollInterval *metav1.Duration `json:"inProgressPollInterval,omitempty"`
+
+	// WorkingDir is the directory the temporary files pcluster needs, such
+	// as configuration files, are created in. It must exist and be writable.
+	// Defaults to the system's temporary directory, usually /tmp.
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// CLILogLevel is the log level of pcluster itself. At Debug pcluster is
+	// run with --debug, and its verbose output is logged by the provider at
+	// debug level, so the provider must also be run with --debug to show it.
+	// Defaults to Info.
+	// +optional
+	// +kubebuilder:validation:Enum=Info;Debug
+	CLILogLevel string `json:"cliLogLevel,omitempty"`
+
+	// EndpointURL is the endpoint of every AWS service pcluster calls, e.g.
+	// http://localhost:4566 to test against LocalStack or moto instead of
+	// AWS. It is passed to pcluster as AWS_ENDPOINT_URL, which requires
+	// botocore 1.31.31 or later.
+	// +optional
+	EndpointURL string `json:"endpointURL,omitempty"`
+
+	// DefaultRegion is the region of resources that don't specify one.
+	// +optional
+	DefaultRegion string `json:"defaultRegion,omitempty"`
+
+	// AllowedRegions are the only regions clusters may be created in.
+	// Clusters that already exist in other regions may still be observed,
+	// updated, and deleted. Clusters may be created in any region when unset.
+	// +optional
+	AllowedRegions []string `json:"allowedRegions,omitempty"`
+
+	// MinimumPclusterVersion is the oldest pcluster version the provider may
+	// use, e.g. 3.7.0. Resources using an older pcluster fail to connect.
+	// Defaults to 3.0.0.
+	// +optional
+	MinimumPclusterVersion string `json:"minimumPclusterVersion,omitempty"`
+
+	// DryRun makes the provider preview the changes it would make to
+	// resources instead of making them. Creates and updates are validated
+	// using pcluster's --dryrun option, and the changes an update would make
+	// are recorded in the resource's status. Nothing is deleted. The changes
+	// are also reported as events.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
+// CredentialsSourceIRSA authenticates by assuming an IAM role with a web
+// identity token, such as the service account token EKS projects into the
+// provider's pod.
+const CredentialsSourceIRSA xpv1.CredentialsSource = "IRSA"
+
+// Log levels of pcluster.
+const (
+	CLILogLevelInfo  = "Info"
+	CLILogLevelDebug = "Debug"
+)
+
 // ProviderCredentials required to authenticate.
 type ProviderCredentials struct {
 	// Source of the provider credentials.
-	// +kubebuilder:validation:Enum=None;Secret;InjectedIdentity;Environment;Filesystem
+	// +kubebuilder:validation:Enum=None;Secret;InjectedIdentity;Environment;Filesystem;IRSA
 	Source xpv1.CredentialsSource `json:"source"`
 
 	xpv1.CommonCredentialSelectors `json:",inline"`
+
+	// AssumeRoleARN is the ARN of an IAM role to assume, typically in
+	// another account. The credentials from the source, or the provider's
+	// ambient credentials, are only used to assume the role, which is assumed
+	// afresh by every pcluster command.
+	// +optional
+	AssumeRoleARN string `json:"assumeRoleARN,omitempty"`
+
+	// ExternalID is the external ID required to assume the role.
+	// +optional
+	ExternalID string `json:"externalID,omitempty"`
+
+	// Profile is the name of an AWS profile for pcluster to use, from the
+	// AWS config and credentials files mounted into the provider. Profiles
+	// may use SSO or chain roles. It is used instead of the provider's
+	// ambient credentials, and can't be combined with credentials from a
+	// source, IRSA, or AssumeRoleARN.
+	// +optional
+	Profile string `json:"profile,omitempty"`
+
+	// WebIdentity configures the IRSA credentials source. The role and token
+	// file EKS injects into the provider's environment are used when unset.
+	// +optional
+	WebIdentity *WebIdentity `json:"webIdentity,omitempty"`
+}
+
+// WebIdentity is an IAM role assumed with a web identity token.
+type WebIdentity struct {
+	// RoleARN is the ARN of the IAM role to assume.
+	// +optional
+	RoleARN string `json:"roleARN,omitempty"`
+
+	// TokenFile is the path of the web identity token.
+	// +optional
+	TokenFile string `json:"tokenFile,omitempty"`
 }
 
 // A ProviderConfigStatus reflects the observed state of a ProviderConfig.
@@ -27,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
@@ -39,6 +40,7 @@ import (
 	"github.com/crossplane-contrib/provider-awspcluster/apis"
 	"github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
 	awspcluster "github.com/crossplane-contrib/provider-awspcluster/internal/controller"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/controller/cluster"
 	"github.com/crossplane-contrib/provider-awspcluster/internal/controller/features"
 )
 
@@ -52,8 +54,13 @@ func main() {
 		pollInterval     = app.Flag("poll", "How often individual resources will be checked for drift from the desired state").Default("1m").Duration()
 		maxReconcileRate = app.Flag("max-reconcile-rate", "The global maximum rate per second at which resources may checked for drift from the desired state.").Default("10").Int()
 
+		maxConcurrentReconciles = app.Flag("max-concurrent-reconciles", "The maximum number of resources of each kind that may be reconciled at once. Defaults to the max reconcile rate.").Default("0").Envar("MAX_CONCURRENT_RECONCILES").Int()
+
+		healthProbeAddress = app.Flag("health-probe-bind-address", "The address the health probes, including the readiness probe that checks pcluster is runnable, are served on. The probes are disabled when empty.").Default(":8081").Envar("HEALTH_PROBE_BIND_ADDRESS").String()
+
 		namespace                  = app.Flag("namespace", "Namespace used to set as default scope in default secret store config.").Default("crossplane-system").Envar("POD_NAMESPACE").String()
 		enableExternalSecretStores = app.Flag("enable-external-secret-stores", "Enable support for ExternalSecretStores.").Default("false").Envar("ENABLE_EXTERNAL_SECRET_STORES").Bool()
+		webhookTLSCertDir          = app.Flag("webhook-tls-cert-dir", "The directory of TLS certificate that will be used by the webhook server. There should be tls.crt and tls.key files. Webhooks are disabled when unset.").Envar("WEBHOOK_TLS_CERT_DIR").String()
 	)
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 
@@ -84,17 +91,15 @@ func main() {
 		LeaderElectionResourceLock: resourcelock.LeasesResourceLock,
 		LeaseDuration:              func() *time.Duration { d := 60 * time.Second; return &d }(),
 		RenewDeadline:              func() *time.Duration { d := 50 * time.Second; return &d }(),
+
+		CertDir: *webhookTLSCertDir,
+
+		HealthProbeBindAddress: *healthProbeAddress,
 	})
 	kingpin.FatalIfError(err, "Cannot create controller manager")
 	kingpin.FatalIfError(apis.AddToScheme(mgr.GetScheme()), "Cannot add AwsPcluster APIs to scheme")
 
-	o := controller.Options{
-		Logger:                  log,
-		MaxConcurrentReconciles: *maxReconcileRate,
-		PollInterval:            *pollInterval,
-		GlobalRateLimiter:       ratelimiter.NewGlobal(*maxReconcileRate),
-		Features:                &feature.Flags{},
-	}
+	o := controllerOptions(log, *pollInterval, *maxReconcileRate, *maxConcurrentReconciles)
 
 	if *enableExternalSecretStores {
 		o.Features.Enable(features.EnableAlphaExternalSecretStores)
@@ -116,5 +121,29 @@ func main() {
 	}
 
 	kingpin.FatalIfError(awspcluster.Setup(mgr, o), "Cannot setup AwsPcluster controllers")
+	kingpin.FatalIfError(mgr.AddHealthzCheck("ping", healthz.Ping), "Cannot add health check")
+	readiness := cluster.NewReadinessChecker(log)
+	kingpin.FatalIfError(mgr.AddReadyzCheck("pcluster", readiness.Check), "Cannot add readiness check")
+	kingpin.FatalIfError(mgr.AddMetricsExtraHandler("/pcluster", readiness), "Cannot add pcluster version handler")
+	if *webhookTLSCertDir != "" {
+		kingpin.FatalIfError(awspcluster.SetupWebhooks(mgr), "Cannot setup AwsPcluster webhooks")
+	}
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
+
+// controllerOptions returns the options of every controller. Each reconcile
+// may run several pcluster commands, which call CloudFormation and other AWS
+// APIs, so many concurrent reconciles may exceed AWS's API rate limits.
+// Concurrency defaults to the maximum reconcile rate.
+func controllerOptions(log logging.Logger, pollInterval time.Duration, maxReconcileRate, maxConcurrentReconciles int) controller.Options {
+	if maxConcurrentReconciles <= 0 {
+		maxConcurrentReconciles = maxReconcileRate
+	}
+	return controller.Options{
+		Logger:                  log,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		PollInterval:            pollInterval,
+		GlobalRateLimiter:       ratelimiter.NewGlobal(maxReconcileRate),
+		Features:                &feature.Flags{},
+	}
+}
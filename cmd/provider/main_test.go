@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+func TestControllerOptions(t *testing.T) {
+	cases := map[string]struct {
+		reason                  string
+		maxConcurrentReconciles int
+		want                    int
+	}{
+		"Default": {
+			reason: "Concurrency should default to the maximum reconcile rate.",
+			want:   10,
+		},
+		"Configured": {
+			reason:                  "The configured concurrency should be passed to controller-runtime.",
+			maxConcurrentReconciles: 2,
+			want:                    2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := controllerOptions(logging.NewNopLogger(), time.Minute, 10, tc.maxConcurrentReconciles)
+			if got := o.ForControllerRuntime().MaxConcurrentReconciles; got != tc.want {
+				t.Errorf("\n%s\ncontrollerOptions(...): want %d concurrent reconciles, got %d", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
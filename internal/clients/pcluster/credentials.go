@@ -0,0 +1,260 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pcluster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+)
+
+const (
+	envAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	envSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	envSessionToken    = "AWS_SESSION_TOKEN"
+	envProfile         = "AWS_PROFILE"
+	envRoleARN         = "AWS_ROLE_ARN"
+	envTokenFile       = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	envConfigFile      = "AWS_CONFIG_FILE"
+	envCredentialsFile = "AWS_SHARED_CREDENTIALS_FILE"
+	envEndpointURL     = "AWS_ENDPOINT_URL"
+
+	defaultProfile = "default"
+
+	// The names of the AWS files written for each pcluster command.
+	awsCredentialsFile = "aws-credentials"
+	awsConfigFile      = "aws-config"
+
+	assumeRoleProfile  = "crossplane-assume-role"
+	webIdentityProfile = "crossplane-web-identity"
+	roleSessionName    = "provider-awspcluster"
+
+	// defaultTokenFile is where EKS projects the service account token used
+	// for IRSA.
+	defaultTokenFile = "/var/run/secrets/eks.amazonaws.com/serviceaccount/token"
+
+	errParseCreds    = "cannot parse credentials"
+	errIncompleteKey = "credentials must include an access key ID and a secret access key"
+	errWriteConfig   = "cannot write AWS config file"
+	errWriteCreds    = "cannot write AWS credentials file"
+	errNoRoleARN     = "IRSA credentials require a role ARN in the ProviderConfig or the " + envRoleARN + " environment variable"
+	errProfile       = "a profile can't be combined with credentials from a source, IRSA, or a role to assume; configure them in the profile instead"
+)
+
+// awsCredentials are static AWS credentials. The JSON form matches the output
+// of aws sts assume-role.
+type awsCredentials struct {
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+}
+
+// parseCredentials parses credentials in either the AWS shared credentials file
+// format, using the default profile, or as a JSON object.
+func parseCredentials(data []byte) (awsCredentials, error) {
+	var creds awsCredentials
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return awsCredentials{}, errors.Wrap(err, errParseCreds)
+		}
+	} else {
+		creds = parseSharedCredentials(data)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCredentials{}, errors.Wrap(errors.New(errIncompleteKey), errParseCreds)
+	}
+	return creds, nil
+}
+
+// parseSharedCredentials returns the default profile of an AWS shared
+// credentials file, or the only profile if there is just one.
+func parseSharedCredentials(data []byte) awsCredentials {
+	profiles := map[string]*awsCredentials{}
+	var current *awsCredentials
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = &awsCredentials{}
+			profiles[strings.TrimSpace(line[1:len(line)-1])] = current
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "aws_access_key_id":
+			current.AccessKeyID = v
+		case "aws_secret_access_key":
+			current.SecretAccessKey = v
+		case "aws_session_token":
+			current.SessionToken = v
+		}
+	}
+	if p, ok := profiles[defaultProfile]; ok {
+		return *p
+	}
+	if len(profiles) == 1 {
+		for _, p := range profiles {
+			return *p
+		}
+	}
+	return awsCredentials{}
+}
+
+// sharedCredentials returns an AWS shared credentials file with the supplied
+// credentials as its default profile.
+func sharedCredentials(creds awsCredentials) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[%s]\naws_access_key_id = %s\naws_secret_access_key = %s\n", defaultProfile, creds.AccessKeyID, creds.SecretAccessKey)
+	if creds.SessionToken != "" {
+		fmt.Fprintf(b, "aws_session_token = %s\n", creds.SessionToken)
+	}
+	return b.String()
+}
+
+// AWSFiles are the AWS shared credentials and config files pcluster is run
+// with. They are written afresh for every command and removed once it has
+// run, so credentials are never in the provider's environment or left on disk.
+type AWSFiles struct {
+	Credentials string
+	Config      string
+}
+
+// Empty returns true if there are no files to write.
+func (f AWSFiles) Empty() bool {
+	return f.Credentials == "" && f.Config == ""
+}
+
+// Write writes the files to dir and returns env configured to use them. Both
+// files are always written, so that nothing is read from the provider's own
+// AWS files.
+func (f AWSFiles) Write(dir string, env []string) ([]string, error) {
+	creds, config := filepath.Join(dir, awsCredentialsFile), filepath.Join(dir, awsConfigFile)
+	if err := os.WriteFile(creds, []byte(f.Credentials), 0o600); err != nil {
+		return nil, errors.Wrap(err, errWriteCreds)
+	}
+	if err := os.WriteFile(config, []byte(f.Config), 0o600); err != nil {
+		return nil, errors.Wrap(err, errWriteConfig)
+	}
+	out := make([]string, 0, len(env)+2)
+	for _, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		if k == envCredentialsFile || k == envConfigFile {
+			continue
+		}
+		out = append(out, e)
+	}
+	return append(out, envCredentialsFile+"="+creds, envConfigFile+"="+config), nil
+}
+
+// withWebIdentity returns env configured to assume a role with a web identity
+// token. Unset fields of wi fall back to the role and token file in the
+// provider's environment, then to the token file EKS projects.
+func withWebIdentity(env []string, wi *apisv1alpha1.WebIdentity) ([]string, error) {
+	role, token := os.Getenv(envRoleARN), os.Getenv(envTokenFile)
+	if wi != nil && wi.RoleARN != "" {
+		role = wi.RoleARN
+	}
+	if wi != nil && wi.TokenFile != "" {
+		token = wi.TokenFile
+	}
+	if role == "" {
+		return nil, errors.New(errNoRoleARN)
+	}
+	if token == "" {
+		token = defaultTokenFile
+	}
+	return append(withoutCredentials(env), envRoleARN+"="+role, envTokenFile+"="+token), nil
+}
+
+// withProfile returns env configured to use the named profile. Any credentials
+// already in env are removed, as they would take precedence over the profile.
+func withProfile(env []string, profile string) []string {
+	return append(withoutCredentials(env), envProfile+"="+profile)
+}
+
+// withAssumeRole returns env configured to use the profile written by
+// assumeRoleConfig. botocore only supports assuming a role from a config file.
+func withAssumeRole(env []string) []string {
+	out := make([]string, 0, len(env)+1)
+	for _, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		if k == envProfile {
+			continue
+		}
+		out = append(out, e)
+	}
+	return append(out, envProfile+"="+assumeRoleProfile)
+}
+
+// assumeRoleConfig returns an AWS config file with a profile that assumes the
+// supplied role. The role is assumed with a web identity token in env, then
+// with the default profile of the credentials file if shared is true, then with
+// static credentials in env, and finally with instance credentials.
+func assumeRoleConfig(env []string, shared bool, roleARN, externalID string) string {
+	vars := map[string]string{}
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		vars[k] = v
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[profile %s]\nrole_arn = %s\nrole_session_name = %s\n", assumeRoleProfile, roleARN, roleSessionName)
+	if externalID != "" {
+		fmt.Fprintf(b, "external_id = %s\n", externalID)
+	}
+	switch {
+	case vars[envRoleARN] != "" && vars[envTokenFile] != "":
+		fmt.Fprintf(b, "source_profile = %s\n\n", webIdentityProfile)
+		fmt.Fprintf(b, "[profile %s]\nrole_arn = %s\nweb_identity_token_file = %s\n", webIdentityProfile, vars[envRoleARN], vars[envTokenFile])
+	case shared:
+		fmt.Fprintf(b, "source_profile = %s\n", defaultProfile)
+	case vars[envAccessKeyID] != "":
+		b.WriteString("credential_source = Environment\n")
+	default:
+		b.WriteString("credential_source = Ec2InstanceMetadata\n")
+	}
+	return b.String()
+}
+
+// withoutCredentials returns env without any AWS credentials.
+func withoutCredentials(env []string) []string {
+	out := make([]string, 0, len(env)+3)
+	for _, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		switch k {
+		case envAccessKeyID, envSecretAccessKey, envSessionToken, envProfile, envRoleARN, envTokenFile:
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
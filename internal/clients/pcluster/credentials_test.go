@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pcluster
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestParseCredentials(t *testing.T) {
+	type want struct {
+		creds awsCredentials
+		err   error
+	}
+
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"SharedCredentials": {
+			reason: "The default profile of a shared credentials file should be used.",
+			data: `[other]
+aws_access_key_id = OTHER
+aws_secret_access_key = other
+
+# Used by the provider.
+[default]
+aws_access_key_id = AKIAEXAMPLE
+aws_secret_access_key = secret
+aws_session_token = token
+`,
+			want: want{creds: awsCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}},
+		},
+		"SingleProfile": {
+			reason: "The only profile of a shared credentials file should be used, whatever its name.",
+			data:   "[provider]\naws_access_key_id=AKIAEXAMPLE\naws_secret_access_key=secret\n",
+			want:   want{creds: awsCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"}},
+		},
+		"JSON": {
+			reason: "Credentials should be parsed from JSON.",
+			data:   `{"accessKeyId": "AKIAEXAMPLE", "secretAccessKey": "secret", "sessionToken": "token"}`,
+			want:   want{creds: awsCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}},
+		},
+		"Incomplete": {
+			reason: "Credentials without a secret access key should be rejected.",
+			data:   `{"accessKeyId": "AKIAEXAMPLE"}`,
+			want:   want{err: errors.Wrap(errors.New(errIncompleteKey), errParseCreds)},
+		},
+		"NoDefaultProfile": {
+			reason: "A shared credentials file with several profiles but no default should be rejected.",
+			data:   "[a]\naws_access_key_id=A\naws_secret_access_key=a\n[b]\naws_access_key_id=B\naws_secret_access_key=b\n",
+			want:   want{err: errors.Wrap(errors.New(errIncompleteKey), errParseCreds)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseCredentials([]byte(tc.data))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nparseCredentials(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.creds, got); diff != "" {
+				t.Errorf("\n%s\nparseCredentials(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSharedCredentials(t *testing.T) {
+	want := "[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\naws_session_token = token\n"
+	got := sharedCredentials(awsCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("sharedCredentials(...): -want, +got:\n%s\n", diff)
+	}
+	if creds, err := parseCredentials([]byte(got)); err != nil || creds.SessionToken != "token" {
+		t.Errorf("parseCredentials(sharedCredentials(...)): want the credentials back, got %v, %v", creds, err)
+	}
+}
+
+func TestWriteAWSFiles(t *testing.T) {
+	dir := t.TempDir()
+	env := []string{"PATH=/usr/bin", "AWS_CONFIG_FILE=/home/provider/.aws/config"}
+	got, err := AWSFiles{Credentials: "[default]\n"}.Write(dir, env)
+	if err != nil {
+		t.Fatalf("f.Write(...): %s", err)
+	}
+	want := []string{"PATH=/usr/bin", "AWS_SHARED_CREDENTIALS_FILE=" + filepath.Join(dir, awsCredentialsFile), "AWS_CONFIG_FILE=" + filepath.Join(dir, awsConfigFile)}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("f.Write(...): -want, +got:\n%s\n", diff)
+	}
+	fi, err := os.Stat(filepath.Join(dir, awsCredentialsFile))
+	if err != nil {
+		t.Fatalf("os.Stat(...): %s", err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("f.Write(...): want credentials file mode 0600, got %o", fi.Mode().Perm())
+	}
+}
+
+func TestAssumeRoleConfig(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		env    []string
+		shared bool
+		want   string
+	}{
+		"StaticCredentials": {
+			reason: "Static credentials in the environment should be used to assume the role.",
+			env:    []string{"AWS_ACCESS_KEY_ID=AKIAEXAMPLE", "AWS_SECRET_ACCESS_KEY=secret"},
+			want: `[profile crossplane-assume-role]
+role_arn = arn:aws:iam::123456789012:role/pcluster
+role_session_name = provider-awspcluster
+external_id = 0123
+credential_source = Environment
+`,
+		},
+		"SharedCredentials": {
+			reason: "Credentials in the credentials file should take precedence over those in the environment.",
+			env:    []string{"AWS_ACCESS_KEY_ID=AKIAEXAMPLE", "AWS_SECRET_ACCESS_KEY=secret"},
+			shared: true,
+			want: `[profile crossplane-assume-role]
+role_arn = arn:aws:iam::123456789012:role/pcluster
+role_session_name = provider-awspcluster
+external_id = 0123
+source_profile = default
+`,
+		},
+		"WebIdentity": {
+			reason: "A web identity token should take precedence over static credentials.",
+			env:    []string{"AWS_ACCESS_KEY_ID=AKIAEXAMPLE", "AWS_ROLE_ARN=arn:aws:iam::210987654321:role/irsa", "AWS_WEB_IDENTITY_TOKEN_FILE=/token"},
+			want: `[profile crossplane-assume-role]
+role_arn = arn:aws:iam::123456789012:role/pcluster
+role_session_name = provider-awspcluster
+external_id = 0123
+source_profile = crossplane-web-identity
+
+[profile crossplane-web-identity]
+role_arn = arn:aws:iam::210987654321:role/irsa
+web_identity_token_file = /token
+`,
+		},
+		"Instance": {
+			reason: "Instance credentials should be used when there are no others.",
+			want: `[profile crossplane-assume-role]
+role_arn = arn:aws:iam::123456789012:role/pcluster
+role_session_name = provider-awspcluster
+external_id = 0123
+credential_source = Ec2InstanceMetadata
+`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := assumeRoleConfig(tc.env, tc.shared, "arn:aws:iam::123456789012:role/pcluster", "0123")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nassumeRoleConfig(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
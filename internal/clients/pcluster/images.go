@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pcluster
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errListImages  = "cannot list official images"
+	errParseImages = "cannot parse official images"
+)
+
+// OfficialImage is an AMI published by AWS ParallelCluster that may be used
+// as the parent image of a build, or the image of a cluster.
+type OfficialImage struct {
+	AmiID        string `json:"amiId"`
+	Name         string `json:"name"`
+	OS           string `json:"os"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture"`
+}
+
+// ListOfficialImagesOutput omits images entirely when there are none.
+type ListOfficialImagesOutput struct {
+	Images []OfficialImage `json:"images,omitempty"`
+}
+
+// A RunFn runs pcluster with the supplied arguments and returns its output.
+type RunFn func(ctx context.Context, args ...string) ([]byte, error)
+
+// ListOfficialImages returns the official pcluster AMIs in region that match
+// the supplied operating system and architecture. Either may be empty to match
+// any. No images and no error are returned if none match.
+func ListOfficialImages(ctx context.Context, run RunFn, region, os, architecture string) ([]OfficialImage, error) {
+	args := []string{"list-official-images", "--region", region}
+	if os != "" {
+		args = append(args, "--os", os)
+	}
+	if architecture != "" {
+		args = append(args, "--architecture", architecture)
+	}
+	output, err := run(ctx, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: %s", errListImages, strings.TrimSpace(string(output)))
+	}
+	var listOutput ListOfficialImagesOutput
+	if err := json.Unmarshal(output, &listOutput); err != nil {
+		return nil, errors.Wrap(err, errParseImages)
+	}
+	return listOutput.Images, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pcluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestListOfficialImages(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("resources", "officialImages.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		args   []string
+		images []OfficialImage
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		output []byte
+		err    error
+		want   want
+	}{
+		"Images": {
+			reason: "Every official image should be returned.",
+			output: b,
+			want: want{
+				args: []string{"list-official-images", "--region", "us-east-1", "--os", "alinux2"},
+				images: []OfficialImage{
+					{
+						AmiID:        "ami-0123456789abcdef0",
+						Name:         "aws-parallelcluster-3.7.0-amzn2-hvm-x86_64-202309121504 2023-09-12T15-08-23.311Z",
+						OS:           "alinux2",
+						Version:      "3.7.0",
+						Architecture: "x86_64",
+					},
+					{
+						AmiID:        "ami-0fedcba9876543210",
+						Name:         "aws-parallelcluster-3.7.0-amzn2-hvm-arm64-202309121504 2023-09-12T15-09-41.127Z",
+						OS:           "alinux2",
+						Version:      "3.7.0",
+						Architecture: "arm64",
+					},
+				},
+			},
+		},
+		"NoImages": {
+			reason: "No images and no error should be returned when none match.",
+			output: []byte(`{}`),
+			want:   want{args: []string{"list-official-images", "--region", "us-east-1", "--os", "alinux2"}},
+		},
+		"Failed": {
+			reason: "The output of a failed command should be returned with its error.",
+			output: []byte(`{"message": "Bad Request"}` + "\n"),
+			err:    errors.New("exit status 1"),
+			want: want{
+				args: []string{"list-official-images", "--region", "us-east-1", "--os", "alinux2"},
+				err:  errors.Wrap(errors.New("exit status 1"), errListImages+`: {"message": "Bad Request"}`),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var args []string
+			run := func(_ context.Context, a ...string) ([]byte, error) {
+				args = a
+				return tc.output, tc.err
+			}
+			got, err := ListOfficialImages(context.Background(), run, "us-east-1", "alinux2", "")
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nListOfficialImages(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.images, got); diff != "" {
+				t.Errorf("\n%s\nListOfficialImages(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.args, args); diff != "" {
+				t.Errorf("\n%s\nListOfficialImages(...): -want args, +got args:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pcluster configures how the pcluster CLI is run for a
+// ProviderConfig.
+package pcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+)
+
+const (
+	// Binary is the pcluster binary that is run from the PATH.
+	Binary = "pcluster"
+
+	// EnvVirtualEnv is the path of a Python virtual environment pcluster is
+	// installed in.
+	EnvVirtualEnv = "PYTHON_VENV_PATH"
+
+	errGetCreds   = "cannot get credentials"
+	errFindBinary = "cannot find pcluster binary"
+)
+
+// Setup is how pcluster is run for a ProviderConfig.
+type Setup struct {
+	// Binary is the pcluster binary to run.
+	Binary string
+
+	// Env is the environment to run it in.
+	Env []string
+
+	// Files are the AWS files to write for each command.
+	Files AWSFiles
+
+	// Credentials are the ProviderConfig's credentials, if it has any.
+	Credentials []byte
+}
+
+// Configure returns how pcluster is run for the ProviderConfig: its binary, and
+// the environment and AWS files that give it the ProviderConfig's credentials,
+// endpoint, and role. Without credentials pcluster uses the provider's ambient
+// credentials.
+func Configure(ctx context.Context, kube client.Client, spec apisv1alpha1.ProviderConfigSpec) (Setup, error) {
+	var data []byte
+	cd := spec.Credentials
+	switch cd.Source {
+	case apisv1alpha1.CredentialsSourceIRSA, xpv1.CredentialsSourceInjectedIdentity:
+		// pcluster authenticates itself using its environment.
+	default:
+		var err error
+		data, err = resource.CommonCredentialExtractor(ctx, cd.Source, kube, cd.CommonCredentialSelectors)
+		if err != nil {
+			return Setup{}, errors.Wrap(err, errGetCreds)
+		}
+	}
+
+	binary, env, err := ResolveBinary(spec)
+	if err != nil {
+		return Setup{}, err
+	}
+	var files AWSFiles
+	switch {
+	case cd.Profile != "":
+		if len(data) > 0 || cd.Source == apisv1alpha1.CredentialsSourceIRSA || cd.AssumeRoleARN != "" {
+			return Setup{}, errors.Wrap(errors.New(errProfile), errGetCreds)
+		}
+		env = withProfile(env, cd.Profile)
+	case cd.Source == apisv1alpha1.CredentialsSourceIRSA:
+		if env, err = withWebIdentity(env, cd.WebIdentity); err != nil {
+			return Setup{}, errors.Wrap(err, errGetCreds)
+		}
+	case len(data) > 0:
+		creds, err := parseCredentials(data)
+		if err != nil {
+			return Setup{}, errors.Wrap(err, errGetCreds)
+		}
+		env = withoutCredentials(env)
+		files.Credentials = sharedCredentials(creds)
+	}
+	if spec.EndpointURL != "" {
+		env = append(env, envEndpointURL+"="+spec.EndpointURL)
+	}
+	if cd.AssumeRoleARN != "" {
+		files.Config = assumeRoleConfig(env, files.Credentials != "", cd.AssumeRoleARN, cd.ExternalID)
+		env = withAssumeRole(env)
+	}
+	return Setup{Binary: binary, Env: env, Files: files, Credentials: data}, nil
+}
+
+// ResolveBinary returns the pcluster binary to run and the environment to run
+// it in. The binary in the ProviderConfig takes precedence over the virtual
+// environment, which takes precedence over the PATH.
+func ResolveBinary(spec apisv1alpha1.ProviderConfigSpec) (string, []string, error) {
+	binary := Binary
+	path := ""
+	if spec.PclusterBinaryPath != "" {
+		if _, err := os.Stat(spec.PclusterBinaryPath); err != nil {
+			return "", nil, errors.Wrap(err, errFindBinary)
+		}
+		binary = spec.PclusterBinaryPath
+	} else {
+		venvBinary, venvPath, err := getVEnvPath()
+		if err != nil {
+			return "", nil, err
+		}
+		if venvBinary != "" {
+			binary, path = venvBinary, venvPath
+		}
+	}
+	env := os.Environ()
+	if path != "" {
+		env = append(env, fmt.Sprintf("PATH=%s", path))
+	}
+	return binary, env, nil
+}
+
+// getVEnvPath returns the path of the pcluster binary in the virtual environment
+// named by PYTHON_VENV_PATH, and a PATH that includes the environment's bin
+// directory. Both are empty if PYTHON_VENV_PATH is unset.
+func getVEnvPath() (string, string, error) {
+	vEnvPath, ok := os.LookupEnv(EnvVirtualEnv)
+	if !ok {
+		return "", "", nil
+	}
+
+	binary := filepath.Join(vEnvPath, "bin", Binary)
+	_, err := os.Stat(binary)
+	if err != nil {
+		return "", "", fmt.Errorf("pcluster file not found: %w", err)
+	}
+	// The binary is run by its absolute path, as the command's PATH is not
+	// used to find it.
+	virtEnvPath := fmt.Sprintf("%s/bin:%s", vEnvPath, os.Getenv("PATH"))
+	return binary, virtEnvPath, nil
+}
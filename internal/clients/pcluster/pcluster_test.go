@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+)
+
+// hasEnv returns true if env contains an entry with the supplied prefix.
+func hasEnv(env []string, prefix string) bool {
+	for _, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConfigure(t *testing.T) {
+	venv := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(venv, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(venv, "bin", Binary), nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	kube := &test.MockClient{MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+		if s, ok := obj.(*corev1.Secret); ok {
+			s.Data = map[string][]byte{"credentials": []byte("[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n")}
+		}
+		return nil
+	})}
+	secret := xpv1.CommonCredentialSelectors{SecretRef: &xpv1.SecretKeySelector{
+		SecretReference: xpv1.SecretReference{Name: "creds", Namespace: "crossplane-system"},
+		Key:             "credentials",
+	}}
+
+	type want struct {
+		binary string
+		env    []string
+		files  AWSFiles
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		venv   string
+		spec   apisv1alpha1.ProviderConfigSpec
+		want   want
+	}{
+		"Default": {
+			reason: "pcluster should be looked up on the PATH by default.",
+			want:   want{binary: Binary},
+		},
+		"VirtualEnvironment": {
+			reason: "pcluster should be run from the virtual environment, which should be on the command's PATH.",
+			venv:   venv,
+			want: want{
+				binary: filepath.Join(venv, "bin", Binary),
+				env:    []string{fmt.Sprintf("PATH=%s/bin:", venv)},
+			},
+		},
+		"BinaryPath": {
+			reason: "A binary path in the ProviderConfig should take precedence over the virtual environment.",
+			venv:   venv,
+			spec:   apisv1alpha1.ProviderConfigSpec{PclusterBinaryPath: filepath.Join(venv, "bin", Binary)},
+			want:   want{binary: filepath.Join(venv, "bin", Binary)},
+		},
+		"Credentials": {
+			reason: "Credentials from the ProviderConfig should be written to a credentials file, not the command's environment.",
+			spec: apisv1alpha1.ProviderConfigSpec{Credentials: apisv1alpha1.ProviderCredentials{
+				Source:                    xpv1.CredentialsSourceSecret,
+				CommonCredentialSelectors: secret,
+			}},
+			want: want{
+				binary: Binary,
+				files:  AWSFiles{Credentials: "[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n"},
+			},
+		},
+		"WebIdentity": {
+			reason: "The IRSA credentials source should configure pcluster to assume the role with the web identity token.",
+			spec: apisv1alpha1.ProviderConfigSpec{Credentials: apisv1alpha1.ProviderCredentials{
+				Source:      apisv1alpha1.CredentialsSourceIRSA,
+				WebIdentity: &apisv1alpha1.WebIdentity{RoleARN: "arn:aws:iam::123456789012:role/pcluster"},
+			}},
+			want: want{
+				binary: Binary,
+				env:    []string{"AWS_ROLE_ARN=arn:aws:iam::123456789012:role/pcluster", "AWS_WEB_IDENTITY_TOKEN_FILE=" + defaultTokenFile},
+			},
+		},
+		"AssumeRole": {
+			reason: "pcluster should be configured to assume the ProviderConfig's role.",
+			spec: apisv1alpha1.ProviderConfigSpec{Credentials: apisv1alpha1.ProviderCredentials{
+				AssumeRoleARN: "arn:aws:iam::123456789012:role/pcluster",
+				ExternalID:    "0123",
+			}},
+			want: want{
+				binary: Binary,
+				env:    []string{"AWS_PROFILE=" + assumeRoleProfile},
+				files:  AWSFiles{Config: "[profile crossplane-assume-role]\nrole_arn = arn:aws:iam::123456789012:role/pcluster\n"},
+			},
+		},
+		"EndpointURL": {
+			reason: "pcluster should call the ProviderConfig's endpoint instead of AWS.",
+			spec:   apisv1alpha1.ProviderConfigSpec{EndpointURL: "http://localhost:4566"},
+			want: want{
+				binary: Binary,
+				env:    []string{"AWS_ENDPOINT_URL=http://localhost:4566"},
+			},
+		},
+		"Profile": {
+			reason: "pcluster should use the ProviderConfig's profile, from the virtual environment.",
+			venv:   venv,
+			spec: apisv1alpha1.ProviderConfigSpec{Credentials: apisv1alpha1.ProviderCredentials{
+				Source:  xpv1.CredentialsSourceNone,
+				Profile: "hpc-sso",
+			}},
+			want: want{
+				binary: filepath.Join(venv, "bin", Binary),
+				env:    []string{fmt.Sprintf("PATH=%s/bin:", venv), "AWS_PROFILE=hpc-sso"},
+			},
+		},
+		"ProfileWithCredentials": {
+			reason: "Configuring pcluster should fail if the ProviderConfig specifies both a profile and credentials.",
+			spec: apisv1alpha1.ProviderConfigSpec{Credentials: apisv1alpha1.ProviderCredentials{
+				Source:                    xpv1.CredentialsSourceSecret,
+				Profile:                   "hpc-sso",
+				CommonCredentialSelectors: secret,
+			}},
+			want: want{err: errors.Wrap(errors.New(errProfile), errGetCreds)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if tc.venv != "" {
+				t.Setenv(EnvVirtualEnv, tc.venv)
+			}
+			if tc.spec.Credentials.Source == "" {
+				tc.spec.Credentials.Source = xpv1.CredentialsSourceNone
+			}
+			path := os.Getenv("PATH")
+			got, err := Configure(context.Background(), kube, tc.spec)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("\n%s\nConfigure(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				return
+			}
+			if got.Binary != tc.want.binary {
+				t.Errorf("\n%s\nConfigure(...): want binary %q, got %q", tc.reason, tc.want.binary, got.Binary)
+			}
+			for _, env := range tc.want.env {
+				if !hasEnv(got.Env, env) {
+					t.Errorf("\n%s\nConfigure(...): want env with prefix %q, got %v", tc.reason, env, got.Env)
+				}
+			}
+			if !strings.HasPrefix(got.Files.Credentials, tc.want.files.Credentials) || !strings.HasPrefix(got.Files.Config, tc.want.files.Config) {
+				t.Errorf("\n%s\nConfigure(...): want AWS files with prefixes %+v, got %+v", tc.reason, tc.want.files, got.Files)
+			}
+			if hasEnv(got.Env, "AWS_ACCESS_KEY_ID=AKIAEXAMPLE") {
+				t.Errorf("\n%s\nConfigure(...): want no credentials in env, got %v", tc.reason, got.Env)
+			}
+			if os.Getenv("PATH") != path {
+				t.Errorf("\n%s\nConfigure(...): process PATH was modified", tc.reason)
+			}
+		})
+	}
+}
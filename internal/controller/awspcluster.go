@@ -18,6 +18,7 @@ package controller
 
 import (
 	"github.com/crossplane-contrib/provider-awspcluster/internal/controller/cluster"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/controller/image"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -30,6 +31,7 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 	for _, setup := range []func(ctrl.Manager, controller.Options) error{
 		config.Setup,
 		cluster.Setup,
+		image.Setup,
 	} {
 		if err := setup(mgr, o); err != nil {
 			return err
@@ -37,3 +39,15 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 	}
 	return nil
 }
+
+// SetupWebhooks adds all AwsPcluster webhooks to the supplied manager.
+func SetupWebhooks(mgr ctrl.Manager) error {
+	for _, setup := range []func(ctrl.Manager) error{
+		cluster.SetupWebhook,
+	} {
+		if err := setup(mgr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
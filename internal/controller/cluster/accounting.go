@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const errSlurmAccounting = "invalid Slurm accounting configuration"
+
+// secretArnRegex matches the ARNs of Secrets Manager secrets.
+var secretArnRegex = regexp.MustCompile(`^arn:aws[a-z-]*:secretsmanager:[a-z0-9-]+:\d{12}:secret:.+$`)
+
+// validateSlurmAccounting returns an error naming each problem with the Slurm
+// accounting configuration of a Slurm cluster, either the database slurmdbd
+// connects to or an external slurmdbd. pcluster only reports most of these
+// once the head node fails to start, long after the cluster was created.
+func validateSlurmAccounting(config string) error {
+	var sc slurmAccountingConfiguration
+	if err := yaml.Unmarshal([]byte(config), &sc); err != nil {
+		return errors.Wrap(err, errConfigYAML)
+	}
+	if sc.Scheduling.Scheduler != "slurm" {
+		return nil
+	}
+
+	var problems []string
+	if db := sc.Scheduling.SlurmSettings.Database; db != nil {
+		problems = append(problems, validateDatabaseURI(db.URI)...)
+		if db.UserName == "" {
+			problems = append(problems, "Database.UserName is required")
+		}
+		switch {
+		case db.PasswordSecretArn == "":
+			problems = append(problems, "Database.PasswordSecretArn is required")
+		case !secretArnRegex.MatchString(db.PasswordSecretArn):
+			problems = append(problems, "Database.PasswordSecretArn "+strconv.Quote(db.PasswordSecretArn)+" is not the ARN of a Secrets Manager secret")
+		}
+	}
+	if dbd := sc.Scheduling.SlurmSettings.ExternalSlurmdbd; dbd != nil {
+		if dbd.Host == "" {
+			problems = append(problems, "ExternalSlurmdbd.Host is required")
+		}
+		if dbd.Port != nil && (*dbd.Port < 1 || *dbd.Port > 65535) {
+			problems = append(problems, "ExternalSlurmdbd.Port "+strconv.Itoa(*dbd.Port)+" is not a valid port")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("%s: %s", errSlurmAccounting, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateDatabaseURI returns the problems with the URI of an accounting
+// database, which is its host, optionally followed by a port.
+func validateDatabaseURI(uri string) []string {
+	if uri == "" {
+		return []string{"Database.Uri is required"}
+	}
+	if strings.Contains(uri, "://") {
+		return []string{"Database.Uri " + strconv.Quote(uri) + " must be a host and optional port, without a scheme"}
+	}
+	host, port, err := net.SplitHostPort(uri)
+	if err != nil {
+		// Without a port, the whole URI is the host.
+		return nil
+	}
+	if host == "" {
+		return []string{"Database.Uri " + strconv.Quote(uri) + " has no host"}
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return []string{"Database.Uri " + strconv.Quote(uri) + " has an invalid port"}
+	}
+	return nil
+}
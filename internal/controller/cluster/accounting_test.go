@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestValidateSlurmAccounting(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		config string
+		want   error
+	}{
+		"NoAccounting": {
+			reason: "A Slurm cluster without accounting should be valid.",
+			config: "Scheduling:\n  Scheduler: slurm\n",
+		},
+		"Database": {
+			reason: "A complete accounting database should be valid.",
+			config: `Scheduling:
+  Scheduler: slurm
+  SlurmSettings:
+    Database:
+      Uri: slurm-db.cluster-0123.us-east-1.rds.amazonaws.com:3306
+      UserName: admin
+      PasswordSecretArn: arn:aws:secretsmanager:us-east-1:123456789012:secret:slurm-db-AbCdEf
+`,
+		},
+		"DatabaseWithoutPort": {
+			reason: "An accounting database's port should be optional.",
+			config: `Scheduling:
+  Scheduler: slurm
+  SlurmSettings:
+    Database:
+      Uri: slurm-db.example.com
+      UserName: admin
+      PasswordSecretArn: arn:aws:secretsmanager:us-east-1:123456789012:secret:slurm-db-AbCdEf
+`,
+		},
+		"DatabaseMissingFields": {
+			reason: "Every missing field of an accounting database should be reported.",
+			config: "Scheduling:\n  Scheduler: slurm\n  SlurmSettings:\n    Database: {}\n",
+			want:   errors.Errorf("%s: %s", errSlurmAccounting, "Database.Uri is required; Database.UserName is required; Database.PasswordSecretArn is required"),
+		},
+		"DatabaseInvalidFields": {
+			reason: "A database URI with a scheme and a password that isn't a secret ARN should be reported.",
+			config: `Scheduling:
+  Scheduler: slurm
+  SlurmSettings:
+    Database:
+      Uri: mysql://slurm-db.example.com:3306
+      UserName: admin
+      PasswordSecretArn: hunter2
+`,
+			want: errors.Errorf("%s: %s", errSlurmAccounting, `Database.Uri "mysql://slurm-db.example.com:3306" must be a host and optional port, without a scheme; Database.PasswordSecretArn "hunter2" is not the ARN of a Secrets Manager secret`),
+		},
+		"DatabaseInvalidPort": {
+			reason: "A database URI with an invalid port should be reported.",
+			config: `Scheduling:
+  Scheduler: slurm
+  SlurmSettings:
+    Database:
+      Uri: slurm-db.example.com:mysql
+      UserName: admin
+      PasswordSecretArn: arn:aws:secretsmanager:us-east-1:123456789012:secret:slurm-db-AbCdEf
+`,
+			want: errors.Errorf("%s: %s", errSlurmAccounting, `Database.Uri "slurm-db.example.com:mysql" has an invalid port`),
+		},
+		"ExternalSlurmdbd": {
+			reason: "An external slurmdbd without a host or with an invalid port should be reported.",
+			config: "Scheduling:\n  Scheduler: slurm\n  SlurmSettings:\n    ExternalSlurmdbd:\n      Port: 70000\n",
+			want:   errors.Errorf("%s: %s", errSlurmAccounting, "ExternalSlurmdbd.Host is required; ExternalSlurmdbd.Port 70000 is not a valid port"),
+		},
+		"NotSlurm": {
+			reason: "Accounting settings should only be checked for Slurm clusters.",
+			config: "Scheduling:\n  Scheduler: awsbatch\n  SlurmSettings:\n    Database: {}\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateSlurmAccounting(tc.config)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nvalidateSlurmAccounting(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCreateInvalidSlurmAccounting(t *testing.T) {
+	fe := &fakeexec.FakeExec{}
+	e := external{executor: fe, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.Spec.ForProvider.ClusterConfiguration = "Scheduling:\n  Scheduler: slurm\n  SlurmSettings:\n    Database: {}\n"
+
+	_, err := e.Create(context.Background(), cr)
+	if err == nil || !strings.HasPrefix(err.Error(), errSlurmAccounting) {
+		t.Errorf("e.Create(...): want an invalid Slurm accounting error, got %v", err)
+	}
+	if fe.CommandCalls != 0 {
+		t.Errorf("e.Create(...): want no pcluster commands, got %d", fe.CommandCalls)
+	}
+	if cr.Status.AtProvider.LastCommandFailure != nil {
+		t.Errorf("e.Create(...): want no command failure recorded, got %+v", cr.Status.AtProvider.LastCommandFailure)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"strconv"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+// An argOption adds options to the arguments of a pcluster command.
+type argOption func(cr *v1alpha1.Cluster, args []string) []string
+
+// pclusterArgs returns the arguments of a pcluster command on the supplied
+// cluster. Every such command is passed the cluster's name and region; any
+// other options are added by opts, in order.
+func (c *external) pclusterArgs(command string, cr *v1alpha1.Cluster, opts ...argOption) []string {
+	args := []string{command, "--cluster-name", cr.Name, "--region", c.region(cr)}
+	for _, o := range opts {
+		args = o(cr, args)
+	}
+	return args
+}
+
+// withArgs adds the supplied arguments.
+func withArgs(a ...string) argOption {
+	return func(_ *v1alpha1.Cluster, args []string) []string {
+		return append(args, a...)
+	}
+}
+
+// withConfiguration adds the cluster's configuration file, or its URL.
+func withConfiguration() argOption {
+	return func(cr *v1alpha1.Cluster, args []string) []string {
+		if u := cr.Spec.ForProvider.ClusterConfigurationURL; u != "" {
+			return append(args, "--cluster-configuration", u)
+		}
+		return append(args, "--cluster-configuration", configFileName(cr))
+	}
+}
+
+// withTags adds the cluster's tags.
+func withTags() argOption {
+	return func(cr *v1alpha1.Cluster, args []string) []string {
+		return append(args, tagArgs(cr.Spec.ForProvider.Tags)...)
+	}
+}
+
+// withSuppressValidators adds the validators the cluster suppresses.
+func withSuppressValidators() argOption {
+	return func(cr *v1alpha1.Cluster, args []string) []string {
+		return append(args, suppressValidatorArgs(cr.Spec.ForProvider.SuppressValidators)...)
+	}
+}
+
+// withValidationFailureLevel adds the level of validation message that fails
+// the command, if the cluster specifies it.
+func withValidationFailureLevel() argOption {
+	return func(cr *v1alpha1.Cluster, args []string) []string {
+		if l := cr.Spec.ForProvider.ValidationFailureLevel; l != "" {
+			return append(args, "--validation-failure-level", l)
+		}
+		return args
+	}
+}
+
+// withRollbackOnFailure adds whether the cluster's stack is rolled back if
+// creation fails, if the cluster specifies it.
+func withRollbackOnFailure() argOption {
+	return func(cr *v1alpha1.Cluster, args []string) []string {
+		if r := cr.Spec.ForProvider.RollbackOnFailure; r != nil {
+			return append(args, "--rollback-on-failure", strconv.FormatBool(*r))
+		}
+		return args
+	}
+}
+
+// withForceUpdate adds whether updates are forced, if the cluster specifies
+// it.
+func withForceUpdate() argOption {
+	return func(cr *v1alpha1.Cluster, args []string) []string {
+		return append(args, forceUpdateArgs(cr.Spec.ForProvider.ForceUpdate)...)
+	}
+}
+
+// withDryRun makes the command a dry-run if dryRun is true.
+func withDryRun(dryRun bool) argOption {
+	return func(_ *v1alpha1.Cluster, args []string) []string {
+		if dryRun {
+			return append(args, "--dryrun", "true")
+		}
+		return args
+	}
+}
+
+// withExtraArgs adds the cluster's extra arguments. They must be added last.
+func withExtraArgs() argOption {
+	return func(cr *v1alpha1.Cluster, args []string) []string {
+		return append(args, cr.Spec.ForProvider.ExtraArgs...)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestPclusterArgs(t *testing.T) {
+	yes := true
+
+	cases := map[string]struct {
+		reason  string
+		command string
+		cr      func(cr *v1alpha1.Cluster)
+		opts    []argOption
+		want    []string
+	}{
+		"NameAndRegion": {
+			reason:  "Every command should be passed the cluster's name and region.",
+			command: "describe-cluster",
+			want:    []string{"describe-cluster", "--cluster-name", "test", "--region", "us-east-1"},
+		},
+		"DefaultRegion": {
+			reason:  "The default region should be passed if the cluster has no region.",
+			command: "describe-cluster",
+			cr:      func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.Region = "" },
+			want:    []string{"describe-cluster", "--cluster-name", "test", "--region", "eu-west-1"},
+		},
+		"Unset": {
+			reason:  "Options the cluster doesn't specify should not be added.",
+			command: "update-cluster",
+			opts:    []argOption{withTags(), withSuppressValidators(), withValidationFailureLevel(), withRollbackOnFailure(), withForceUpdate(), withDryRun(false), withExtraArgs()},
+			want:    []string{"update-cluster", "--cluster-name", "test", "--region", "us-east-1"},
+		},
+		"Ordered": {
+			reason:  "Options should be added in order, with extra arguments last.",
+			command: "update-cluster",
+			cr: func(cr *v1alpha1.Cluster) {
+				cr.Spec.ForProvider.ClusterConfigurationFileName = "hpc.yaml"
+				cr.Spec.ForProvider.Tags = []v1alpha1.Tag{{Key: "team", Value: "hpc"}}
+				cr.Spec.ForProvider.SuppressValidators = []string{"ALL"}
+				cr.Spec.ForProvider.ForceUpdate = &yes
+				cr.Spec.ForProvider.ExtraArgs = []string{"--validation-failure-level", "WARNING"}
+			},
+			opts: []argOption{withConfiguration(), withTags(), withSuppressValidators(), withForceUpdate(), withDryRun(true), withExtraArgs()},
+			want: []string{
+				"update-cluster",
+				"--cluster-name", "test",
+				"--region", "us-east-1",
+				"--cluster-configuration", "hpc.yaml",
+				"--tags", "Key=team,Value=hpc",
+				"--suppress-validators", "ALL",
+				"--force-update", "true",
+				"--dryrun", "true",
+				"--validation-failure-level", "WARNING",
+			},
+		},
+		"ValidationFailureLevel": {
+			reason:  "The cluster's validation failure level should be added.",
+			command: "create-cluster",
+			cr:      func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ValidationFailureLevel = "WARNING" },
+			opts:    []argOption{withSuppressValidators(), withValidationFailureLevel(), withRollbackOnFailure()},
+			want:    []string{"create-cluster", "--cluster-name", "test", "--region", "us-east-1", "--validation-failure-level", "WARNING"},
+		},
+		"Args": {
+			reason:  "Arbitrary arguments should be added.",
+			command: "update-compute-fleet",
+			opts:    []argOption{withArgs("--status", FleetStopRequested)},
+			want:    []string{"update-compute-fleet", "--cluster-name", "test", "--region", "us-east-1", "--status", FleetStopRequested},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := makeCluster()
+			if tc.cr != nil {
+				tc.cr(cr)
+			}
+			e := external{defaultRegion: "eu-west-1"}
+			if diff := cmp.Diff(tc.want, e.pclusterArgs(tc.command, cr, tc.opts...)); diff != "" {
+				t.Errorf("\n%s\ne.pclusterArgs(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
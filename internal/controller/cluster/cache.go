@@ -0,0 +1,204 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+// defaultDryRunCacheTTL is how long the result of a dry-run update is reused
+// for. It is short so that changes made outside of Crossplane, which don't
+// change what is observed of the cluster, are still noticed.
+const defaultDryRunCacheTTL = 5 * time.Minute
+
+// A dryRunCache caches whether clusters are up to date, so the expensive
+// update-cluster --dryrun only runs when something may have changed.
+type dryRunCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dryRunResult
+}
+
+type dryRunResult struct {
+	key      string
+	upToDate bool
+	expires  time.Time
+}
+
+func newDryRunCache(ttl time.Duration) *dryRunCache {
+	return &dryRunCache{ttl: ttl, now: time.Now, entries: map[string]dryRunResult{}}
+}
+
+// get returns the cached result for the named cluster, if it was cached with
+// the supplied key and has not expired.
+func (c *dryRunCache) get(name, key string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[name]
+	if !ok || r.key != key || !c.now().Before(r.expires) {
+		return false, false
+	}
+	return r.upToDate, true
+}
+
+// set caches the result for the named cluster under the supplied key,
+// replacing any previous result.
+func (c *dryRunCache) set(name, key string, upToDate bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[name] = dryRunResult{key: key, upToDate: upToDate, expires: c.now().Add(c.ttl)}
+}
+
+// forget removes any cached result for the named cluster.
+func (c *dryRunCache) forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// dryRunKey identifies everything a dry-run update depends on: the hash of the
+// cluster's configuration, its spec, and what was observed of the cluster. The
+// spec's generation changes whenever the spec does.
+func dryRunKey(cr *v1alpha1.Cluster, configHash string, observed DescribeClusterOutput) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00", cr.GetGeneration(), observed.ClusterStatus, observed.LastUpdatedTime.UTC().Format(time.RFC3339Nano), configHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// configHash returns the SHA-256 hash of a rendered cluster configuration.
+func configHash(config string) string {
+	h := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(h[:])
+}
+
+// defaultRegionClusterCacheTTL is how long the number of clusters in a region
+// is reused for.
+const defaultRegionClusterCacheTTL = 10 * time.Minute
+
+// A regionClusterCache caches the number of clusters in each region, so they
+// are only listed once in a while, however many Clusters are observed. Each
+// ProviderConfig may use a different AWS account, so the number is cached for
+// each ProviderConfig and region.
+type regionClusterCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[regionKey]regionClusters
+}
+
+// A regionKey identifies a region as seen through a ProviderConfig.
+type regionKey struct {
+	providerConfig string
+	region         string
+}
+
+type regionClusters struct {
+	count   int
+	expires time.Time
+}
+
+func newRegionClusterCache(ttl time.Duration) *regionClusterCache {
+	return &regionClusterCache{ttl: ttl, now: time.Now, entries: map[regionKey]regionClusters{}}
+}
+
+// fresh returns true if the number of clusters in the region was cached and
+// has not expired.
+func (c *regionClusterCache) fresh(k regionKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[k]
+	return ok && c.now().Before(r.expires)
+}
+
+// set caches the number of clusters in the region. It returns true if the
+// number differs from the one previously cached, expired or not, or if none
+// was.
+func (c *regionClusterCache) set(k regionKey, count int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[k]
+	c.entries[k] = regionClusters{count: count, expires: c.now().Add(c.ttl)}
+	return !ok || r.count != count
+}
+
+// defaultFleetCacheTTL is how long the details of a cluster's compute fleet
+// are reused for.
+const defaultFleetCacheTTL = 5 * time.Minute
+
+// A fleetCache caches the details of each cluster's compute fleet, so
+// describe-compute-fleet and describe-cluster-instances only run once in a
+// while rather than on every poll.
+type fleetCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]fleetDetails
+}
+
+// fleetDetails are what is described of a compute fleet beyond what
+// describe-cluster reports.
+type fleetDetails struct {
+	// status is the compute fleet status the details were described at.
+	status string
+
+	lastUpdatedTime      string
+	computeInstanceCount int
+	instanceCounts       []v1alpha1.InstanceCount
+	expires              time.Time
+}
+
+func newFleetCache(ttl time.Duration) *fleetCache {
+	return &fleetCache{ttl: ttl, now: time.Now, entries: map[string]fleetDetails{}}
+}
+
+// get returns the cached details of the named cluster's compute fleet, if
+// they were described at the supplied fleet status and have not expired.
+func (c *fleetCache) get(name, status string) (fleetDetails, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.entries[name]
+	if !ok || d.status != status || !c.now().Before(d.expires) {
+		return fleetDetails{}, false
+	}
+	return d, true
+}
+
+// set caches the details of the named cluster's compute fleet, replacing any
+// previously cached.
+func (c *fleetCache) set(name string, d fleetDetails) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d.expires = c.now().Add(c.ttl)
+	c.entries[name] = d
+}
+
+// forget removes any cached details of the named cluster's compute fleet.
+func (c *fleetCache) forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	k8sexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestObserveReusesDryRun(t *testing.T) {
+	dryRun := func(cmd string, args ...string) k8sexec.Cmd {
+		return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("upToDate.json", errors.New("error"))}}
+	}
+	// Each Observe also lists the region's clusters and describes the
+	// compute fleet and instances, as the cluster is CREATE_COMPLETE.
+	observe := []fakeexec.FakeCommandAction{describeWithStatus(CreateComplete), fakeOutput(`{"clusters": []}`, nil), fakeOutput("", errors.New("error")), fakeOutput("", errors.New("error"))}
+	withDryRun := append([]fakeexec.FakeCommandAction{observe[0], dryRun}, observe[1:]...)
+
+	cases := map[string]struct {
+		reason string
+		second func(cr *v1alpha1.Cluster)
+		ttl    time.Duration
+		script []fakeexec.FakeCommandAction
+	}{
+		"Unchanged": {
+			reason: "The dry-run should be skipped when nothing has changed since the last one.",
+			ttl:    time.Minute,
+			script: append(withDryRun, observe...),
+		},
+		"SpecChanged": {
+			reason: "The dry-run should run again when the spec has changed.",
+			second: func(cr *v1alpha1.Cluster) { cr.SetGeneration(2) },
+			ttl:    time.Minute,
+			script: append(withDryRun, withDryRun...),
+		},
+		"ConfigurationChanged": {
+			reason: "The dry-run should run again when the cluster configuration has changed.",
+			second: func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ClusterConfiguration = "Image:\n  Os: ubuntu2204\n" },
+			ttl:    time.Minute,
+			script: append(withDryRun, withDryRun...),
+		},
+		"Expired": {
+			reason: "The dry-run should run again once the cached result has expired.",
+			script: append(withDryRun, withDryRun...),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fe := &fakeexec.FakeExec{CommandScript: tc.script}
+			e := external{executor: fe, dryRuns: newDryRunCache(tc.ttl), logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			cr := makeCluster()
+			cr.SetGeneration(1)
+			for i := 0; i < 2; i++ {
+				if i == 1 && tc.second != nil {
+					tc.second(cr)
+				}
+				got, err := e.Observe(context.Background(), cr)
+				if err != nil {
+					t.Fatalf("\n%s\ne.Observe(...): %s", tc.reason, err)
+				}
+				if !got.ResourceUpToDate {
+					t.Errorf("\n%s\ne.Observe(...): want up to date, got not up to date", tc.reason)
+				}
+			}
+			if got, want := fe.CommandCalls, len(tc.script); got != want {
+				t.Errorf("\n%s\ne.Observe(...): want %d pcluster commands, got %d", tc.reason, want, got)
+			}
+		})
+	}
+}
+
+func TestObserveConfigHash(t *testing.T) {
+	dryRun := func(cmd string, args ...string) k8sexec.Cmd {
+		return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("upToDate.json", errors.New("error"))}}
+	}
+	observe := []fakeexec.FakeCommandAction{describeWithStatus(CreateComplete), dryRun, fakeOutput(`{"clusters": []}`, nil), fakeOutput("", errors.New("error")), fakeOutput("", errors.New("error"))}
+
+	cases := map[string]struct {
+		reason  string
+		second  func(cr *v1alpha1.Cluster)
+		changed bool
+	}{
+		"SpecChanged": {
+			reason: "The hash should not change when the spec changes without changing the configuration.",
+			second: func(cr *v1alpha1.Cluster) { cr.SetGeneration(2) },
+		},
+		"ConfigurationChanged": {
+			reason:  "The hash should change when the cluster configuration changes.",
+			second:  func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ClusterConfiguration = "Image:\n  Os: ubuntu2204\n" },
+			changed: true,
+		},
+		"OverrideChanged": {
+			reason:  "The hash should change when an override in the spec changes the configuration given to pcluster.",
+			second:  func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.HeadNodeSubnet = "subnet-11111111" },
+			changed: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fe := &fakeexec.FakeExec{CommandScript: append(append([]fakeexec.FakeCommandAction{}, observe...), observe...)}
+			e := external{executor: fe, dryRuns: newDryRunCache(0), logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			cr := makeCluster()
+			cr.SetGeneration(1)
+
+			var hashes []string
+			for i := 0; i < 2; i++ {
+				if i == 1 {
+					tc.second(cr)
+				}
+				if _, err := e.Observe(context.Background(), cr); err != nil {
+					t.Fatalf("\n%s\ne.Observe(...): %s", tc.reason, err)
+				}
+				hashes = append(hashes, cr.Status.AtProvider.ConfigHash)
+			}
+			if hashes[0] == "" {
+				t.Fatalf("\n%s\ne.Observe(...): want a configuration hash, got none", tc.reason)
+			}
+			if changed := hashes[0] != hashes[1]; changed != tc.changed {
+				t.Errorf("\n%s\ne.Observe(...): want hash changed %t, got %s then %s", tc.reason, tc.changed, hashes[0], hashes[1])
+			}
+		})
+	}
+}
+
+func TestRecordRegionClusters(t *testing.T) {
+	list := func(n int) fakeexec.FakeCommandAction {
+		clusters := make([]string, n)
+		for i := range clusters {
+			clusters[i] = fmt.Sprintf(`{"clusterName": "cluster-%d"}`, i)
+		}
+		return fakeOutput(fmt.Sprintf(`{"clusters": [%s]}`, strings.Join(clusters, ",")), nil)
+	}
+
+	now := time.Now()
+	cache := newRegionClusterCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{list(1), list(1), list(2), list(2)}}
+	r := &recordingRecorder{}
+	e := external{executor: fe, regionClusters: cache, logger: logging.NewNopLogger(), recorder: r}
+	cr := makeCluster()
+
+	steps := []struct {
+		reason         string
+		providerConfig string
+		advance        time.Duration
+		want           int
+	}{
+		{reason: "The number of clusters should be recorded the first time they are listed.", want: 1},
+		{reason: "The clusters should not be listed again while the number is cached.", want: 1},
+		{reason: "No event should be emitted when the number of clusters is unchanged.", advance: time.Minute, want: 1},
+		{reason: "An event should be emitted when the number of clusters changes.", advance: time.Minute, want: 2},
+		{reason: "The clusters should be listed again for a different ProviderConfig, which may use a different account.", providerConfig: "other", want: 3},
+	}
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		e.providerConfig = s.providerConfig
+		e.recordRegionClusters(context.Background(), e.logger, cr)
+		if len(r.reasons) != s.want {
+			t.Errorf("\n%s\ne.recordRegionClusters(...): want %d events, got %d", s.reason, s.want, len(r.reasons))
+		}
+	}
+	if fe.CommandCalls != 4 {
+		t.Errorf("e.recordRegionClusters(...): want clusters listed 4 times, got %d", fe.CommandCalls)
+	}
+}
+
+func TestSetFleetDetails(t *testing.T) {
+	describe := []fakeexec.FakeCommandAction{
+		fakeOutput(`{"status": "RUNNING", "lastStatusUpdatedTime": "2023-09-12T16:20:31.000Z"}`, nil),
+		fakeOutput(`{"instances": [{"instanceId": "i-0a1b2c3d4e5f60004", "state": "running", "nodeType": "ComputeNode", "queueName": "gpu"}]}`, nil),
+	}
+	failed := []fakeexec.FakeCommandAction{fakeOutput("", errors.New("exit status 1")), describe[1]}
+
+	var script []fakeexec.FakeCommandAction
+	for _, s := range [][]fakeexec.FakeCommandAction{describe, describe, describe, failed, describe} {
+		script = append(script, s...)
+	}
+
+	now := time.Now()
+	cache := newFleetCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	fe := &fakeexec.FakeExec{CommandScript: script}
+	e := external{executor: fe, fleets: cache, logger: logging.NewNopLogger()}
+
+	steps := []struct {
+		reason  string
+		forget  bool
+		advance time.Duration
+		status  string
+		want    int
+	}{
+		{reason: "The compute fleet should be described the first time.", status: FleetRunning, want: 2},
+		{reason: "The compute fleet should not be described again while its details are cached.", status: FleetRunning, want: 2},
+		{reason: "The compute fleet should be described again when its status changes.", status: FleetStopped, want: 4},
+		{reason: "The compute fleet should be described again once its details have expired.", advance: time.Minute, status: FleetStopped, want: 6},
+		{reason: "The compute fleet should be described again once its details are forgotten.", forget: true, status: FleetStopped, want: 8},
+		{reason: "The compute fleet's details should not be cached if it could not be described.", status: FleetStopped, want: 10},
+	}
+	for _, s := range steps {
+		if s.forget {
+			cache.forget("test")
+		}
+		now = now.Add(s.advance)
+		cr := makeCluster()
+		cr.Status.AtProvider.ComputeFleetStatus = s.status
+		e.setFleetDetails(context.Background(), e.logger, cr)
+		if fe.CommandCalls != s.want {
+			t.Errorf("\n%s\ne.setFleetDetails(...): want %d pcluster commands, got %d", s.reason, s.want, fe.CommandCalls)
+		}
+		if cr.Status.AtProvider.ComputeInstanceCount != 1 {
+			t.Errorf("\n%s\ne.setFleetDetails(...): want 1 compute instance, got %d", s.reason, cr.Status.AtProvider.ComputeInstanceCount)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	reasonDisruptiveChanges event.Reason = "DisruptiveChanges"
+
+	// allowAllChanges allows every disruptive change.
+	allowAllChanges = "*"
+
+	errDisruptiveChanges = "refusing to update the cluster with disruptive changes; add them to allowDisruptiveChanges to apply them"
+)
+
+// disruptiveParameters match the parameters of a change set whose changes
+// replace the head node, require the compute fleet to be stopped, or may lose
+// data.
+var disruptiveParameters = []*regexp.Regexp{
+	regexp.MustCompile(`^HeadNode\.(InstanceType|Networking\.SubnetId|LocalStorage\.RootVolume)`),
+	regexp.MustCompile(`^Image\.(Os|CustomAmi)$`),
+	regexp.MustCompile(`^Scheduling\.Scheduler$`),
+	regexp.MustCompile(`^Scheduling\.SlurmQueues\[[^]]*\]\.Networking\.SubnetIds`),
+	regexp.MustCompile(`^Scheduling\.SlurmQueues\[[^]]*\]\.ComputeResources\[[^]]*\]\.(InstanceType|Instances)`),
+	regexp.MustCompile(`^SharedStorage`),
+}
+
+// disruptiveChanges returns the parameters of the disruptive changes that are
+// not allowed.
+func disruptiveChanges(changes []v1alpha1.Change, allowed []string) []string {
+	var out []string
+	for _, c := range changes {
+		if isDisruptive(c.Parameter) && !isAllowed(c.Parameter, allowed) {
+			out = append(out, c.Parameter)
+		}
+	}
+	return out
+}
+
+func isDisruptive(parameter string) bool {
+	for _, re := range disruptiveParameters {
+		if re.MatchString(parameter) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowed returns true if the parameter, or a parameter it is within, is
+// allowed.
+func isAllowed(parameter string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == allowAllChanges || a == parameter || strings.HasPrefix(parameter, a+".") || strings.HasPrefix(parameter, a+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestDisruptiveChanges(t *testing.T) {
+	changes := []v1alpha1.Change{
+		{Parameter: "HeadNode.Ssh.AllowedIps"},
+		{Parameter: "HeadNode.Networking.SubnetId"},
+		{Parameter: "Scheduling.SlurmQueues[compute].ComputeResources[c5].InstanceType"},
+		{Parameter: "Scheduling.SlurmQueues[compute].ComputeResources[c5].MaxCount"},
+	}
+
+	cases := map[string]struct {
+		reason  string
+		allowed []string
+		want    []string
+	}{
+		"NoneAllowed": {
+			reason: "Disruptive changes should be refused unless they are allowed.",
+			want:   []string{"HeadNode.Networking.SubnetId", "Scheduling.SlurmQueues[compute].ComputeResources[c5].InstanceType"},
+		},
+		"Parameter": {
+			reason:  "An allowed parameter should allow changes to it.",
+			allowed: []string{"HeadNode.Networking.SubnetId"},
+			want:    []string{"Scheduling.SlurmQueues[compute].ComputeResources[c5].InstanceType"},
+		},
+		"Within": {
+			reason:  "An allowed parameter should allow changes to parameters within it.",
+			allowed: []string{"HeadNode", "Scheduling.SlurmQueues[compute]"},
+		},
+		"Prefix": {
+			reason:  "An allowed parameter should not allow changes to parameters it is merely a prefix of.",
+			allowed: []string{"HeadNode.Net", "Scheduling.SlurmQueues[comp"},
+			want:    []string{"HeadNode.Networking.SubnetId", "Scheduling.SlurmQueues[compute].ComputeResources[c5].InstanceType"},
+		},
+		"All": {
+			reason:  "* should allow every change.",
+			allowed: []string{allowAllChanges},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := disruptiveChanges(changes, tc.allowed)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ndisruptiveChanges(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUpdateDisruptiveChanges(t *testing.T) {
+	cr := makeCluster()
+	cr.Status.AtProvider.UpdateChangeSet = []v1alpha1.Change{{Parameter: "HeadNode.Networking.SubnetId"}}
+	fe := &fakeexec.FakeExec{}
+	r := &recordingRecorder{}
+	e := &external{executor: fe, logger: logging.NewNopLogger(), recorder: r}
+
+	_, err := e.Update(context.Background(), cr)
+	want := errors.Errorf("%s: %s", errDisruptiveChanges, "HeadNode.Networking.SubnetId")
+	if diff := cmp.Diff(want, err, test.EquateErrors()); diff != "" {
+		t.Errorf("e.Update(...): -want error, +got error:\n%s\n", diff)
+	}
+	if fe.CommandCalls != 0 {
+		t.Errorf("e.Update(...): want no pcluster commands, got %d", fe.CommandCalls)
+	}
+	if diff := cmp.Diff([]event.Reason{reasonDisruptiveChanges}, r.reasons); diff != "" {
+		t.Errorf("e.Update(...): -want events, +got events:\n%s\n", diff)
+	}
+}
@@ -17,19 +17,35 @@ limitations under the License.
 package cluster
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
 	k8sexec "k8s.io/utils/exec"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/crossplane/crossplane-runtime/pkg/connection"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
@@ -40,19 +56,97 @@ import (
 
 	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
 	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/clients/pcluster"
 	"github.com/crossplane-contrib/provider-awspcluster/internal/controller/features"
 )
 
 const (
 	clusterConfigFileName = "cluster-config.yaml"
 
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = time.Second
+
+	// maxLoggedOutput is the most output of a failed command that is logged.
+	maxLoggedOutput = 512
+
+	// maxStackEventPages is the most pages of stack events read to estimate
+	// the progress of an operation.
+	maxStackEventPages = 5
+
+	// tempDirPrefix prefixes the names of the provider's temporary
+	// directories. Those older than staleTempDirAge, longer than any command
+	// runs, are removed when the provider starts.
+	tempDirPrefix   = "provider-awspcluster-"
+	staleTempDirAge = time.Hour
+
+	// annotationImport marks a Cluster as adopting an existing cluster. The
+	// cluster is not updated while the annotation is "true".
+	annotationImport = "awspcluster.crossplane.io/import"
+
+	// annotationExportLogs requests the cluster's logs be exported to the
+	// named S3 bucket. They are exported once for each bucket it names.
+	annotationExportLogs = "awspcluster.crossplane.io/export-logs"
+
+	// annotationDeletionProtection prevents the cluster being deleted while
+	// it is "true".
+	annotationDeletionProtection = "awspcluster.crossplane.io/deletion-protection"
+
+	// annotationRetryDelete allows deletion of a cluster whose deletion
+	// failed to be retried while it is "true". Retrying is opt-in, as someone
+	// may be debugging the resources the failed deletion left behind.
+	annotationRetryDelete = "awspcluster.crossplane.io/retry-failed-delete"
+
+	// annotationForceReconcile forces the cluster to be updated with
+	// update-cluster, even if it appears up to date, whenever its value
+	// changes. A timestamp is a convenient value.
+	annotationForceReconcile = "awspcluster.crossplane.io/force-reconcile"
+
+	reasonListClusters event.Reason = "ListClusters"
+	reasonCreateFailed event.Reason = "CreateClusterFailed"
+	reasonUpdateFailed event.Reason = "UpdateClusterFailed"
+	reasonDeleteFailed event.Reason = "DeleteClusterFailed"
+	reasonValidation   event.Reason = "ValidationWarning"
+	reasonExportLogs   event.Reason = "ExportClusterLogs"
+	reasonProtected    event.Reason = "DeletionProtected"
+	reasonVersionDrift event.Reason = "VersionDrift"
+	reasonDryRun       event.Reason = "DryRun"
+
+	keyHeadNodePublicIP   = "headNodePublicIp"
+	keyHeadNodePrivateIP  = "headNodePrivateIp"
+	keyHeadNodeInstanceID = "headNodeInstanceId"
+
+	nodeTypeCompute = "ComputeNode"
+
+	msgCreateFailed = "cluster creation failed; delete the Cluster and create it again to retry"
+	msgDeleteFailed = "cluster deletion failed; set the " + annotationRetryDelete + " annotation to \"true\" to retry"
+
 	errNotCluster   = "managed resource is not a Cluster custom resource"
 	errTrackPCUsage = "cannot track ProviderConfig usage"
 	errGetPC        = "cannot get ProviderConfig"
-	errGetCreds     = "cannot get credentials"
+	errFindBinary   = "cannot find pcluster binary"
+	errBadRegion    = "invalid region"
+	errNoRegion     = "region must be set in the Cluster or as the ProviderConfig's default region"
+	errBadName      = "invalid cluster name"
+	errGetConfigMap = "cannot get cluster configuration ConfigMap"
+	errGetSecret    = "cannot get cluster configuration Secret"
+	errValidation   = "cluster configuration failed validation"
+	errPollInterval = "pollIntervalOverride must be positive"
+	errObserveOnly  = "cluster does not exist and cannot be created with the ObserveOnly management policy"
+	errProtected    = "cluster has deletion protection; remove the " + annotationDeletionProtection + " annotation to delete it"
+	errDeleteFailed = "cluster deletion failed; not retrying until the " + annotationRetryDelete + " annotation is \"true\""
+	errExists       = "cluster already exists"
+	errConfigYAML   = "cluster configuration is not valid YAML"
+	errConfigSource = "only one of clusterConfiguration, clusterConfigurationRef, clusterConfigurationSecretRef, and clusterConfigurationUrl may be set"
+	errConfigURL    = "invalid cluster configuration URL"
+	errBadFileName  = "invalid cluster configuration file name"
+	errExtraArgs    = "invalid extra arguments"
+
+	errUnexpectedOutput = "pcluster failed with unexpected output"
+	errNoClusterStatus  = "describe-cluster reported no cluster status; the pcluster version may not be supported"
+	errWorkingDir       = "cannot use working directory"
+	errRegionNotAllowed = "clusters may not be created in region"
 
 	errNewClient                    = "cannot create new Service"
-	virtualEnvPath                  = "PYTHON_VENV_PATH"
 	CreateInProgress PClusterStatus = "CREATE_IN_PROGRESS"
 	CreateFailed     PClusterStatus = "CREATE_FAILED"
 	CreateComplete   PClusterStatus = "CREATE_COMPLETE"
@@ -63,6 +157,18 @@ const (
 	UpdateComplete   PClusterStatus = "UPDATE_COMPLETE"
 	UpdateFailed     PClusterStatus = "UPDATE_FAILED"
 
+	ValidationError   ValidationLevel = "ERROR"
+	ValidationWarning ValidationLevel = "WARNING"
+
+	FleetRunning        FleetStatus = "RUNNING"
+	FleetStopped        FleetStatus = "STOPPED"
+	FleetStartRequested FleetStatus = "START_REQUESTED"
+	FleetStopRequested  FleetStatus = "STOP_REQUESTED"
+	FleetStarting       FleetStatus = "STARTING"
+	FleetStopping       FleetStatus = "STOPPING"
+	FleetEnabled        FleetStatus = "ENABLED"
+	FleetDisabled       FleetStatus = "DISABLED"
+
 	errPclusterCliNoChange             = "Bad Request: No changes found in your cluster configuration."
 	errPClusterCliDryRun               = "Request would have succeeded, but DryRun flag is set."
 	errPClusterCliInProgress errStatus = "Cannot execute update while stack is in"
@@ -70,6 +176,7 @@ const (
 	errStatusEmpty           errStatus = "emptyMessage"
 	errStatusUpToDate        errStatus = "clusterUpToDate"
 	errStatusNotUpToDate     errStatus = "clusterNotUpToDate"
+	errStatusInProgress      errStatus = "clusterInProgress"
 )
 
 // A NoOpService does nothing.
@@ -79,8 +186,34 @@ type errStatus = string
 
 type PClusterStatus = string
 
+type FleetStatus = string
+
+type ValidationLevel = string
+
 var (
 	newNoOpService = func(_ []byte) (interface{}, error) { return &NoOpService{}, nil }
+
+	// regionRegex matches AWS region names, including GovCloud regions.
+	regionRegex = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-\d+$`)
+
+	// clusterNameRegex matches the cluster names pcluster accepts. Names are
+	// also limited to maxClusterNameLength characters, as they are used to
+	// name the cluster's CloudFormation stack.
+	clusterNameRegex     = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+	maxClusterNameLength = 60
+
+	// reservedOptions are the pcluster options the provider sets, which may
+	// not be passed as extra arguments.
+	reservedOptions = map[string]bool{
+		"--cluster-name":          true,
+		"--cluster-configuration": true,
+		"--region":                true,
+		"--dryrun":                true,
+		"--tags":                  true,
+		"--suppress-validators":   true,
+		"--rollback-on-failure":   true,
+		"--force-update":          true,
+	}
 )
 
 // Setup adds a controller that reconciles Cluster managed resources.
@@ -92,6 +225,14 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
 	}
 
+	logPclusterVersion(o.Logger)
+	sweepStaleTempDirs(o.Logger)
+
+	if err := pclusterMetrics.register(metrics.Registry); err != nil {
+		return errors.Wrap(err, errRegisterMetrics)
+	}
+
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
 	r := managed.NewReconciler(mgr,
 		resource.ManagedKind(v1alpha1.ClusterGroupVersionKind),
 		managed.WithExternalConnecter(&connector{
@@ -99,9 +240,17 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 			usage:         resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
 			newExectuorFn: newExectuor,
 			logger:        o.Logger,
+			recorder:      recorder,
+			metrics:       pclusterMetrics,
+			dryRuns:       newDryRunCache(defaultDryRunCacheTTL),
+			images:        newOfficialImageCache(defaultOfficialImageCacheTTL),
+			regions:       newRegionClusterCache(defaultRegionClusterCacheTTL),
+			fleets:        newFleetCache(defaultFleetCacheTTL),
+			logExports:    newLogExports(),
+			namespace:     providerNamespace(),
 		}),
 		managed.WithLogger(o.Logger.WithValues("controller", name)),
-		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithRecorder(recorder),
 		managed.WithConnectionPublishers(cps...),
 		managed.WithPollInterval(o.PollInterval),
 	)
@@ -110,7 +259,7 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
 		For(&v1alpha1.Cluster{}).
-		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+		Complete(ratelimiter.NewReconciler(name, &pollIntervalReconciler{Reconciler: r, kube: mgr.GetClient(), pollInterval: o.PollInterval}, o.GlobalRateLimiter))
 }
 
 // A connector is expected to produce an ExternalClient when its Connect method
@@ -120,6 +269,18 @@ type connector struct {
 	usage         resource.Tracker
 	newExectuorFn func(creds []byte) (k8sexec.Interface, error)
 	logger        logging.Logger
+	recorder      event.Recorder
+	metrics       metricsRecorder
+	dryRuns       *dryRunCache
+	images        *officialImageCache
+	regions       *regionClusterCache
+	fleets        *fleetCache
+	logExports    *logExports
+	namespace     string
+
+	// versions caches the version of each pcluster binary, so it is only
+	// checked once.
+	versions sync.Map
 }
 
 func newExectuor(creds []byte) (k8sexec.Interface, error) {
@@ -146,89 +307,437 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errGetPC)
 	}
 
-	cd := pc.Spec.Credentials
-	data, err := resource.CommonCredentialExtractor(ctx, cd.Source, c.kube, cd.CommonCredentialSelectors)
+	setup, err := pcluster.Configure(ctx, c.kube, pc.Spec)
 	if err != nil {
-		return nil, errors.Wrap(err, errGetCreds)
+		return nil, err
 	}
-
-	svc, err := c.newExectuorFn(data)
+	svc, err := c.newExectuorFn(setup.Credentials)
 	if err != nil {
 		return nil, errors.Wrap(err, errNewClient)
 	}
-	path, err := getVEnvPath()
+	if cr.Spec.ForProvider.Region == "" && pc.Spec.DefaultRegion == "" {
+		return nil, errors.New(errNoRegion)
+	}
+
+	v, err := c.version(ctx, svc, setup.Binary, setup.Env)
 	if err != nil {
 		return nil, err
 	}
-	env := os.Environ()
-	if path != "" {
-		env = append(env, fmt.Sprintf("PATH=%s", path))
+	if err := checkVersion(v, pc.Spec.MinimumPclusterVersion); err != nil {
+		return nil, err
+	}
+	if err := checkWorkingDir(pc.Spec.WorkingDir); err != nil {
+		return nil, err
+	}
+
+	e := &external{kube: c.kube, providerConfig: pc.Name, env: setup.Env, binary: setup.Binary, version: v, defaultRegion: pc.Spec.DefaultRegion, executor: svc, fetch: fetchURL, logger: c.logger, recorder: c.recorder, metrics: c.metrics, dryRuns: c.dryRuns, officialImages: c.images, regionClusters: c.regions, fleets: c.fleets, logExports: c.logExports, awsFiles: setup.Files, namespace: c.namespace, workingDir: pc.Spec.WorkingDir, cliDebug: pc.Spec.CLILogLevel == apisv1alpha1.CLILogLevelDebug, allowedRegions: pc.Spec.AllowedRegions}
+	if pc.Spec.CommandTimeout != nil {
+		e.timeout = pc.Spec.CommandTimeout.Duration
+	}
+	e.preview = pc.Spec.DryRun
+	e.wait = pc.Spec.WaitForCompletion
+	e.maxRetries, e.retryBaseDelay = defaultMaxRetries, defaultRetryBaseDelay
+	if pc.Spec.MaxRetries != nil {
+		e.maxRetries = *pc.Spec.MaxRetries
+	}
+	if pc.Spec.RetryBaseDelay != nil {
+		e.retryBaseDelay = pc.Spec.RetryBaseDelay.Duration
+	}
+	return e, nil
+}
+
+// version returns the version of the supplied pcluster binary.
+func (c *connector) version(ctx context.Context, executor k8sexec.Interface, binary string, env []string) (*version.Version, error) {
+	if v, ok := c.versions.Load(binary); ok {
+		return v.(*version.Version), nil
 	}
+	v, err := pclusterVersion(ctx, executor, binary, env)
+	if err != nil {
+		return nil, err
+	}
+	c.versions.Store(binary, v)
+	return v, nil
+}
 
-	return &external{env: env, path: path, executor: svc, logger: c.logger}, nil
+// logPclusterVersion logs the version of the default pcluster binary. The
+// binary is only required once a ProviderConfig that uses it is, so failures
+// are just logged.
+func logPclusterVersion(log logging.Logger) {
+	binary, env, err := pcluster.ResolveBinary(apisv1alpha1.ProviderConfigSpec{})
+	if err != nil {
+		log.Info("Cannot find default pcluster binary", "error", err)
+		return
+	}
+	v, err := pclusterVersion(context.Background(), k8sexec.New(), binary, env)
+	if err != nil {
+		log.Info("Cannot get default pcluster version", "binary", binary, "error", err)
+		return
+	}
+	log.Info("Found default pcluster binary", "binary", binary, "version", v.String())
 }
 
 // An ExternalClient observes, then either creates, updates, or deletes an
 // external resource to ensure it reflects the managed resource's desired state.
 type external struct {
-	dir      string
-	env      []string
-	path     string
-	executor k8sexec.Interface
-	logger   logging.Logger
+	kube          client.Client
+	env           []string
+	binary        string
+	version       *version.Version
+	timeout       time.Duration
+	defaultRegion string
+	executor      k8sexec.Interface
+	fetch         func(ctx context.Context, url string) ([]byte, error)
+	logger        logging.Logger
+	recorder      event.Recorder
+	metrics       metricsRecorder
+	dryRuns       *dryRunCache
+	awsFiles      pcluster.AWSFiles
+	workingDir    string
+
+	// officialImages caches the architectures of pcluster's official images
+	// for preflight checks.
+	officialImages *officialImageCache
+
+	// providerConfig is the name of the ProviderConfig the client was
+	// connected with.
+	providerConfig string
+
+	// regionClusters caches the number of clusters in each region.
+	regionClusters *regionClusterCache
+
+	// fleets caches the details of each cluster's compute fleet.
+	fleets *fleetCache
+
+	// logExports tracks the exports of clusters' logs running in the
+	// background.
+	logExports *logExports
+
+	// namespace is the namespace the provider runs in, where it stores any
+	// ConfigMaps it creates.
+	namespace string
+
+	// preview makes Create, Update, and Delete preview their changes
+	// instead of making them.
+	preview bool
+
+	// cliDebug runs pcluster with --debug, logging its verbose output.
+	cliDebug bool
+
+	// allowedRegions are the only regions clusters may be created in, if
+	// any are set.
+	allowedRegions []string
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+
+	// wait makes Create, Update, and Delete wait for the operation they
+	// start to finish, polling every waitInterval.
+	wait         bool
+	waitInterval time.Duration
+}
+
+// region returns the region of the cluster, falling back to the
+// ProviderConfig's default region.
+func (c *external) region(cr *v1alpha1.Cluster) string {
+	if cr.Spec.ForProvider.Region != "" {
+		return cr.Spec.ForProvider.Region
+	}
+	return c.defaultRegion
 }
 
-func (c *external) execPcluster(ctx context.Context, cr *v1alpha1.Cluster, args ...string) ([]byte, error) {
-	err := os.Setenv("PATH", c.path)
+// clusterLogger returns a logger that identifies the supplied cluster, so the
+// logs of concurrent reconciles can be told apart. Anything resembling
+// credentials is redacted from its logs.
+func (c *external) clusterLogger(cr *v1alpha1.Cluster) logging.Logger {
+	return redactingLogger{Logger: c.logger}.WithValues("cluster", cr.GetName(), "region", c.region(cr))
+}
+
+// lateInitialize sets any unset parameters that the controller defaults, so
+// they are persisted in the Cluster's spec. It returns true if any were set.
+func lateInitialize(p *v1alpha1.ClusterParameters, defaultRegion string) bool {
+	li := false
+	if p.Region == "" && defaultRegion != "" {
+		p.Region = defaultRegion
+		li = true
+	}
+	return li
+}
+
+// execPcluster runs pcluster in dir, retrying with exponential backoff while
+// AWS throttles it. The command's AWS files are written to dir. The external
+// client may be shared by concurrent reconciles, so nothing here may modify it
+// or the process.
+func (c *external) execPcluster(ctx context.Context, log logging.Logger, dir string, args ...string) ([]byte, error) {
+	env := c.env
+	if !c.awsFiles.Empty() {
+		// Commands that aren't run in a directory of their own get one for
+		// their AWS files.
+		if dir == "" {
+			d, err := createTempDir(c.workingDir, "pcluster")
+			if err != nil {
+				return nil, err
+			}
+			defer os.RemoveAll(d)
+			dir = d
+		}
+		var err error
+		if env, err = c.awsFiles.Write(dir, env); err != nil {
+			return nil, err
+		}
+	}
+	delay := c.retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		output, err := c.runPcluster(ctx, log, dir, env, args...)
+		if c.metrics != nil {
+			subcommand, region := commandLabels(args, c.defaultRegion)
+			c.metrics.recordCommand(subcommand, region, time.Since(start), err)
+		}
+		if err == nil || !isThrottled(output) {
+			return output, err
+		}
+		if attempt >= c.maxRetries {
+			return output, &pclusterError{kind: ErrThrottled, err: err}
+		}
+		log.Debug("pcluster was throttled, retrying", "command", args[0], "attempt", attempt+1, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return output, &pclusterError{kind: ErrThrottled, err: err}
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// runPcluster runs pcluster in dir with env once.
+func (c *external) runPcluster(ctx context.Context, log logging.Logger, dir string, env []string, args ...string) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if c.cliDebug {
+		args = append(args, "--debug")
+	}
+	cmd := c.executor.CommandContext(ctx, c.binary, args...)
+	cmd.SetEnv(env)
+	cmd.SetDir(dir)
+	// pcluster must never wait for input, e.g. for update-cluster to be
+	// confirmed, as nothing would answer. Prompts read EOF instead.
+	cmd.SetStdin(bytes.NewReader(nil))
+	log.Debug(fmt.Sprintf("executing: %s %s", c.binary, strings.Join(args, " ")))
+	var stdout, stderr bytes.Buffer
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	err := cmd.Run() // blocks
+	if stderr.Len() > 0 {
+		// pcluster logs to stderr, including its debug output.
+		log.Debug("pcluster command stderr", "command", args[0], "output", stderr.String())
+	}
+	// A killed command's output may be cut short, so it isn't returned lest
+	// it be mistaken for the command's result.
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, &pclusterError{kind: ErrInterrupted, err: fmt.Errorf("pcluster %s timed out: %w", args[0], ctx.Err())}
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, &pclusterError{kind: ErrInterrupted, err: fmt.Errorf("pcluster %s was cancelled: %w", args[0], ctx.Err())}
+	}
+	output := commandOutput(stdout.Bytes(), stderr.Bytes(), err)
+	if err != nil {
+		log.Info("pcluster command failed", "command", args[0], "exitCode", exitCode(err), "output", truncate(output, maxLoggedOutput))
+		log.Debug("pcluster command output", "command", args[0], "output", string(output))
+	}
+	return output, err
+}
+
+// commandOutput returns the output of a pcluster command, which is its stdout:
+// its JSON result, or the JSON error it failed with. The stderr of a command
+// that failed without reporting an error, e.g. with a traceback, is returned
+// instead, as it explains why.
+func commandOutput(stdout, stderr []byte, err error) []byte {
+	if err == nil || len(stderr) == 0 {
+		return stdout
+	}
+	if _, ok := parseErrorOutput(stdout); ok {
+		return stdout
+	}
+	return stderr
+}
+
+// clusterConfiguration returns the configuration pcluster is given for the
+// cluster: its resolved configuration, with the overrides from its spec.
+func (c *external) clusterConfiguration(ctx context.Context, cr *v1alpha1.Cluster) (string, error) {
+	config, err := c.resolveClusterConfiguration(ctx, cr)
+	if err != nil {
+		return "", err
+	}
+	return applyOverrides(config, cr)
+}
+
+// applyOverrides returns the resolved cluster configuration with the overrides
+// from the cluster's spec.
+func applyOverrides(config string, cr *v1alpha1.Cluster) (string, error) {
+	if cr.Spec.ForProvider.ClusterConfigurationURL != "" {
+		return config, nil
+	}
+	if err := validateYAML(config); err != nil {
+		return "", err
+	}
+	if err := validateSlurmAccounting(config); err != nil {
+		return "", err
+	}
+	config, err := applySubnetOverrides(config, cr.Spec.ForProvider)
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to set PATH: %w", err)
+		return "", err
 	}
-	cmd := c.executor.CommandContext(ctx, "pcluster", args...)
-	cmd.SetEnv(c.env)
-	cmd.SetDir(c.dir)
-	c.logger.Debug(fmt.Sprintf("executing: pcluster %s", strings.Join(args, " ")))
-	return cmd.CombinedOutput() // blocks
+	return applyRetainSharedStorage(config, cr.Spec.ForProvider.RetainSharedStorage)
 }
 
 // set up things that the pcluster cli needs. e.g. directory, configuration file, env vars, etc.
 // If the command exits with non-zero status, error is returned and []byte contains error message from stderr.
-func (c *external) execute(ctx context.Context, cr *v1alpha1.Cluster, args []string) ([]byte, error) {
-	dir, err := createTempDir(cr.Name)
+func (c *external) execute(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster, args []string) ([]byte, error) {
+	dir, err := createTempDir(c.workingDir, cr.Name)
 	if err != nil {
 		return []byte{}, err
 	}
 	defer os.RemoveAll(dir)
 
-	c.dir = dir
-	err = writeConfigToFile(cr.Spec.ForProvider.ClusterConfiguration, fmt.Sprintf("%s/%s", dir, clusterConfigFileName))
+	config, err := c.clusterConfiguration(ctx, cr)
 	if err != nil {
 		return []byte{}, err
 	}
-	return c.execPcluster(ctx, cr, args...)
+	if cr.Spec.ForProvider.ClusterConfigurationURL == "" {
+		err = writeConfigToFile(config, filepath.Join(dir, configFileName(cr)))
+		if err != nil {
+			return []byte{}, err
+		}
+	}
+	return c.execPcluster(ctx, log, dir, args...)
+}
+
+// resolveClusterConfiguration returns the cluster configuration, rendered with
+// the cluster's configuration values if it has any.
+func (c *external) resolveClusterConfiguration(ctx context.Context, cr *v1alpha1.Cluster) (string, error) {
+	config, err := c.configurationSource(ctx, cr)
+	if err != nil {
+		return "", err
+	}
+	return c.renderConfiguration(ctx, cr, config)
+}
+
+// configurationSource returns the cluster configuration from whichever of the
+// inline configuration, the referenced ConfigMap, or the referenced Secret is
+// set.
+func (c *external) configurationSource(ctx context.Context, cr *v1alpha1.Cluster) (string, error) {
+	p := cr.Spec.ForProvider
+	sources := 0
+	for _, set := range []bool{p.ClusterConfiguration != "", p.ClusterConfigurationRef != nil, p.ClusterConfigurationSecretRef != nil, p.ClusterConfigurationURL != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", errors.New(errConfigSource)
+	}
+
+	switch {
+	case p.ClusterConfigurationURL != "":
+		// pcluster downloads the configuration itself.
+		return "", nil
+	case p.ClusterConfigurationRef != nil:
+		ref := p.ClusterConfigurationRef
+		cm := &corev1.ConfigMap{}
+		if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, cm); err != nil {
+			return "", errors.Wrap(err, errGetConfigMap)
+		}
+		config, ok := cm.Data[ref.Key]
+		if !ok {
+			return "", errors.Errorf("%s: key %q not found in %s/%s", errGetConfigMap, ref.Key, ref.Namespace, ref.Name)
+		}
+		return config, nil
+	case p.ClusterConfigurationSecretRef != nil:
+		ref := p.ClusterConfigurationSecretRef
+		s := &corev1.Secret{}
+		if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+			return "", errors.Wrap(err, errGetSecret)
+		}
+		config, ok := s.Data[ref.Key]
+		if !ok {
+			return "", errors.Errorf("%s: key %q not found in %s/%s", errGetSecret, ref.Key, ref.Namespace, ref.Name)
+		}
+		return string(config), nil
+	default:
+		return p.ClusterConfiguration, nil
+	}
 }
 
-func (c *external) isUpToDate(ctx context.Context, cr *v1alpha1.Cluster) (bool, error) {
-	args := []string{
-		"update-cluster",
-		"--dryrun", // this means pcluster exit status is always non-zero
-		"true",
-		"--cluster-name",
-		cr.Name,
-		"--cluster-configuration",
-		clusterConfigFileName,
+// isUpToDate returns whether the cluster is up to date, reusing the result of
+// an earlier dry-run update if nothing it depends on has changed since.
+func (c *external) isUpToDate(ctx context.Context, obs *observation) (bool, error) {
+	log, cr := obs.log, obs.cr
+	config, err := obs.configuration(ctx)
+	if err != nil {
+		return false, err
+	}
+	if config, err = applyOverrides(config, cr); err != nil {
+		return false, err
+	}
+	cr.Status.AtProvider.ConfigHash = configHash(config)
+	if u := cr.Spec.ForProvider.ClusterConfigurationURL; u != "" {
+		// Only pcluster sees the configuration, so changing it without
+		// changing its URL goes unnoticed until the dry-run is repeated.
+		cr.Status.AtProvider.ConfigHash = configHash(u)
+	}
+	if c.dryRuns == nil {
+		return c.dryRunUpdate(ctx, log, cr)
 	}
-	output, err := c.execute(ctx, cr, args)
+	observed, err := obs.describe(ctx)
+	if err != nil {
+		return false, err
+	}
+	key := dryRunKey(cr, cr.Status.AtProvider.ConfigHash, observed)
+	if upToDate, ok := c.dryRuns.get(cr.Name, key); ok {
+		log.Debug("reusing dry-run result", "upToDate", upToDate)
+		return upToDate, nil
+	}
+	upToDate, err := c.dryRunUpdate(ctx, log, cr)
+	if err != nil {
+		return false, err
+	}
+	c.dryRuns.set(cr.Name, key, upToDate)
+	return upToDate, nil
+}
+
+// dryRunUpdate returns whether the cluster is up to date using a dry-run
+// update, and records the changes an update would make.
+func (c *external) dryRunUpdate(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) (bool, error) {
+	// A dry-run's exit status is always non-zero.
+	args := c.pclusterArgs("update-cluster", cr,
+		withConfiguration(),
+		withSuppressValidators(),
+		withValidationFailureLevel(),
+		withForceUpdate(),
+		withDryRun(true),
+		withExtraArgs(),
+	)
+	output, err := c.execute(ctx, log, cr, args)
 	if err != nil && len(output) > 0 {
 		status, sErr := getErrorStatus(output, cr.Name)
 		if sErr != nil {
 			return false, sErr
 		}
-		if status == errStatusUpToDate {
+		switch status {
+		case errStatusUpToDate:
+			cr.Status.AtProvider.UpdateChangeSet = nil
+			cr.Status.AtProvider.PendingChanges = nil
 			return true, nil
+		case errStatusNotUpToDate:
+			cr.Status.AtProvider.UpdateChangeSet = getChangeSet(output)
+			cr.Status.AtProvider.PendingChanges = cr.Status.AtProvider.UpdateChangeSet
 		}
 		return false, nil
 	}
-	c.logger.Debug("dryrun operation ended with exit code 0")
+	log.Debug("dryrun operation ended with exit code 0")
 	return false, err
 }
 
@@ -237,28 +746,85 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 	if !ok {
 		return managed.ExternalObservation{}, errors.New(errNotCluster)
 	}
-	output, err := c.execPcluster(ctx, cr, "describe-cluster", "--cluster-name", cr.Name)
-	if err != nil {
-		status, _ := getErrorStatus(output, cr.Name)
-		if status == errStatusNotFound {
-			return managed.ExternalObservation{ResourceExists: false}, nil
+	log := c.clusterLogger(cr)
+	if o := cr.Spec.ForProvider.PollIntervalOverride; o != nil && o.Duration <= 0 {
+		return managed.ExternalObservation{}, errors.New(errPollInterval)
+	}
+	if isValidateOnly(cr) {
+		return c.validateOnly(ctx, log, cr)
+	}
+	// Deleting an ObserveOnly Cluster, or any Cluster in preview, leaves the
+	// cluster be, so it is reported as gone for the Cluster to be finalized.
+	if meta.WasDeleted(cr) && isObserveOnly(cr) {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+	if meta.WasDeleted(cr) && c.preview {
+		c.recorder.Event(cr, event.Normal(reasonDryRun, "Would delete cluster"))
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+	obs := c.newObservation(log, cr)
+	describeOutput, err := obs.describe(ctx)
+	if errors.Is(err, ErrClusterNotFound) {
+		if c.dryRuns != nil {
+			c.dryRuns.forget(cr.Name)
+		}
+		if c.fleets != nil {
+			c.fleets.forget(cr.Name)
 		}
-		return managed.ExternalObservation{}, fmt.Errorf("failed to run pcluster command: %s %w", output, err)
+		if isObserveOnly(cr) {
+			return managed.ExternalObservation{}, errors.New(errObserveOnly)
+		}
+		// Create is about to run, so it needs to know how it last failed.
+		restoreCreateFailure(cr)
+		return managed.ExternalObservation{ResourceExists: false}, nil
 	}
-	var describeOutput DescribeClusterOutput
-	err = json.Unmarshal(output, &describeOutput.OutputCluster) // TODO avoid double unmarshal
-	err = json.Unmarshal(output, &describeOutput)
 	if err != nil {
-		return managed.ExternalObservation{}, fmt.Errorf("failed to unmarshal describe response: %w", err)
+		return managed.ExternalObservation{}, err
 	}
+	// The cluster has since been created.
+	createFailureCleared := clearCreateFailure(cr)
+	recordCreated(cr)
 
-	isUpToDate, err := c.isUpToDate(ctx, cr)
-	if err != nil {
-		return managed.ExternalObservation{}, fmt.Errorf("could not determine if resource is up-to-date: %w", err)
+	// A dry-run can't be done while an operation is in progress, so the
+	// cluster is still converging until it finishes.
+	isUpToDate := false
+	if !isInProgress(describeOutput.ClusterStatus) {
+		isUpToDate, err = c.isUpToDate(ctx, obs)
+		if err != nil {
+			return managed.ExternalObservation{}, fmt.Errorf("could not determine if resource is up-to-date: %w", err)
+		}
+	}
+
+	// Tags are only compared once the configuration is up to date, as a full
+	// update applies them too.
+	cr.Status.AtProvider.OutdatedTags = nil
+	if isUpToDate {
+		cr.Status.AtProvider.OutdatedTags = outdatedTags(cr.Spec.ForProvider.Tags, describeOutput.Tags)
+		isUpToDate = len(cr.Status.AtProvider.OutdatedTags) == 0
 	}
 
 	eo := managed.ExternalObservation{
-		ResourceUpToDate: isUpToDate,
+		ResourceUpToDate:  isUpToDate && fleetUpToDate(cr.Spec.ForProvider.ComputeFleetState, describeOutput.ComputeFleetStatus),
+		ConnectionDetails: headNodeConnectionDetails(describeOutput.HeadNode),
+	}
+	if isForceReconcileRequested(cr) && !isInProgress(describeOutput.ClusterStatus) {
+		eo.ResourceUpToDate = false
+	}
+	if isObserveOnly(cr) {
+		eo.ResourceUpToDate = true
+	}
+	// Late initialization is what persists the removed annotation.
+	eo.ResourceLateInitialized = lateInitialize(&cr.Spec.ForProvider, c.defaultRegion) || createFailureCleared
+	if isImport(cr) {
+		if cr.Status.AtProvider.ImportedConfiguration == "" {
+			config, err := c.fetch(ctx, describeOutput.ClusterConfiguration.URL)
+			if err != nil {
+				return managed.ExternalObservation{}, fmt.Errorf("failed to fetch imported cluster configuration: %w", err)
+			}
+			cr.Status.AtProvider.ImportedConfiguration = string(config)
+		}
+		// Leave the cluster untouched until the annotation is removed.
+		eo.ResourceUpToDate = true
 	}
 	switch describeOutput.ClusterStatus {
 	case CreateInProgress, UpdateInProgress, DeleteInProgress:
@@ -266,42 +832,405 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 	case CreateComplete, UpdateComplete:
 		eo.ResourceExists = true
 		cr.SetConditions(xpv1.Available())
-	case CreateFailed, DeleteComplete:
+		c.recordRegionClusters(ctx, log, cr)
+	case CreateFailed:
+		// The failed stack remains, so the cluster can't be created again
+		// until it is deleted. Updating it would also fail.
+		eo.ResourceExists = true
+		eo.ResourceUpToDate = true
+	case DeleteComplete:
 		eo.ResourceExists = false
 	case UpdateFailed, DeleteFailed:
 		eo.ResourceExists = true
 		cr.SetConditions(xpv1.Unavailable())
 	}
-	setStatus(describeOutput.OutputCluster, cr)
+	switch describeOutput.ClusterStatus {
+	case CreateInProgress, UpdateInProgress:
+		c.setProgress(ctx, log, cr)
+	default:
+		cr.Status.AtProvider.Progress = ""
+	}
+	switch describeOutput.ClusterStatus {
+	case CreateFailed, UpdateFailed, DeleteFailed:
+		c.setFailureReason(ctx, obs)
+		msg := cr.Status.AtProvider.FailureReason
+		switch describeOutput.ClusterStatus {
+		case CreateFailed:
+			msg = strings.TrimSuffix(msgCreateFailed+": "+msg, ": ")
+		case DeleteFailed:
+			msg = strings.TrimSuffix(msgDeleteFailed+": "+msg, ": ")
+		}
+		if msg != "" {
+			cr.SetConditions(xpv1.Unavailable().WithMessage(msg))
+		}
+	default:
+		cr.Status.AtProvider.FailureReason = ""
+	}
+	setDescribeStatus(describeOutput, cr)
+	if config, err := obs.configuration(ctx); err != nil {
+		log.Debug("cannot resolve cluster configuration", "error", err)
+	} else {
+		setSchedulerQueues(log, cr, config)
+		setHeadNodeIAM(log, cr, config)
+	}
+	c.recordVersionDrift(log, cr)
+	switch describeOutput.ClusterStatus {
+	case CreateComplete, UpdateComplete:
+		c.setFleetDetails(ctx, log, cr)
+	default:
+		// The compute fleet may not exist yet.
+		cr.Status.AtProvider.ComputeFleetLastUpdatedTime = ""
+	}
+	c.reconcileLogExport(log, cr)
+	c.reconcileLogEvents(ctx, log, cr)
 	return eo, nil
 }
 
+// setSchedulerQueues records the queues of the cluster's scheduler, from its
+// resolved configuration, or the imported configuration of a cluster without
+// one. It is diagnostic only, so failures are just logged.
+func setSchedulerQueues(log logging.Logger, cr *v1alpha1.Cluster, config string) {
+	if config == "" {
+		config = cr.Status.AtProvider.ImportedConfiguration
+	}
+	queues, err := schedulerQueues(config)
+	if err != nil {
+		log.Debug("cannot parse scheduler queues", "error", err)
+		return
+	}
+	cr.Status.AtProvider.Scheduler.Queues = queues
+}
+
+// schedulerQueues returns the Slurm or AWS Batch queues of a cluster
+// configuration.
+func schedulerQueues(config string) ([]v1alpha1.SchedulerQueue, error) {
+	var sc schedulingConfiguration
+	if err := yaml.Unmarshal([]byte(config), &sc); err != nil {
+		return nil, err
+	}
+	var queues []v1alpha1.SchedulerQueue
+	for _, q := range append(sc.Scheduling.SlurmQueues, sc.Scheduling.AwsBatchQueues...) {
+		sq := v1alpha1.SchedulerQueue{Name: q.Name}
+		for _, r := range q.ComputeResources {
+			sq.ComputeResources = append(sq.ComputeResources, r.Name)
+		}
+		queues = append(queues, sq)
+	}
+	return queues, nil
+}
+
+// setHeadNodeIAM records the IAM instance profile or role of the head node,
+// from the cluster's resolved configuration, or the imported configuration of
+// a cluster without one. It is diagnostic only, so failures are just logged.
+func setHeadNodeIAM(log logging.Logger, cr *v1alpha1.Cluster, config string) {
+	if config == "" {
+		config = cr.Status.AtProvider.ImportedConfiguration
+	}
+	var hc headNodeConfiguration
+	if err := yaml.Unmarshal([]byte(config), &hc); err != nil {
+		log.Debug("cannot parse head node IAM configuration", "error", err)
+		return
+	}
+	cr.Status.AtProvider.HeadNode.InstanceProfile = hc.HeadNode.Iam.InstanceProfile
+	cr.Status.AtProvider.HeadNode.InstanceRole = hc.HeadNode.Iam.InstanceRole
+}
+
+// recordVersionDrift emits an event if the cluster was created or last updated
+// by a different pcluster version than the provider's, as updating it may
+// require its configuration to be changed, or the cluster to be rebuilt. The
+// drift is recorded in the Cluster's status, so it is only reported once.
+func (c *external) recordVersionDrift(log logging.Logger, cr *v1alpha1.Cluster) {
+	if c.version == nil || cr.Status.AtProvider.Version == "" {
+		return
+	}
+	observed, err := version.ParseGeneric(cr.Status.AtProvider.Version)
+	if err != nil {
+		log.Debug("cannot parse cluster version", "error", err)
+		return
+	}
+	if observed.String() == c.version.String() {
+		cr.Status.AtProvider.VersionDrift = ""
+		return
+	}
+	drift := fmt.Sprintf("%s to %s", observed, c.version)
+	if cr.Status.AtProvider.VersionDrift == drift {
+		return
+	}
+	cr.Status.AtProvider.VersionDrift = drift
+	c.recorder.Event(cr, event.Warning(reasonVersionDrift, errors.Errorf("cluster version %s differs from pcluster version %s; check the cluster configuration is compatible before updating", observed, c.version)))
+}
+
+// setFailureReason records why the cluster failed, using its CloudFormation
+// stack events. The reason is cached in the Cluster's status until the cluster
+// status changes, so stack events are only read once per failure.
+func (c *external) setFailureReason(ctx context.Context, obs *observation) {
+	log, cr := obs.log, obs.cr
+	observed, err := obs.describe(ctx)
+	if err != nil {
+		log.Debug("cannot describe cluster", "error", err)
+		return
+	}
+	status := observed.ClusterStatus
+	if cr.Status.AtProvider.FailureReason != "" && cr.Status.AtProvider.ClusterStatus == status {
+		return
+	}
+	args := c.pclusterArgs("get-cluster-stack-events", cr)
+	output, err := c.execPcluster(ctx, log, "", args...)
+	if err != nil {
+		log.Debug("cannot get cluster stack events", "error", err, "output", string(output))
+		return
+	}
+	var eventsOutput StackEventsOutput
+	if err := json.Unmarshal(output, &eventsOutput); err != nil {
+		log.Debug("cannot unmarshal cluster stack events", "error", err)
+		return
+	}
+	cr.Status.AtProvider.FailureReason = latestFailureReason(eventsOutput.Events)
+}
+
+// setFleetDetails records when the compute fleet's status last changed and
+// how many instances the cluster has. Those described by a recent poll are
+// reused while the fleet's status, which describe-cluster reports, is
+// unchanged.
+func (c *external) setFleetDetails(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) {
+	status := cr.Status.AtProvider.ComputeFleetStatus
+	if c.fleets != nil {
+		if d, ok := c.fleets.get(cr.Name, status); ok {
+			cr.Status.AtProvider.ComputeFleetLastUpdatedTime = d.lastUpdatedTime
+			cr.Status.AtProvider.ComputeInstanceCount = d.computeInstanceCount
+			cr.Status.AtProvider.InstanceCounts = d.instanceCounts
+			return
+		}
+	}
+	fleet := c.setComputeFleetStatus(ctx, log, cr)
+	counts := c.setInstanceCounts(ctx, log, cr)
+	if c.fleets != nil && fleet && counts {
+		c.fleets.set(cr.Name, fleetDetails{
+			status:               status,
+			lastUpdatedTime:      cr.Status.AtProvider.ComputeFleetLastUpdatedTime,
+			computeInstanceCount: cr.Status.AtProvider.ComputeInstanceCount,
+			instanceCounts:       cr.Status.AtProvider.InstanceCounts,
+		})
+	}
+}
+
+// setComputeFleetStatus records the compute fleet's status, and when it last
+// changed, using describe-compute-fleet. It is diagnostic only, so failures are
+// just logged. It returns true if the status was recorded.
+func (c *external) setComputeFleetStatus(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) bool {
+	args := c.pclusterArgs("describe-compute-fleet", cr)
+	output, err := c.execPcluster(ctx, log, "", args...)
+	if err != nil {
+		log.Debug("cannot describe compute fleet", "error", err, "output", string(output))
+		return false
+	}
+	var fleetOutput DescribeComputeFleetOutput
+	if err := json.Unmarshal(output, &fleetOutput); err != nil {
+		log.Debug("cannot unmarshal compute fleet description", "error", err)
+		return false
+	}
+	cr.Status.AtProvider.ComputeFleetStatus = fleetOutput.Status
+	cr.Status.AtProvider.ComputeFleetLastUpdatedTime = formatTime(fleetOutput.LastStatusUpdatedTime)
+	return true
+}
+
+// describeInstances returns all of the cluster's instances, using
+// describe-cluster-instances.
+func (c *external) describeInstances(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) ([]ClusterInstance, error) {
+	var instances []ClusterInstance
+	token := ""
+	for {
+		args := c.pclusterArgs("describe-cluster-instances", cr)
+		if token != "" {
+			args = append(args, "--next-token", token)
+		}
+		output, err := c.execPcluster(ctx, log, "", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe cluster instances: %s: %w", errorMessage(output), err)
+		}
+		var instancesOutput DescribeClusterInstancesOutput
+		if err := json.Unmarshal(output, &instancesOutput); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal instances output: %w", err)
+		}
+		instances = append(instances, instancesOutput.Instances...)
+		if instancesOutput.NextToken == "" {
+			return instances, nil
+		}
+		token = instancesOutput.NextToken
+	}
+}
+
+// setInstanceCounts records how many instances the cluster has of each node
+// type in each state. It is diagnostic only, so failures are just logged. It
+// returns true if the counts were recorded.
+func (c *external) setInstanceCounts(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) bool {
+	instances, err := c.describeInstances(ctx, log, cr)
+	if err != nil {
+		log.Debug("cannot describe cluster instances", "error", err)
+		return false
+	}
+	cr.Status.AtProvider.ComputeInstanceCount, cr.Status.AtProvider.InstanceCounts = countInstances(instances)
+	return true
+}
+
+// countInstances returns the number of compute instances, and the number of
+// instances of each node type in each state, sorted by node type then state.
+// There are no compute instances while the compute fleet is stopped.
+func countInstances(instances []ClusterInstance) (int, []v1alpha1.InstanceCount) {
+	compute := 0
+	counts := map[v1alpha1.InstanceCount]int{}
+	for _, i := range instances {
+		if i.NodeType == nodeTypeCompute {
+			compute++
+		}
+		counts[v1alpha1.InstanceCount{NodeType: i.NodeType, State: i.State}]++
+	}
+	if len(counts) == 0 {
+		return 0, nil
+	}
+	out := make([]v1alpha1.InstanceCount, 0, len(counts))
+	for k, n := range counts {
+		k.Count = n
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].NodeType != out[j].NodeType {
+			return out[i].NodeType < out[j].NodeType
+		}
+		return out[i].State < out[j].State
+	})
+	return compute, out
+}
+
+// listClusters returns all clusters in the supplied region.
+func (c *external) listClusters(ctx context.Context, log logging.Logger, region string) ([]OutputCluster, error) {
+	var clusters []OutputCluster
+	token := ""
+	for {
+		args := []string{"list-clusters", "--region", region}
+		if token != "" {
+			args = append(args, "--next-token", token)
+		}
+		output, err := c.execPcluster(ctx, log, "", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list clusters: %s: %w", errorMessage(output), err)
+		}
+		var listOutput ListClustersOutput
+		if err := json.Unmarshal(output, &listOutput); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal list output: %w", err)
+		}
+		clusters = append(clusters, listOutput.Clusters...)
+		if listOutput.NextToken == "" {
+			return clusters, nil
+		}
+		token = listOutput.NextToken
+	}
+}
+
+// recordRegionClusters emits an event with the number of clusters in the
+// Cluster's region when it changes. The number is cached, so the region's
+// clusters are only listed once in a while. It is diagnostic only, so failures
+// are just logged.
+func (c *external) recordRegionClusters(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) {
+	k := regionKey{providerConfig: c.providerConfig, region: c.region(cr)}
+	if c.regionClusters != nil && c.regionClusters.fresh(k) {
+		return
+	}
+	clusters, err := c.listClusters(ctx, log, k.region)
+	if err != nil {
+		log.Debug("cannot list clusters", "error", err)
+		return
+	}
+	if c.regionClusters != nil && !c.regionClusters.set(k, len(clusters)) {
+		return
+	}
+	c.recorder.Event(cr, event.Normal(reasonListClusters, fmt.Sprintf("Found %d clusters in region %s", len(clusters), c.region(cr))))
+}
+
 func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
 	cr, ok := mg.(*v1alpha1.Cluster)
 	if !ok {
 		return managed.ExternalCreation{}, errors.New(errNotCluster)
 	}
+	log := c.clusterLogger(cr)
+	if isObserveOnly(cr) {
+		return managed.ExternalCreation{}, nil
+	}
+
+	if err := validateClusterName(cr.Name); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := validateRegion(c.region(cr)); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := checkRegionAllowed(c.region(cr), c.allowedRegions); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := validateArgs(cr.Spec.ForProvider); err != nil {
+		return managed.ExternalCreation{}, err
+	}
 
-	fmt.Printf("Creating: %+v", cr)
-	args := []string{
-		"create-cluster",
-		"--cluster-configuration",
-		clusterConfigFileName,
-		"--cluster-name",
-		cr.Name,
-		"--region",
-		cr.Spec.ForProvider.Region,
+	if err := notRetriedError(cr, "create-cluster"); err != nil && !c.preview {
+		return managed.ExternalCreation{}, err
 	}
-	output, err := c.execute(ctx, cr, args)
-	if err != nil {
+
+	// Problems with the configuration, such as its Slurm accounting, are
+	// reported before anything is created.
+	if _, err := c.clusterConfiguration(ctx, cr); err != nil {
 		return managed.ExternalCreation{}, err
 	}
+
+	// A cluster Observe didn't find may have been created since, e.g. by
+	// another Cluster with the same name. pcluster would only report a
+	// confusing failure to create it again. A dry-run needs no such check.
+	if !c.preview {
+		if err := c.checkNotExists(ctx, log, cr); err != nil {
+			return managed.ExternalCreation{}, err
+		}
+	}
+
+	if err := c.preflight(ctx, log, cr); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	log.Debug("creating cluster")
+	args := c.pclusterArgs("create-cluster", cr,
+		withConfiguration(),
+		withTags(),
+		withSuppressValidators(),
+		withValidationFailureLevel(),
+		withRollbackOnFailure(),
+		withDryRun(c.preview),
+		withExtraArgs(),
+	)
+	output, err := c.execute(ctx, log, cr, args)
+	if c.preview && isDryRunSuccess(output, err, cr.Name) {
+		c.recorder.Event(cr, event.Normal(reasonDryRun, "Would create cluster"))
+		return managed.ExternalCreation{}, nil
+	}
+	if errors.Is(err, ErrInterrupted) {
+		return managed.ExternalCreation{}, fmt.Errorf("failed to create using pcluster cli: %w", err)
+	}
+	if err != nil {
+		recordCommandFailure(cr, "create-cluster", output, err)
+		persistCreateFailure(cr)
+		c.recorder.Event(cr, event.Warning(reasonCreateFailed, errors.New(errorMessage(output))))
+		if vErr := c.validationError(cr, output); vErr != nil {
+			return managed.ExternalCreation{}, vErr
+		}
+		return managed.ExternalCreation{}, fmt.Errorf("failed to create using pcluster cli: %s: %w", errorMessage(output), err)
+	}
 	var createOutput CreateClusterOutput
 	err = json.Unmarshal(output, &createOutput)
 	if err != nil {
 		return managed.ExternalCreation{}, fmt.Errorf("failed to unmarshal create output: %w", err)
 	}
+	c.recordValidationWarnings(cr, createOutput.ValidationMessages)
 	setStatus(createOutput.Cluster, cr)
+	cr.Status.AtProvider.LastCommandFailure = nil
+	if c.wait {
+		c.waitForCompletion(ctx, log, cr, cr.Status.AtProvider.ClusterStatus)
+	}
 
 	return managed.ExternalCreation{
 		// Optionally return any details that may be required to connect to the
@@ -310,32 +1239,131 @@ func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 	}, nil
 }
 
+// checkNotExists returns an error if the cluster already exists.
+func (c *external) checkNotExists(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) error {
+	existing, err := c.newObservation(log, cr).describe(ctx)
+	if errors.Is(err, ErrClusterNotFound) || (err == nil && existing.ClusterStatus == DeleteComplete) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return errors.Errorf("%s in %s with status %s; annotate the Cluster with %s: \"true\" to adopt it", errExists, c.region(cr), existing.ClusterStatus, annotationImport)
+}
+
 func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
 	cr, ok := mg.(*v1alpha1.Cluster)
 	if !ok {
 		return managed.ExternalUpdate{}, errors.New(errNotCluster)
 	}
+	log := c.clusterLogger(cr)
+	if isObserveOnly(cr) {
+		return managed.ExternalUpdate{}, nil
+	}
 
-	fmt.Printf("Updating: %+v", cr)
-	args := []string{
-		"update-cluster",
-		"--cluster-configuration",
-		clusterConfigFileName,
-		"--cluster-name",
-		cr.Name,
-		"--region",
-		cr.Spec.ForProvider.Region,
+	// Observe reports an in-progress cluster as not up to date, but it can't
+	// be updated until the operation finishes.
+	if isInProgress(cr.Status.AtProvider.ClusterStatus) {
+		log.Debug("waiting for the operation in progress to finish", "status", cr.Status.AtProvider.ClusterStatus)
+		return managed.ExternalUpdate{}, nil
 	}
-	output, err := c.execute(ctx, cr, args)
-	if err != nil {
+
+	// Likewise a compute fleet that is starting or stopping can't be changed
+	// until it settles.
+	if isFleetConverging(cr.Spec.ForProvider.ComputeFleetState, cr.Status.AtProvider.ComputeFleetStatus) {
+		log.Debug("waiting for the compute fleet to settle", "status", cr.Status.AtProvider.ComputeFleetStatus)
+		return managed.ExternalUpdate{}, nil
+	}
+
+	switch {
+	case !fleetNeedsUpdate(cr.Spec.ForProvider.ComputeFleetState, cr.Status.AtProvider.ComputeFleetStatus):
+	case c.preview:
+		c.recorder.Event(cr, event.Normal(reasonDryRun, fmt.Sprintf("Would change compute fleet status to %s", cr.Spec.ForProvider.ComputeFleetState)))
+	default:
+		if err := c.updateComputeFleet(ctx, log, cr); err != nil {
+			return managed.ExternalUpdate{}, err
+		}
+	}
+
+	if err := validateRegion(c.region(cr)); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if err := validateArgs(cr.Spec.ForProvider); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	// Observe's dry-run recorded the changes the update would apply.
+	if d := disruptiveChanges(cr.Status.AtProvider.UpdateChangeSet, cr.Spec.ForProvider.AllowDisruptiveChanges); len(d) > 0 && !c.preview {
+		err := errors.Errorf("%s: %s", errDisruptiveChanges, strings.Join(d, ", "))
+		c.recorder.Event(cr, event.Warning(reasonDisruptiveChanges, err))
+		return managed.ExternalUpdate{}, err
+	}
+
+	if err := notRetriedError(cr, "update-cluster"); err != nil && !c.preview {
 		return managed.ExternalUpdate{}, err
 	}
+
+	opts := []argOption{
+		withConfiguration(),
+		withTags(),
+		withSuppressValidators(),
+		withValidationFailureLevel(),
+		withForceUpdate(),
+		withDryRun(c.preview),
+		withExtraArgs(),
+	}
+	reason, msg := reasonUpdateCluster, "Updated cluster"
+	if isTagOnlyUpdate(cr) {
+		tags := strings.Join(cr.Status.AtProvider.OutdatedTags, ", ")
+		opts = tagUpdateArgs(c.preview)
+		reason, msg = reasonUpdateTags, "Updated cluster tags "+tags
+		log.Debug("updating cluster tags", "tags", tags)
+	} else {
+		log.Debug("updating cluster")
+	}
+	args := c.pclusterArgs("update-cluster", cr, opts...)
+	output, err := c.execute(ctx, log, cr, args)
+	if c.preview && isDryRunSuccess(output, err, cr.Name) {
+		changes := getChangeSet(output)
+		cr.Status.AtProvider.UpdateChangeSet = changes
+		c.recorder.Event(cr, event.Normal(reasonDryRun, fmt.Sprintf("Would update cluster with %d changes", len(changes))))
+		setForceReconcileHandled(cr)
+		return managed.ExternalUpdate{}, nil
+	}
+	if errors.Is(err, ErrInterrupted) {
+		return managed.ExternalUpdate{}, fmt.Errorf("failed to update using pcluster cli: %w", err)
+	}
+	if err != nil {
+		// The update may only have been needed for the compute fleet, or may
+		// have to wait for an operation already in progress.
+		status, _ := getErrorStatus(output, cr.Name)
+		if status == errStatusUpToDate {
+			setForceReconcileHandled(cr)
+			return managed.ExternalUpdate{}, nil
+		}
+		if errors.Is(classifyError(output, cr.Name, err), ErrUpdateInProgress) {
+			return managed.ExternalUpdate{}, nil
+		}
+		recordCommandFailure(cr, "update-cluster", output, err)
+		c.recorder.Event(cr, event.Warning(reasonUpdateFailed, errors.New(errorMessage(output))))
+		if vErr := c.validationError(cr, output); vErr != nil {
+			return managed.ExternalUpdate{}, vErr
+		}
+		return managed.ExternalUpdate{}, fmt.Errorf("failed to update using pcluster cli: %s: %w", errorMessage(output), classifyError(output, cr.Name, err))
+	}
 	var updateOutput UpdateClusterOutput
 	err = json.Unmarshal(output, &updateOutput)
 	if err != nil {
 		return managed.ExternalUpdate{}, fmt.Errorf("failed to unmarshal update output: %w", err)
 	}
-	c.logger.Debug(fmt.Sprintf("updated to reflect %d changes", len(updateOutput.ChangeSet)))
+	log.Debug(fmt.Sprintf("updated to reflect %d changes", len(updateOutput.ChangeSet)))
+	c.recorder.Event(cr, event.Normal(reason, msg))
+	cr.Status.AtProvider.LastCommandFailure = nil
+	recordOperation(cr, "update-cluster", len(updateOutput.ChangeSet))
+	setForceReconcileHandled(cr)
+	if c.wait {
+		cr.Status.AtProvider.ClusterStatus = c.waitForCompletion(ctx, log, cr, UpdateInProgress)
+	}
 	return managed.ExternalUpdate{
 		// Optionally return any details that may be required to connect to the
 		// external resource. These will be stored as the connection secret.
@@ -343,63 +1371,116 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 	}, nil
 }
 
+// updateComputeFleet requests the compute fleet be started or stopped to match
+// the desired state.
+func (c *external) updateComputeFleet(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) error {
+	status := FleetStartRequested
+	if cr.Spec.ForProvider.ComputeFleetState == FleetStopped {
+		status = FleetStopRequested
+	}
+	args := c.pclusterArgs("update-compute-fleet", cr, withArgs("--status", status))
+	output, err := c.execPcluster(ctx, log, "", args...)
+	if err != nil {
+		return fmt.Errorf("failed to update compute fleet: %s: %w", errorMessage(output), classifyError(output, cr.Name, err))
+	}
+	var fleetOutput UpdateComputeFleetOutput
+	if err := json.Unmarshal(output, &fleetOutput); err != nil {
+		return fmt.Errorf("failed to unmarshal update compute fleet output: %w", err)
+	}
+	cr.Status.AtProvider.ComputeFleetStatus = fleetOutput.Status
+	recordOperation(cr, "update-compute-fleet", 0)
+	return nil
+}
+
 func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
 	cr, ok := mg.(*v1alpha1.Cluster)
 	if !ok {
 		return errors.New(errNotCluster)
 	}
+	log := c.clusterLogger(cr)
+	if isObserveOnly(cr) || isValidateOnly(cr) {
+		return nil
+	}
 
-	fmt.Printf("Deleting: %+v", cr)
-	args := []string{
-		"delete-cluster",
-		"--cluster-name",
-		cr.Name,
-		"--region",
-		cr.Spec.ForProvider.Region,
+	if cr.GetAnnotations()[annotationDeletionProtection] == "true" {
+		err := errors.New(errProtected)
+		c.recorder.Event(cr, event.Warning(reasonProtected, err))
+		return err
 	}
-	output, err := c.execute(ctx, cr, args)
-	if err != nil {
+
+	// pcluster can't preview a deletion.
+	if c.preview {
+		c.recorder.Event(cr, event.Normal(reasonDryRun, "Would delete cluster"))
+		return nil
+	}
+
+	// Observe records the cluster status before Delete is called.
+	if PClusterStatus(cr.Status.AtProvider.ClusterStatus) == DeleteFailed && cr.GetAnnotations()[annotationRetryDelete] != "true" {
+		err := errors.New(errDeleteFailed)
+		if reason := cr.Status.AtProvider.FailureReason; reason != "" {
+			err = errors.Wrap(errors.New(reason), errDeleteFailed)
+		}
+		c.recorder.Event(cr, event.Warning(reasonDeleteFailed, err))
+		return err
+	}
+
+	log.Debug("deleting cluster")
+	args := c.pclusterArgs("delete-cluster", cr)
+	// The configuration isn't needed, so a malformed one can't block deletion.
+	output, err := c.execPcluster(ctx, log, "", args...)
+	if errors.Is(err, ErrInterrupted) {
 		return fmt.Errorf("failed to delete using pcluster cli: %w", err)
 	}
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonDeleteFailed, errors.New(errorMessage(output))))
+		return fmt.Errorf("failed to delete using pcluster cli: %s: %w", errorMessage(output), classifyError(output, cr.Name, err))
+	}
 
 	var deleteOutput DeleteClusterOutput
 	err = json.Unmarshal(output, &deleteOutput)
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal update output: %w", err)
 	}
-	c.logger.Debug(fmt.Sprintf("deleted %s. response: %s", cr.Name, output))
+	log.Debug(fmt.Sprintf("deleted %s. response: %s", cr.Name, output))
+	recordOperation(cr, "delete-cluster", 0)
+	if c.wait {
+		cr.Status.AtProvider.ClusterStatus = c.waitForCompletion(ctx, log, cr, DeleteInProgress)
+	}
 
 	return nil
 }
 
-func getVEnvPath() (string, error) {
-	vEnvPath, ok := os.LookupEnv(virtualEnvPath)
-	if !ok {
-		return "", nil
+// exitCode returns the exit code of a failed command, or -1 if it did not exit.
+func exitCode(err error) int {
+	var ee k8sexec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitStatus()
 	}
+	return -1
+}
 
-	_, err := os.Stat(fmt.Sprintf("%s/bin/pcluster", vEnvPath))
-	if err != nil {
-		return "", fmt.Errorf("pcluster file not found: %w", err)
+// truncate returns at most n bytes of b, marking where it was cut.
+func truncate(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
 	}
-	//cmd.Dir = vEnvPath
-	virtEnvPath := fmt.Sprintf("%s/bin:%s", vEnvPath, os.Getenv("PATH"))
-	return virtEnvPath, nil
+	return string(b[:n]) + "...(truncated)"
 }
 
 func getErrorStatus(cmdOutput []byte, clusterName string) (errStatus, error) {
-	var pErr errorOutput
-	err := json.Unmarshal(cmdOutput, &pErr)
-	if err != nil {
-		return "", err
+	pErr, ok := parseErrorOutput(cmdOutput)
+	if !ok {
+		// pcluster failed without reporting why, e.g. with a traceback.
+		return errStatusEmpty, errors.Errorf("%s: %s", errUnexpectedOutput, errorMessage(cmdOutput))
 	}
 	msg := pErr.Message
-	// exact match may become problematic later.
 	switch {
-	case strings.HasPrefix(msg, fmt.Sprintf("Cluster '%s' does not exist", clusterName)):
+	case isNotFound(msg, clusterName):
 		return errStatusNotFound, nil
-	case msg == errPclusterCliNoChange, strings.HasPrefix(msg, errPClusterCliInProgress):
+	case msg == errPclusterCliNoChange:
 		return errStatusUpToDate, nil
+	case strings.HasPrefix(msg, errPClusterCliInProgress):
+		return errStatusInProgress, nil
 	case msg == errPClusterCliDryRun:
 		return errStatusNotUpToDate, nil
 	default:
@@ -407,14 +1488,549 @@ func getErrorStatus(cmdOutput []byte, clusterName string) (errStatus, error) {
 	}
 }
 
-func createTempDir(prefix string) (string, error) {
-	dir, err := os.MkdirTemp("", prefix)
+// isThrottled returns true if the output of a failed command shows AWS
+// throttled it.
+func isThrottled(cmdOutput []byte) bool {
+	for _, msg := range []string{"Throttling", "Rate exceeded", "TooManyRequestsException"} {
+		if bytes.Contains(cmdOutput, []byte(msg)) {
+			return true
+		}
+	}
+	return false
+}
+
+// headNodeConnectionDetails returns the details needed to connect to the head
+// node. Only known values are returned to avoid churning the connection secret
+// while the head node is launching.
+func headNodeConnectionDetails(h HeadNode) managed.ConnectionDetails {
+	cd := managed.ConnectionDetails{}
+	for k, v := range map[string]string{
+		keyHeadNodePublicIP:   h.PublicIPAddress,
+		keyHeadNodePrivateIP:  h.PrivateIPAddress,
+		keyHeadNodeInstanceID: h.InstanceID,
+	} {
+		if v != "" {
+			cd[k] = []byte(v)
+		}
+	}
+	return cd
+}
+
+// isObserveOnly returns true if the provider may only observe the cluster.
+func isObserveOnly(cr *v1alpha1.Cluster) bool {
+	return cr.Spec.ManagementPolicy == v1alpha1.ManagementObserveOnly
+}
+
+// isDryRunSuccess returns true if a command run with --dryrun would have
+// succeeded. pcluster reports this as a failure with a message saying so.
+func isDryRunSuccess(cmdOutput []byte, err error, clusterName string) bool {
+	if err == nil {
+		return true
+	}
+	status, _ := getErrorStatus(cmdOutput, clusterName)
+	return status == errStatusNotUpToDate
+}
+
+// isInProgress returns true if a CloudFormation operation on the cluster is in
+// progress.
+func isInProgress(status PClusterStatus) bool {
+	switch status {
+	case CreateInProgress, UpdateInProgress, DeleteInProgress:
+		return true
+	}
+	return false
+}
+
+// isImport returns true if the Cluster is adopting an existing cluster.
+func isImport(cr *v1alpha1.Cluster) bool {
+	return cr.GetAnnotations()[annotationImport] == "true"
+}
+
+// isForceReconcileRequested returns true if the cluster's force-reconcile
+// annotation has changed since it was last handled.
+func isForceReconcileRequested(cr *v1alpha1.Cluster) bool {
+	v, ok := cr.GetAnnotations()[annotationForceReconcile]
+	return ok && v != cr.Status.AtProvider.ForceReconcileHandled
+}
+
+// setForceReconcileHandled records that the cluster's force-reconcile
+// annotation has been handled, so it only forces one update.
+func setForceReconcileHandled(cr *v1alpha1.Cluster) {
+	if v, ok := cr.GetAnnotations()[annotationForceReconcile]; ok {
+		cr.Status.AtProvider.ForceReconcileHandled = v
+	}
+}
+
+// fetchURL returns the body of the supplied URL.
+func fetchURL(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fleetEndState returns the stable state the compute fleet is in or heading
+// for, and whether it has reached it. AWS Batch fleets report ENABLED and
+// DISABLED, which are treated as converging on RUNNING and STOPPED.
+func fleetEndState(observed FleetStatus) (FleetStatus, bool) {
+	switch observed {
+	case FleetRunning, FleetStopped:
+		return observed, true
+	case FleetStartRequested, FleetStarting, FleetEnabled:
+		return FleetRunning, false
+	case FleetStopRequested, FleetStopping, FleetDisabled:
+		return FleetStopped, false
+	default:
+		return "", false
+	}
+}
+
+// fleetUpToDate returns true if the compute fleet is in, or converging on, the
+// desired state. A fleet whose status is unknown is considered up to date, as
+// nothing can be done about it.
+func fleetUpToDate(desired string, observed FleetStatus) bool {
+	end, _ := fleetEndState(observed)
+	return desired == "" || end == "" || end == desired
+}
+
+// isFleetConverging returns true if the compute fleet's state is managed and
+// the fleet is starting or stopping.
+func isFleetConverging(desired string, observed FleetStatus) bool {
+	end, stable := fleetEndState(observed)
+	return desired != "" && end != "" && !stable
+}
+
+// fleetNeedsUpdate returns true if the compute fleet must be started or stopped
+// to reach the desired state. Only stable states are acted on, so a fleet that
+// is already starting or stopping is left to converge.
+func fleetNeedsUpdate(desired string, observed FleetStatus) bool {
+	end, stable := fleetEndState(observed)
+	return desired != "" && stable && end != desired
+}
+
+// setProgress records how many of the cluster's CloudFormation resources the
+// operation in progress has completed, using the stack events since it
+// started. It is diagnostic only, so failures are just logged.
+func (c *external) setProgress(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) {
+	var events []StackEvent
+	token := ""
+	for page := 0; page < maxStackEventPages; page++ {
+		args := c.pclusterArgs("get-cluster-stack-events", cr)
+		if token != "" {
+			args = append(args, "--next-token", token)
+		}
+		output, err := c.execPcluster(ctx, log, "", args...)
+		if err != nil {
+			log.Debug("cannot get cluster stack events", "error", err, "output", string(output))
+			return
+		}
+		var eventsOutput StackEventsOutput
+		if err := json.Unmarshal(output, &eventsOutput); err != nil {
+			log.Debug("cannot unmarshal cluster stack events", "error", err)
+			return
+		}
+		events = append(events, eventsOutput.Events...)
+		// Events are listed newest first, so the operation's events have all
+		// been read once its first event has.
+		if eventsOutput.NextToken == "" || !operationStart(events, cr.Name).IsZero() {
+			break
+		}
+		token = eventsOutput.NextToken
+	}
+	cr.Status.AtProvider.Progress = stackProgress(events, cr.Name)
+}
+
+// operationStart returns when the latest operation on the named stack
+// started, or the zero time if none of the events are its start.
+func operationStart(events []StackEvent, stack string) time.Time {
+	var start time.Time
+	for _, e := range events {
+		if e.LogicalResourceID == stack && strings.HasSuffix(e.ResourceStatus, "_IN_PROGRESS") && e.Timestamp.After(start) {
+			start = e.Timestamp
+		}
+	}
+	return start
+}
+
+// stackProgress returns how many of the named stack's resources are complete,
+// out of those the latest operation on the stack has started on.
+func stackProgress(events []StackEvent, stack string) string {
+	start := operationStart(events, stack)
+	latest := map[string]StackEvent{}
+	for _, e := range events {
+		if e.LogicalResourceID == stack || e.Timestamp.Before(start) {
+			continue
+		}
+		if l, ok := latest[e.LogicalResourceID]; !ok || e.Timestamp.After(l.Timestamp) {
+			latest[e.LogicalResourceID] = e
+		}
+	}
+	complete := 0
+	for _, e := range latest {
+		if strings.HasSuffix(e.ResourceStatus, "_COMPLETE") {
+			complete++
+		}
+	}
+	return fmt.Sprintf("%d/%d resources", complete, len(latest))
+}
+
+// latestFailureReason returns the reason of the most recent stack event that
+// has one. Reasons CloudFormation gives for failures caused by other resources
+// are skipped, as they don't explain the failure.
+func latestFailureReason(events []StackEvent) string {
+	var latest *StackEvent
+	for i := range events {
+		e := &events[i]
+		if e.ResourceStatusReason == "" || isCascadingFailure(e.ResourceStatusReason) {
+			continue
+		}
+		if latest == nil || e.Timestamp.After(latest.Timestamp) {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s: %s", latest.LogicalResourceID, latest.ResourceStatus, latest.ResourceStatusReason)
+}
+
+// isCascadingFailure returns true if reason describes a failure caused by the
+// failure of another resource.
+func isCascadingFailure(reason string) bool {
+	for _, prefix := range []string{"The following resource(s) failed", "Resource creation cancelled", "Resource update cancelled"} {
+		if strings.HasPrefix(reason, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateYAML returns an error if config is not well-formed YAML. The error
+// includes the line of the syntax error. Whether the configuration is valid is
+// left to pcluster.
+func validateYAML(config string) error {
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &n); err != nil {
+		return errors.Wrap(err, errConfigYAML)
+	}
+	return nil
+}
+
+// checkRegionAllowed returns an error if clusters may not be created in region.
+// Clusters may be created in any region if allowed is empty.
+func checkRegionAllowed(region string, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, r := range allowed {
+		if r == region {
+			return nil
+		}
+	}
+	return errors.Errorf("%s %q: the ProviderConfig only allows %s", errRegionNotAllowed, region, strings.Join(allowed, ", "))
+}
+
+// validateClusterName returns an error if name is not a valid pcluster cluster
+// name.
+func validateClusterName(name string) error {
+	if len(name) > maxClusterNameLength {
+		return errors.Errorf("%s %q: must be at most %d characters", errBadName, name, maxClusterNameLength)
+	}
+	if !clusterNameRegex.MatchString(name) {
+		return errors.Errorf("%s %q: must start with a letter and contain only letters, digits, and hyphens", errBadName, name)
+	}
+	return nil
+}
+
+// validateRegion returns an error if region is not a valid AWS region name.
+func validateRegion(region string) error {
+	if !regionRegex.MatchString(region) {
+		return errors.Errorf("%s %q: must match %s", errBadRegion, region, regionRegex)
+	}
+	return nil
+}
+
+// configFileName returns the name of the file the cluster configuration is
+// written to.
+func configFileName(cr *v1alpha1.Cluster) string {
+	if n := cr.Spec.ForProvider.ClusterConfigurationFileName; n != "" {
+		return n
+	}
+	return clusterConfigFileName
+}
+
+// validateArgs returns an error if the configuration file name or URL,
+// override subnets, or extra arguments are invalid.
+func validateArgs(p v1alpha1.ClusterParameters) error {
+	if err := validateConfigFileName(p.ClusterConfigurationFileName); err != nil {
+		return err
+	}
+	if err := validateConfigURL(p); err != nil {
+		return err
+	}
+	if err := validateSubnets(p); err != nil {
+		return err
+	}
+	return validateExtraArgs(p.ExtraArgs)
+}
+
+// validateConfigFileName returns an error if name is not a plain file name.
+func validateConfigFileName(name string) error {
+	if name != "" && (name != filepath.Base(name) || name == "." || name == "..") {
+		return errors.Errorf("%s %q: must be a file name", errBadFileName, name)
+	}
+	return nil
+}
+
+// validateConfigURL returns an error if the configuration URL is not an https
+// or s3 URL, or is combined with settings that change the configuration, as
+// the provider never sees it.
+func validateConfigURL(p v1alpha1.ClusterParameters) error {
+	if p.ClusterConfigurationURL == "" {
+		return nil
+	}
+	u, err := url.Parse(p.ClusterConfigurationURL)
+	if err != nil {
+		return errors.Wrap(err, errConfigURL)
+	}
+	if (u.Scheme != "https" && u.Scheme != "s3") || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return errors.Errorf("%s %q: must be an https:// or s3:// URL of a file", errConfigURL, p.ClusterConfigurationURL)
+	}
+	if len(p.ConfigValues) > 0 || p.ConfigValuesSecretRef != nil || p.HeadNodeSubnet != "" || len(p.ComputeSubnets) > 0 || p.RetainSharedStorage {
+		return errors.Errorf("%s: configValues, configValuesSecretRef, headNodeSubnet, computeSubnets, and retainSharedStorage need the configuration itself", errConfigURL)
+	}
+	return nil
+}
+
+// validateExtraArgs returns an error if args include an option the provider
+// sets, as pcluster would either reject it or use it instead.
+func validateExtraArgs(args []string) error {
+	for _, a := range args {
+		option, _, _ := strings.Cut(a, "=")
+		if reservedOptions[option] {
+			return errors.Errorf("%s: %s is set by the provider", errExtraArgs, option)
+		}
+	}
+	return nil
+}
+
+// tagArgs returns a --tags flag for each of the supplied tags, using the AWS
+// CLI shorthand syntax. No flags are returned when there are no tags.
+func tagArgs(tags []v1alpha1.Tag) []string {
+	args := make([]string, 0, len(tags)*2)
+	for _, t := range tags {
+		args = append(args, "--tags", fmt.Sprintf("Key=%s,Value=%s", quoteShorthand(t.Key), quoteShorthand(t.Value)))
+	}
+	return args
+}
+
+// suppressValidatorArgs returns a single --suppress-validators flag followed by
+// the supplied validators, or no flag at all if there are none.
+func suppressValidatorArgs(validators []string) []string {
+	if len(validators) == 0 {
+		return nil
+	}
+	return append([]string{"--suppress-validators"}, validators...)
+}
+
+// forceUpdateArgs returns a --force-update flag if force is set.
+func forceUpdateArgs(force *bool) []string {
+	if force == nil {
+		return nil
+	}
+	return []string{"--force-update", strconv.FormatBool(*force)}
+}
+
+// quoteShorthand quotes s if it contains characters that are significant to
+// the AWS CLI shorthand syntax. Arguments are passed to pcluster without a
+// shell, so no shell quoting is needed.
+func quoteShorthand(s string) string {
+	if !strings.ContainsAny(s, ",= \"'\\") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// validationError returns an error listing the validators that failed at ERROR
+// level, if the output of a failed command includes any. The error becomes the
+// message of the Cluster's Synced condition. Validators that failed at WARNING
+// level are recorded as events.
+func (c *external) validationError(cr *v1alpha1.Cluster, cmdOutput []byte) error {
+	var pErr errorOutput
+	if err := json.Unmarshal(cmdOutput, &pErr); err != nil {
+		return nil
+	}
+	msgs := append(pErr.ConfigurationValidationErrors, pErr.ValidationMessages...)
+	c.recordValidationWarnings(cr, msgs)
+	var failures []string
+	for _, m := range msgs {
+		if m.Level == ValidationError {
+			failures = append(failures, fmt.Sprintf("%s: %s", m.Type, m.Message))
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrValidationFailed, strings.Join(failures, "; "))
+}
+
+// recordValidationWarnings emits an event for each validator that failed at
+// WARNING level.
+func (c *external) recordValidationWarnings(cr *v1alpha1.Cluster, msgs []ValidationMessage) {
+	for _, m := range msgs {
+		if m.Level == ValidationWarning {
+			c.recorder.Event(cr, event.Warning(reasonValidation, errors.Errorf("%s: %s", m.Type, m.Message)))
+		}
+	}
+}
+
+// isNotFound returns true if msg reports that the named cluster does not
+// exist. pcluster versions phrase this differently, e.g. "Cluster 'name' does
+// not exist in region ..." or "Stack with id name does not exist", so the
+// message need only name the cluster and say it does not exist.
+func isNotFound(msg, clusterName string) bool {
+	if !strings.Contains(strings.ToLower(msg), "does not exist") {
+		return false
+	}
+	// The name must not be part of a longer name, or of another resource's.
+	for i := 0; i <= len(msg)-len(clusterName); i++ {
+		j := strings.Index(msg[i:], clusterName)
+		if j < 0 {
+			return false
+		}
+		i += j
+		before, _ := utf8.DecodeLastRuneInString(msg[:i])
+		after, _ := utf8.DecodeRuneInString(msg[i+len(clusterName):])
+		if !isClusterNameRune(before) && !isClusterNameRune(after) {
+			return true
+		}
+	}
+	return false
+}
+
+// isClusterNameRune returns true if r may appear in a cluster name.
+func isClusterNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-'
+}
+
+// parseErrorOutput parses pcluster's JSON error output, which may follow
+// warnings. It returns false if the output contains no JSON.
+func parseErrorOutput(cmdOutput []byte) (errorOutput, bool) {
+	var pErr errorOutput
+	if err := json.Unmarshal(cmdOutput, &pErr); err == nil {
+		return pErr, true
+	}
+	if i := bytes.Index(cmdOutput, []byte("\n{")); i >= 0 {
+		if err := json.Unmarshal(cmdOutput[i+1:], &pErr); err == nil {
+			return pErr, true
+		}
+	}
+	return errorOutput{}, false
+}
+
+// errorMessage returns a one line summary of a pcluster error: the message of
+// its JSON error, which may follow other output, or else the last line of its
+// output.
+func errorMessage(cmdOutput []byte) string {
+	if pErr, ok := parseErrorOutput(cmdOutput); ok && pErr.Message != "" {
+		return pErr.Message
+	}
+	// Otherwise the last line is usually the error, e.g. of a traceback.
+	lines := strings.Split(strings.TrimSpace(string(cmdOutput)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// getChangeSet returns the changes reported by an update-cluster dry run.
+func getChangeSet(cmdOutput []byte) []v1alpha1.Change {
+	var dryRunOutput UpdateClusterOutput
+	if err := json.Unmarshal(cmdOutput, &dryRunOutput); err != nil {
+		return nil
+	}
+	var changes []v1alpha1.Change
+	for _, c := range dryRunOutput.ChangeSet {
+		changes = append(changes, v1alpha1.Change{
+			Parameter:      c.Parameter,
+			CurrentValue:   string(c.CurrentValue),
+			RequestedValue: string(c.RequestedValue),
+		})
+	}
+	return changes
+}
+
+// createTempDir creates a temporary directory in base, or in the system's
+// temporary directory if base is empty. Callers remove the directory when they
+// return, including when they panic or their context is cancelled; only those
+// of a killed provider are left behind, for sweepTempDirs to remove.
+func createTempDir(base, prefix string) (string, error) {
+	dir, err := os.MkdirTemp(base, tempDirPrefix+prefix)
 	if err != nil {
 		return "", fmt.Errorf("failed to create tmp dir: %w", err)
 	}
 	return dir, nil
 }
 
+// sweepStaleTempDirs removes any temporary directories a previous provider
+// process left in the system's temporary directory. Those in a ProviderConfig's
+// working directory are not swept, as it is unknown until a resource uses it.
+func sweepStaleTempDirs(log logging.Logger) {
+	n, err := sweepTempDirs(os.TempDir(), time.Now().Add(-staleTempDirAge))
+	if err != nil {
+		log.Info("cannot remove stale temporary directories", "error", err)
+	}
+	if n > 0 {
+		log.Info("removed stale temporary directories", "count", n)
+	}
+}
+
+// sweepTempDirs removes the temporary directories created by createTempDir in
+// base that were last modified before the supplied time. It returns how many
+// were removed.
+func sweepTempDirs(base string, before time.Time) (int, error) {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), tempDirPrefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(base, e.Name())); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// checkWorkingDir returns an error if temporary directories can't be created
+// in dir. An empty dir is the system's temporary directory, which is assumed
+// to be usable.
+func checkWorkingDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	d, err := os.MkdirTemp(dir, "check")
+	if err != nil {
+		return errors.Wrapf(err, "%s %q", errWorkingDir, dir)
+	}
+	return os.Remove(d)
+}
+
 func writeConfigToFile(input string, filePath string) error {
 	configFile, err := os.Create(filePath)
 	if err != nil {
@@ -431,6 +2047,46 @@ func writeConfigToFile(input string, filePath string) error {
 func setStatus(output OutputCluster, cluster *v1alpha1.Cluster) {
 	cluster.Status.AtProvider.ClusterStatus = output.ClusterStatus
 	cluster.Status.AtProvider.CloudformationStackArn = output.CloudformationStackArn
+	cluster.Status.AtProvider.CloudformationStackStatus = output.CloudformationStackStatus
 	cluster.Status.AtProvider.Scheduler.SchedulerType = output.Scheduler.SchedulerType
 	cluster.Status.AtProvider.ClusterName = output.ClusterName
+	cluster.Status.AtProvider.Version = output.Version
+}
+
+// setDescribeStatus sets the fields only describe-cluster reports, in addition
+// to those common to every pcluster command.
+func setDescribeStatus(output DescribeClusterOutput, cluster *v1alpha1.Cluster) {
+	setStatus(output.OutputCluster, cluster)
+	if m := output.Scheduler.Metadata; m != nil {
+		cluster.Status.AtProvider.Scheduler.Name = m.Name
+		cluster.Status.AtProvider.Scheduler.Version = m.Version
+	}
+	cluster.Status.AtProvider.ComputeFleetStatus = output.ComputeFleetStatus
+	cluster.Status.AtProvider.ConfigurationURL = output.ClusterConfiguration.URL
+	cluster.Status.AtProvider.CreationTime = formatTime(output.CreationTime)
+	cluster.Status.AtProvider.LastUpdatedTime = formatTime(output.LastUpdatedTime)
+	cluster.Status.AtProvider.HeadNode = v1alpha1.HeadNode{
+		InstanceID:       output.HeadNode.InstanceID,
+		InstanceType:     output.HeadNode.InstanceType,
+		State:            output.HeadNode.State,
+		PublicIPAddress:  output.HeadNode.PublicIPAddress,
+		PrivateIPAddress: output.HeadNode.PrivateIPAddress,
+		LaunchTime:       formatTime(output.HeadNode.LaunchTime),
+	}
+	cluster.Status.AtProvider.LoginNodes = nil
+	for _, pool := range output.LoginNodes {
+		cluster.Status.AtProvider.LoginNodes = append(cluster.Status.AtProvider.LoginNodes, v1alpha1.LoginNodes{
+			PoolName: pool.PoolName,
+			Status:   pool.Status,
+			Address:  pool.Address,
+		})
+	}
+}
+
+// formatTime returns t in RFC3339 format, or an empty string if t is unset.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
 }
@@ -18,20 +18,35 @@ package cluster
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/clients/pcluster"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
 	k8sexec "k8s.io/utils/exec"
 	fakeexec "k8s.io/utils/exec/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Unlike many Kubernetes projects Crossplane does not use third party testing
@@ -49,7 +64,7 @@ func makeCluster() *v1alpha1.Cluster {
 		},
 		Spec: v1alpha1.ClusterSpec{
 			ForProvider: v1alpha1.ClusterParameters{
-				Region:               "us-eastish",
+				Region:               "us-east-1",
 				ClusterConfiguration: "Image:\n        Os: alinux2\n",
 			},
 		},
@@ -68,10 +83,528 @@ func readResourceFile(path string, errToReturn error) func() ([]byte, []byte, er
 	}
 }
 
+// fakeOutput returns an action that runs a command with the supplied stdout.
+// describeNotFound is the describe-cluster that Create runs first, finding no
+// cluster.
+func describeNotFound(cmd string, args ...string) k8sexec.Cmd {
+	return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("notFound.json", errors.New("exit status 1"))}}
+}
+
+func fakeOutput(output string, err error) fakeexec.FakeCommandAction {
+	return func(cmd string, args ...string) k8sexec.Cmd {
+		return &fakeexec.FakeCmd{
+			RunScript: []fakeexec.FakeAction{
+				func() ([]byte, []byte, error) { return []byte(output), nil, err },
+			},
+		}
+	}
+}
+
+// describeWithStatus returns an action that describes the test cluster in the
+// supplied status.
+func describeWithStatus(status PClusterStatus) fakeexec.FakeCommandAction {
+	b, err := os.ReadFile(filepath.Join("resources", "describeOutput.json"))
+	if err != nil {
+		panic(fmt.Sprintf("couldn't read file: %s", err))
+	}
+	output := strings.Replace(string(b), `"clusterStatus": "CREATE_IN_PROGRESS"`, fmt.Sprintf("%q: %q", "clusterStatus", status), 1)
+	return fakeOutput(output, nil)
+}
+
+// hasEnv returns true if env contains an entry with the supplied prefix.
+func hasEnv(env []string, prefix string) bool {
+	for _, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConnect(t *testing.T) {
+	providerConfig := func(spec apisv1alpha1.ProviderConfigSpec) client.Client {
+		return &test.MockClient{MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+			switch o := obj.(type) {
+			case *apisv1alpha1.ProviderConfig:
+				if spec.Credentials.Source == "" {
+					spec.Credentials.Source = xpv1.CredentialsSourceNone
+				}
+				o.Spec = spec
+			case *corev1.Secret:
+				o.Data = map[string][]byte{"credentials": []byte("[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n")}
+			}
+			return nil
+		})}
+	}
+
+	type want struct {
+		binary string
+		files  pcluster.AWSFiles
+		err    error
+	}
+
+	pclusterVersion := func(v string) fakeexec.FakeAction {
+		return func() ([]byte, []byte, error) {
+			return []byte(fmt.Sprintf(`{"version": %q}`, v)), nil, nil
+		}
+	}
+
+	cases := map[string]struct {
+		reason  string
+		kube    client.Client
+		cr      func(cr *v1alpha1.Cluster)
+		version fakeexec.FakeAction
+		want    want
+	}{
+		"Default": {
+			reason: "pcluster should be run from the PATH by default.",
+			kube:   providerConfig(apisv1alpha1.ProviderConfigSpec{}),
+			want:   want{binary: pcluster.Binary},
+		},
+		"Credentials": {
+			reason: "pcluster should be run with the ProviderConfig's credentials.",
+			kube: providerConfig(apisv1alpha1.ProviderConfigSpec{Credentials: apisv1alpha1.ProviderCredentials{
+				Source: xpv1.CredentialsSourceSecret,
+				CommonCredentialSelectors: xpv1.CommonCredentialSelectors{SecretRef: &xpv1.SecretKeySelector{
+					SecretReference: xpv1.SecretReference{Name: "creds", Namespace: "crossplane-system"},
+					Key:             "credentials",
+				}},
+			}}),
+			want: want{
+				binary: pcluster.Binary,
+				files:  pcluster.AWSFiles{Credentials: "[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n"},
+			},
+		},
+		"NoRegion": {
+			reason: "Connecting should fail if neither the Cluster nor the ProviderConfig specify a region.",
+			kube:   providerConfig(apisv1alpha1.ProviderConfigSpec{}),
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.Region = "" },
+			want:   want{err: errors.New(errNoRegion)},
+		},
+		"DefaultRegion": {
+			reason: "A Cluster without a region should use the ProviderConfig's default region.",
+			kube:   providerConfig(apisv1alpha1.ProviderConfigSpec{DefaultRegion: "eu-west-1"}),
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.Region = "" },
+			want:   want{binary: pcluster.Binary},
+		},
+		"BinaryMissing": {
+			reason:  "Connecting should fail if the pcluster binary cannot be found.",
+			kube:    providerConfig(apisv1alpha1.ProviderConfigSpec{}),
+			version: func() ([]byte, []byte, error) { return nil, nil, k8sexec.ErrExecutableNotFound },
+			want: want{
+				err: errors.Wrapf(k8sexec.ErrExecutableNotFound, "%s %q", errFindBinary, pcluster.Binary),
+			},
+		},
+		"VersionTooOld": {
+			reason:  "Connecting should fail if pcluster is older than the minimum version.",
+			kube:    providerConfig(apisv1alpha1.ProviderConfigSpec{MinimumPclusterVersion: "3.7.0"}),
+			version: pclusterVersion("3.6.1"),
+			want: want{
+				err: errors.Errorf("%s 3.6.1: must be at least 3.7.0", errUnsupported),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("TMPDIR", t.TempDir())
+			if tc.version == nil {
+				tc.version = pclusterVersion("3.7.0")
+			}
+			executor := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+				func(cmd string, args ...string) k8sexec.Cmd {
+					return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{tc.version}}
+				},
+			}}
+			c := connector{
+				kube:          tc.kube,
+				usage:         resource.TrackerFn(func(_ context.Context, _ resource.Managed) error { return nil }),
+				newExectuorFn: func(_ []byte) (k8sexec.Interface, error) { return executor, nil },
+				logger:        logging.NewNopLogger(),
+			}
+			cr := makeCluster()
+			cr.SetProviderConfigReference(&xpv1.Reference{Name: "default"})
+			if tc.cr != nil {
+				tc.cr(cr)
+			}
+			got, err := c.Connect(context.Background(), cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("\n%s\nc.Connect(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				return
+			}
+			e := got.(*external)
+			if e.binary != tc.want.binary {
+				t.Errorf("\n%s\nc.Connect(...): want binary %q, got %q", tc.reason, tc.want.binary, e.binary)
+			}
+			if !strings.HasPrefix(e.awsFiles.Credentials, tc.want.files.Credentials) || !strings.HasPrefix(e.awsFiles.Config, tc.want.files.Config) {
+				t.Errorf("\n%s\nc.Connect(...): want AWS files with prefixes %+v, got %+v", tc.reason, tc.want.files, e.awsFiles)
+			}
+			if hasEnv(e.env, "AWS_ACCESS_KEY_ID=AKIAEXAMPLE") {
+				t.Errorf("\n%s\nc.Connect(...): want no credentials in env, got %v", tc.reason, e.env)
+			}
+		})
+	}
+}
+
+func TestCheckWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		reason  string
+		dir     string
+		wantErr bool
+	}{
+		"Unset": {
+			reason: "The system's temporary directory should be used when no directory is set.",
+		},
+		"Writable": {
+			reason: "A writable directory should be usable.",
+			dir:    dir,
+		},
+		"Missing": {
+			reason:  "A directory that doesn't exist should not be usable.",
+			dir:     filepath.Join(dir, "missing"),
+			wantErr: true,
+		},
+		"NotDirectory": {
+			reason:  "A file should not be usable.",
+			dir:     file,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := checkWorkingDir(tc.dir)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("\n%s\ncheckWorkingDir(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("\n%s\ncheckWorkingDir(...): want nothing left in the directory, got %d entries", tc.reason, len(entries))
+			}
+		})
+	}
+}
+
+func TestSweepTempDirs(t *testing.T) {
+	base := t.TempDir()
+	stale, err := createTempDir(base, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stale, clusterConfigFileName), []byte("Image:\n  Os: alinux2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleTempDirAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := createTempDir(base, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(base, "other")
+	if err := os.Mkdir(other, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(other, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := sweepTempDirs(base, time.Now().Add(-staleTempDirAge))
+	if err != nil {
+		t.Fatalf("sweepTempDirs(...): %s", err)
+	}
+	if n != 1 {
+		t.Errorf("sweepTempDirs(...): want 1 directory removed, got %d", n)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("sweepTempDirs(...): want the stale directory removed, got %v", err)
+	}
+	for _, dir := range []string{fresh, other} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("sweepTempDirs(...): want %s kept, got %v", dir, err)
+		}
+	}
+}
+
+func TestExecPclusterEnv(t *testing.T) {
+	path := os.Getenv("PATH")
+	fc := &fakeexec.FakeCmd{
+		RunScript: []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) { return nil, nil, nil },
+		},
+	}
+	executor := &fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			func(cmd string, args ...string) k8sexec.Cmd { return fc },
+		},
+	}
+	e := external{executor: executor, env: []string{"PATH=/venv/bin:/usr/bin"}, logger: logging.NewNopLogger()}
+	if _, err := e.execPcluster(context.Background(), logging.NewNopLogger(), "", "version"); err != nil {
+		t.Fatalf("e.execPcluster(...): %s", err)
+	}
+	if !hasEnv(fc.Env, "PATH=/venv/bin:") {
+		t.Errorf("e.execPcluster(...): want command PATH to include the virtual environment, got %v", fc.Env)
+	}
+	if os.Getenv("PATH") != path {
+		t.Errorf("e.execPcluster(...): process PATH was modified")
+	}
+}
+
+func TestUpdateDoesNotPrompt(t *testing.T) {
+	// The command prompts for confirmation, reading stdin until it is closed.
+	// Without stdin it would wait for a terminal that never answers.
+	fc := &fakeexec.FakeCmd{}
+	fc.RunScript = []fakeexec.FakeAction{
+		func() ([]byte, []byte, error) {
+			stdin := fc.Stdin
+			if stdin == nil {
+				stdin, _ = io.Pipe()
+			}
+			if _, err := io.ReadAll(stdin); err != nil {
+				return nil, nil, err
+			}
+			return []byte(`{"message": "Update aborted: confirmation required"}`), nil, errors.New("exit status 1")
+		},
+	}
+	executor := &fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			func(cmd string, args ...string) k8sexec.Cmd { return fc },
+		},
+	}
+	e := external{executor: executor, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.Update(context.Background(), makeCluster())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("e.Update(...): want error from the aborted update, got nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("e.Update(...): blocked waiting for input")
+	}
+}
+
+func TestExecPclusterStdin(t *testing.T) {
+	// The fake pcluster prompts for input, and fails if there is none.
+	binary := filepath.Join(t.TempDir(), "pcluster")
+	script := "#!/bin/sh\nread answer || { echo '{\"message\": \"no input\"}'; exit 1; }\necho '{}'\n"
+	if err := os.WriteFile(binary, []byte(script), 0o700); err != nil {
+		t.Fatalf("os.WriteFile(...): %s", err)
+	}
+	e := external{executor: k8sexec.New(), binary: binary, timeout: 10 * time.Second, logger: logging.NewNopLogger()}
+	output, err := e.execPcluster(context.Background(), logging.NewNopLogger(), "", "update-cluster")
+	if err == nil {
+		t.Fatalf("e.execPcluster(...): want error from the prompt, got output %q", output)
+	}
+	if got, want := errorMessage(output), "no input"; got != want {
+		t.Errorf("e.execPcluster(...): want the prompt to read EOF, got %v: %q", err, got)
+	}
+}
+
+func TestExecPclusterCancelled(t *testing.T) {
+	// The fake pcluster writes part of its output, then hangs until killed.
+	binary := filepath.Join(t.TempDir(), "pcluster")
+	script := "#!/bin/sh\necho '{\"message\": \"No changes'\nexec sleep 10\n"
+	if err := os.WriteFile(binary, []byte(script), 0o700); err != nil {
+		t.Fatalf("os.WriteFile(...): %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	e := external{executor: k8sexec.New(), binary: binary, logger: logging.NewNopLogger()}
+	start := time.Now()
+	output, err := e.execPcluster(ctx, logging.NewNopLogger(), "", "update-cluster")
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("e.execPcluster(...): want ErrInterrupted, got %v", err)
+	}
+	if output != nil {
+		t.Errorf("e.execPcluster(...): want no partial output, got %q", output)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("e.execPcluster(...): want the command killed when cancelled, ran for %s", d)
+	}
+}
+
+func TestCreateInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	executor := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+		describeNotFound,
+		func(cmd string, args ...string) k8sexec.Cmd {
+			return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{
+				func() ([]byte, []byte, error) {
+					cancel()
+					return []byte("{"), nil, errors.New("signal: killed")
+				},
+			}}
+		},
+	}}
+	r := &recordingRecorder{}
+	e := external{executor: executor, workingDir: t.TempDir(), logger: logging.NewNopLogger(), recorder: r}
+	cr := makeCluster()
+	_, err := e.Create(ctx, cr)
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("e.Create(...): want ErrInterrupted, got %v", err)
+	}
+	if cr.Status.AtProvider.LastCommandFailure != nil {
+		t.Errorf("e.Create(...): want no command failure recorded, got %+v", cr.Status.AtProvider.LastCommandFailure)
+	}
+	if len(r.reasons) != 0 {
+		t.Errorf("e.Create(...): want no events, got %v", r.reasons)
+	}
+}
+
+func TestExecPclusterDebug(t *testing.T) {
+	debug := []byte("2023-01-04 00:00:00,000 - DEBUG - pcluster.cli - Handling describe-cluster\n")
+	cases := map[string]struct {
+		reason string
+		action fakeexec.FakeAction
+		want   string
+	}{
+		"Succeeded": {
+			reason: "Only the JSON result should be returned.",
+			action: func() ([]byte, []byte, error) { return []byte(`{"clusterName": "test"}`), debug, nil },
+			want:   `{"clusterName": "test"}`,
+		},
+		"FailedWithoutOutput": {
+			reason: "The debug output of a command that failed without output should be returned.",
+			action: func() ([]byte, []byte, error) { return nil, []byte("KeyError: 'Stacks'"), errors.New("exit status 1") },
+			want:   "KeyError: 'Stacks'",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fc := &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{tc.action}}
+			executor := &fakeexec.FakeExec{
+				CommandScript: []fakeexec.FakeCommandAction{
+					func(cmd string, args ...string) k8sexec.Cmd { return fakeexec.InitFakeCmd(fc, cmd, args...) },
+				},
+			}
+			e := external{executor: executor, cliDebug: true, logger: logging.NewNopLogger()}
+			output, _ := e.execPcluster(context.Background(), logging.NewNopLogger(), "", "describe-cluster")
+			if diff := cmp.Diff(tc.want, string(output)); diff != "" {
+				t.Errorf("\n%s\ne.execPcluster(...): -want output, +got output:\n%s\n", tc.reason, diff)
+			}
+			if got := fc.Argv[len(fc.Argv)-1]; got != "--debug" {
+				t.Errorf("\n%s\ne.execPcluster(...): want --debug passed, got %v", tc.reason, fc.Argv)
+			}
+		})
+	}
+}
+
+func TestExecPclusterAWSFiles(t *testing.T) {
+	var credsFile, creds string
+	fc := &fakeexec.FakeCmd{}
+	fc.RunScript = []fakeexec.FakeAction{
+		func() ([]byte, []byte, error) {
+			for _, e := range fc.Env {
+				if strings.HasPrefix(e, "AWS_SHARED_CREDENTIALS_FILE=") {
+					credsFile = strings.TrimPrefix(e, "AWS_SHARED_CREDENTIALS_FILE=")
+				}
+			}
+			b, err := os.ReadFile(credsFile)
+			creds = string(b)
+			return nil, nil, err
+		},
+	}
+	executor := &fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			func(cmd string, args ...string) k8sexec.Cmd { return fc },
+		},
+	}
+	e := external{executor: executor, awsFiles: pcluster.AWSFiles{Credentials: "[default]\n"}, logger: logging.NewNopLogger()}
+	if _, err := e.execPcluster(context.Background(), logging.NewNopLogger(), "", "list-clusters"); err != nil {
+		t.Fatalf("e.execPcluster(...): %s", err)
+	}
+	if creds != "[default]\n" {
+		t.Errorf("e.execPcluster(...): want the credentials file to be written for the command, got %q", creds)
+	}
+	if _, err := os.Stat(credsFile); !os.IsNotExist(err) {
+		t.Errorf("e.execPcluster(...): want the credentials file to be removed, got %v", err)
+	}
+}
+
+func TestExecPclusterRetry(t *testing.T) {
+	throttled := func() ([]byte, []byte, error) {
+		return []byte(`{"message": "An error occurred (Throttling) when calling the DescribeStacks operation (reached max retries: 4): Rate exceeded"}`), nil, errors.New("exit status 1")
+	}
+	succeeded := func() ([]byte, []byte, error) { return []byte(`{}`), nil, nil }
+	failed := func() ([]byte, []byte, error) {
+		return []byte(`{"message": "Bad Request"}`), nil, errors.New("exit status 1")
+	}
+
+	cases := map[string]struct {
+		reason     string
+		maxRetries int
+		actions    []fakeexec.FakeAction
+		wantErr    bool
+		throttled  bool
+	}{
+		"ThrottledThenSucceeded": {
+			reason:     "A command should be retried until it is no longer throttled.",
+			maxRetries: 3,
+			actions:    []fakeexec.FakeAction{throttled, throttled, succeeded},
+		},
+		"RetriesExhausted": {
+			reason:     "The error should be returned once retries are exhausted.",
+			maxRetries: 1,
+			actions:    []fakeexec.FakeAction{throttled, throttled},
+			wantErr:    true,
+			throttled:  true,
+		},
+		"NotThrottled": {
+			reason:     "Other errors should not be retried.",
+			maxRetries: 3,
+			actions:    []fakeexec.FakeAction{failed},
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			executor := &fakeexec.FakeExec{}
+			for _, a := range tc.actions {
+				a := a
+				executor.CommandScript = append(executor.CommandScript, func(cmd string, args ...string) k8sexec.Cmd {
+					return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{a}}
+				})
+			}
+			e := external{executor: executor, maxRetries: tc.maxRetries, retryBaseDelay: time.Millisecond, logger: logging.NewNopLogger()}
+			_, err := e.execPcluster(context.Background(), logging.NewNopLogger(), "", "describe-cluster")
+			if (err != nil) != tc.wantErr {
+				t.Errorf("\n%s\ne.execPcluster(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+			if got := errors.Is(err, ErrThrottled); got != tc.throttled {
+				t.Errorf("\n%s\nerrors.Is(err, ErrThrottled): want %t, got %t", tc.reason, tc.throttled, got)
+			}
+			if got, want := executor.CommandCalls, len(tc.actions); got != want {
+				t.Errorf("\n%s\ne.execPcluster(...): want %d attempts, got %d", tc.reason, want, got)
+			}
+		})
+	}
+}
+
 func TestObserve(t *testing.T) {
+	headNodeDetails := managed.ConnectionDetails{
+		keyHeadNodePrivateIP:  []byte("10.0.1.25"),
+		keyHeadNodeInstanceID: []byte("i-0a1b2c3d4e5f67890"),
+	}
+
 	type fields struct {
-		executor fakeexec.FakeExec
-		actions  []fakeexec.FakeCommandAction
+		executor      fakeexec.FakeExec
+		actions       []fakeexec.FakeCommandAction
+		defaultRegion string
 	}
 
 	type args struct {
@@ -82,6 +615,9 @@ func TestObserve(t *testing.T) {
 	type want struct {
 		o   managed.ExternalObservation
 		err error
+
+		// ready is the expected Ready condition, if any.
+		ready *xpv1.Condition
 	}
 
 	cases := map[string]struct {
@@ -90,6 +626,23 @@ func TestObserve(t *testing.T) {
 		args   args
 		want   want
 	}{
+		"unexpectedOutput": {
+			reason: "Output of an unexpected shape should be an error rather than an empty status.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				err: errors.New(errNoClusterStatus),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						fakeOutput(`{"cluster": {"name": "test", "status": "CREATE_COMPLETE"}}`, nil),
+					},
+				},
+			},
+		},
 		"resourceUpToDate": {
 			args: args{
 				ctx: context.Background(),
@@ -97,28 +650,63 @@ func TestObserve(t *testing.T) {
 			},
 			want: want{
 				o: managed.ExternalObservation{
-					ResourceExists:   true,
-					ResourceUpToDate: true,
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: headNodeDetails,
 				},
 				err: nil,
 			},
 			fields: fields{
 				executor: fakeexec.FakeExec{
 					CommandScript: []fakeexec.FakeCommandAction{
+						describeWithStatus(CreateComplete),
 						func(cmd string, args ...string) k8sexec.Cmd {
 							return &fakeexec.FakeCmd{
-								CombinedOutputScript: []fakeexec.FakeAction{
-									readResourceFile("describeOutPut.json", nil),
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("upToDate.json", fmt.Errorf("error")),
 								},
 							}
 						},
+						fakeOutput(`{"clusters": []}`, nil),
+						fakeOutput("", errors.New("error")),
+						fakeOutput("", errors.New("error")),
+					},
+				},
+			},
+		},
+		"lateInitializeRegion": {
+			reason: "The default region should be late-initialized if the Cluster has no region.",
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					cr := makeCluster()
+					cr.Spec.ForProvider.Region = ""
+					return cr
+				}(),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceUpToDate:        true,
+					ResourceLateInitialized: true,
+					ConnectionDetails:       headNodeDetails,
+				},
+			},
+			fields: fields{
+				defaultRegion: "us-east-1",
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						describeWithStatus(CreateComplete),
 						func(cmd string, args ...string) k8sexec.Cmd {
 							return &fakeexec.FakeCmd{
-								CombinedOutputScript: []fakeexec.FakeAction{
+								RunScript: []fakeexec.FakeAction{
 									readResourceFile("upToDate.json", fmt.Errorf("error")),
 								},
 							}
 						},
+						fakeOutput(`{"clusters": []}`, nil),
+						fakeOutput("", errors.New("error")),
+						fakeOutput("", errors.New("error")),
 					},
 				},
 			},
@@ -130,68 +718,2166 @@ func TestObserve(t *testing.T) {
 			},
 			want: want{
 				o: managed.ExternalObservation{
-					ResourceExists:   true,
-					ResourceUpToDate: false,
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: headNodeDetails,
 				},
 				err: nil,
 			},
 			fields: fields{
 				executor: fakeexec.FakeExec{
 					CommandScript: []fakeexec.FakeCommandAction{
+						describeWithStatus(CreateComplete),
 						func(cmd string, args ...string) k8sexec.Cmd {
 							return &fakeexec.FakeCmd{
-								CombinedOutputScript: []fakeexec.FakeAction{
-									readResourceFile("describeOutPut.json", nil),
-								},
-							}
-						},
-						func(cmd string, args ...string) k8sexec.Cmd {
-							return &fakeexec.FakeCmd{
-								CombinedOutputScript: []fakeexec.FakeAction{
+								RunScript: []fakeexec.FakeAction{
 									readResourceFile("notUpToDate.json", fmt.Errorf("error")),
 								},
 							}
 						},
+						fakeOutput(`{"clusters": []}`, nil),
+						fakeOutput("", errors.New("error")),
+						fakeOutput("", errors.New("error")),
 					},
 				},
 			},
 		},
-		"resourceDoesNotExist": {
+		"resourceDryRunInProgress": {
 			args: args{
 				ctx: context.Background(),
 				mg:  makeCluster(),
 			},
 			want: want{
 				o: managed.ExternalObservation{
-					ResourceExists: false,
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: headNodeDetails,
 				},
 				err: nil,
 			},
 			fields: fields{
 				executor: fakeexec.FakeExec{
 					CommandScript: []fakeexec.FakeCommandAction{
+						describeWithStatus(CreateComplete),
 						func(cmd string, args ...string) k8sexec.Cmd {
 							return &fakeexec.FakeCmd{
-								CombinedOutputScript: []fakeexec.FakeAction{
-									readResourceFile("notFound.json", fmt.Errorf("notused")),
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("updateInProgress.json", fmt.Errorf("error")),
 								},
 							}
 						},
+						fakeOutput(`{"clusters": []}`, nil),
+						fakeOutput("", errors.New("error")),
+						fakeOutput("", errors.New("error")),
 					},
 				},
 			},
 		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			e := external{executor: &tc.fields.executor, logger: logging.NewNopLogger()}
-			got, err := e.Observe(tc.args.ctx, tc.args.mg)
-			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
-			}
-			if diff := cmp.Diff(tc.want.o, got); diff != "" {
-				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+		"resourceCreateInProgress": {
+			reason: "No dry-run should be done while an operation is in progress, and the cluster should not be up to date.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: headNodeDetails,
+				},
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						describeWithStatus(CreateInProgress),
+						fakeOutput(readFile(t, "stackEventsInProgress.json"), nil),
+					},
+				},
+			},
+		},
+		"resourceUpdateInProgress": {
+			reason: "No dry-run should be done while an operation is in progress, and the cluster should not be up to date.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: headNodeDetails,
+				},
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						describeWithStatus(UpdateInProgress),
+						fakeOutput(readFile(t, "stackEventsInProgress.json"), nil),
+					},
+				},
+			},
+		},
+		"resourceDeleteInProgress": {
+			reason: "No dry-run should be done while an operation is in progress, and the cluster should not be up to date.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  false,
+					ConnectionDetails: headNodeDetails,
+				},
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						describeWithStatus(DeleteInProgress),
+					},
+				},
+			},
+		},
+		"resourcePlainTextError": {
+			reason: "A failure that pcluster didn't report as JSON should be returned rather than treated as not found.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				err: fmt.Errorf("failed to run pcluster command: %s: %w", errors.Errorf("%s: %s", errUnexpectedOutput, "botocore.exceptions.NoCredentialsError: Unable to locate credentials"), errors.New("exit status 1")),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						fakeOutput("Traceback (most recent call last):\nbotocore.exceptions.NoCredentialsError: Unable to locate credentials\n", errors.New("exit status 1")),
+					},
+				},
+			},
+		},
+		"resourceCreateFailed": {
+			reason: "A cluster that failed to create should exist, as its stack must be deleted before it can be created again.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists:    true,
+					ResourceUpToDate:  true,
+					ConnectionDetails: managed.ConnectionDetails{},
+				},
+				ready: func() *xpv1.Condition {
+					c := xpv1.Unavailable().WithMessage(msgCreateFailed + ": HeadNode CREATE_FAILED: Your requested instance type (t2.micro) is not supported in your requested Availability Zone (us-east-1e).")
+					return &c
+				}(),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("describeCreateFailed.json", nil),
+								},
+							}
+						},
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("notUpToDate.json", fmt.Errorf("error")),
+								},
+							}
+						},
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("stackEvents.json", nil),
+								},
+							}
+						},
+					},
+				},
+			},
+		},
+		"resourceDoesNotExist": {
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				o: managed.ExternalObservation{
+					ResourceExists: false,
+				},
+				err: nil,
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("notFound.json", fmt.Errorf("notused")),
+								},
+							}
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{executor: &tc.fields.executor, defaultRegion: tc.fields.defaultRegion, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			got, err := e.Observe(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if got, want := tc.fields.executor.CommandCalls, len(tc.fields.executor.CommandScript); got != want {
+				t.Errorf("\n%s\ne.Observe(...): want %d pcluster commands, got %d", tc.reason, want, got)
+			}
+			if tc.want.ready != nil {
+				if diff := cmp.Diff(*tc.want.ready, tc.args.mg.GetCondition(xpv1.TypeReady), cmpopts.IgnoreFields(xpv1.Condition{}, "LastTransitionTime")); diff != "" {
+					t.Errorf("\n%s\ne.Observe(...): -want Ready condition, +got Ready condition:\n%s\n", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestForceReconcile(t *testing.T) {
+	upToDate := func(cmd string, args ...string) k8sexec.Cmd {
+		return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("upToDate.json", errors.New("exit status 1"))}}
+	}
+	observe := []fakeexec.FakeCommandAction{describeWithStatus(CreateComplete), upToDate, fakeOutput(`{"clusters": []}`, nil), fakeOutput("", errors.New("error")), fakeOutput("", errors.New("error"))}
+	script := append(append(append([]fakeexec.FakeCommandAction{}, observe...), upToDate), observe...)
+	fe := &fakeexec.FakeExec{CommandScript: script}
+	e := external{executor: fe, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.SetAnnotations(map[string]string{annotationForceReconcile: "2023-09-12T16:00:00Z"})
+
+	o, err := e.Observe(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %s", err)
+	}
+	if o.ResourceUpToDate {
+		t.Errorf("e.Observe(...): want a forced reconcile to report the cluster as not up to date")
+	}
+	if _, err := e.Update(context.Background(), cr); err != nil {
+		t.Fatalf("e.Update(...): %s", err)
+	}
+	if got, want := cr.Status.AtProvider.ForceReconcileHandled, "2023-09-12T16:00:00Z"; got != want {
+		t.Errorf("e.Update(...): want handled force-reconcile %q, got %q", want, got)
+	}
+	o, err = e.Observe(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %s", err)
+	}
+	if !o.ResourceUpToDate {
+		t.Errorf("e.Observe(...): want a handled forced reconcile to report the cluster as up to date")
+	}
+	if fe.CommandCalls != len(script) {
+		t.Errorf("want %d pcluster commands, got %d", len(script), fe.CommandCalls)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	type fields struct {
+		executor       fakeexec.FakeExec
+		allowedRegions []string
+	}
+
+	type args struct {
+		ctx context.Context
+		mg  resource.Managed
+	}
+
+	type want struct {
+		c   managed.ExternalCreation
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		fields fields
+		args   args
+		want   want
+	}{
+		"Created": {
+			reason: "A successful create-cluster should return no error.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				c: managed.ExternalCreation{ConnectionDetails: managed.ConnectionDetails{}},
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						describeNotFound,
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("createOutput.json", nil),
+								},
+							}
+						},
+					},
+				},
+			},
+		},
+		"ValidationFailed": {
+			reason: "Validators that failed at ERROR level should be listed in the returned error.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				err: fmt.Errorf("%w: %s", ErrValidationFailed, "InstanceTypeValidator: The instance type 't2.nano' is not supported.; SubnetsValidator: The subnet 'subnet-0123' does not exist."),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						describeNotFound,
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("validationFailed.json", fmt.Errorf("exit status 1")),
+								},
+							}
+						},
+					},
+				},
+			},
+		},
+		"InvalidName": {
+			reason: "A Cluster whose name pcluster would reject should not be created.",
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					cr := makeCluster()
+					cr.SetName("test_cluster")
+					return cr
+				}(),
+			},
+			want: want{
+				err: errors.Errorf("%s %q: must start with a letter and contain only letters, digits, and hyphens", errBadName, "test_cluster"),
+			},
+		},
+		"RegionNotAllowed": {
+			reason: "A Cluster should not be created in a region the ProviderConfig doesn't allow.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				err: errors.Errorf("%s %q: the ProviderConfig only allows %s", errRegionNotAllowed, "us-east-1", "eu-west-1, eu-central-1"),
+			},
+			fields: fields{
+				allowedRegions: []string{"eu-west-1", "eu-central-1"},
+			},
+		},
+		"AlreadyExists": {
+			reason: "A cluster that already exists should not be created again.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				err: errors.Errorf("%s in %s with status %s; annotate the Cluster with %s: \"true\" to adopt it", errExists, "us-east-1", CreateFailed, annotationImport),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{fakeOutput(readFile(t, "describeCreateFailed.json"), nil)}},
+			},
+		},
+		"DescribeFailed": {
+			reason: "A cluster should not be created if whether it already exists is unknown.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				err: fmt.Errorf("failed to run pcluster command: %s: %w", "Unable to locate credentials.", errors.New("exit status 1")),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{fakeOutput(`{"message": "Unable to locate credentials."}`, errors.New("exit status 1"))}},
+			},
+		},
+		"RegionAllowed": {
+			reason: "A Cluster should be created in a region the ProviderConfig allows.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			want: want{
+				c: managed.ExternalCreation{ConnectionDetails: managed.ConnectionDetails{}},
+			},
+			fields: fields{
+				executor:       fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{describeNotFound, fakeOutput(readFile(t, "createOutput.json"), nil)}},
+				allowedRegions: []string{"eu-west-1", "us-east-1"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{executor: &tc.fields.executor, allowedRegions: tc.fields.allowedRegions, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			got, err := e.Create(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.c, got); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// syncExec allows a FakeExec to be used by concurrent commands.
+type syncExec struct {
+	mu sync.Mutex
+	fakeexec.FakeExec
+}
+
+func (e *syncExec) CommandContext(ctx context.Context, cmd string, args ...string) k8sexec.Cmd {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.FakeExec.CommandContext(ctx, cmd, args...)
+}
+
+func TestExecuteConcurrently(t *testing.T) {
+	// Each command waits until both are running, then returns the
+	// configuration written to its working directory.
+	var running sync.WaitGroup
+	running.Add(2)
+	readConfig := func(cmd string, args ...string) k8sexec.Cmd {
+		fc := &fakeexec.FakeCmd{}
+		fc.RunScript = []fakeexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				running.Done()
+				running.Wait()
+				b, err := os.ReadFile(filepath.Join(fc.Dirs[0], clusterConfigFileName))
+				return b, nil, err
+			},
+		}
+		return fc
+	}
+	executor := &syncExec{FakeExec: fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{readConfig, readConfig},
+	}}
+	e := external{executor: executor, logger: logging.NewNopLogger()}
+
+	clusters := map[string]string{"a": "Image:\n  Os: alinux2\n", "b": "Image:\n  Os: ubuntu2004\n"}
+	got := map[string]string{}
+	var mu sync.Mutex
+	var done sync.WaitGroup
+	for name, config := range clusters {
+		cr := makeCluster()
+		cr.Name = name
+		cr.Spec.ForProvider.ClusterConfiguration = config
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			out, err := e.execute(context.Background(), logging.NewNopLogger(), cr, []string{"describe-cluster"})
+			if err != nil {
+				t.Errorf("e.execute(...): %s", err)
+			}
+			mu.Lock()
+			got[cr.Name] = string(out)
+			mu.Unlock()
+		}()
+	}
+	done.Wait()
+
+	if diff := cmp.Diff(clusters, got); diff != "" {
+		t.Errorf("e.execute(...): -want configuration, +got configuration:\n%s\n", diff)
+	}
+}
+
+func TestExecuteCancelled(t *testing.T) {
+	base := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	executor := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+		func(cmd string, args ...string) k8sexec.Cmd {
+			return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{
+				func() ([]byte, []byte, error) {
+					cancel()
+					return nil, nil, ctx.Err()
+				},
+			}}
+		},
+	}}
+	e := external{executor: executor, workingDir: base, logger: logging.NewNopLogger()}
+	if _, err := e.execute(ctx, logging.NewNopLogger(), makeCluster(), []string{"describe-cluster"}); err == nil {
+		t.Fatalf("e.execute(...): want error, got nil")
+	}
+	if entries, _ := os.ReadDir(base); len(entries) != 0 {
+		t.Errorf("e.execute(...): want the temporary directory removed, got %d entries", len(entries))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	type fields struct {
+		executor fakeexec.FakeExec
+	}
+
+	type args struct {
+		ctx context.Context
+		mg  resource.Managed
+	}
+
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		fields fields
+		args   args
+		want   want
+	}{
+		"Deleted": {
+			reason: "A successful delete-cluster should return no error.",
+			args: args{
+				ctx: context.Background(),
+				mg:  makeCluster(),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("deleteOutput.json", nil),
+								},
+							}
+						},
+					},
+				},
+			},
+		},
+		"ObserveOnly": {
+			reason: "A Cluster with the ObserveOnly management policy should not be deleted.",
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					cr := makeCluster()
+					cr.Spec.ManagementPolicy = v1alpha1.ManagementObserveOnly
+					return cr
+				}(),
+			},
+		},
+		"DeletionProtected": {
+			reason: "A Cluster with deletion protection should not be deleted.",
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					cr := makeCluster()
+					cr.SetAnnotations(map[string]string{annotationDeletionProtection: "true"})
+					return cr
+				}(),
+			},
+			want: want{
+				err: errors.New(errProtected),
+			},
+		},
+		"DeleteFailed": {
+			reason: "A Cluster whose deletion failed should not be deleted again unless retrying is requested.",
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					cr := makeCluster()
+					cr.Status.AtProvider.ClusterStatus = string(DeleteFailed)
+					cr.Status.AtProvider.FailureReason = "ComputeFleet DELETE_FAILED: Resource is in use"
+					return cr
+				}(),
+			},
+			want: want{
+				err: errors.Wrap(errors.New("ComputeFleet DELETE_FAILED: Resource is in use"), errDeleteFailed),
+			},
+		},
+		"RetryDeleteFailed": {
+			reason: "A Cluster whose deletion failed should be deleted again when retrying is requested.",
+			args: args{
+				ctx: context.Background(),
+				mg: func() resource.Managed {
+					cr := makeCluster()
+					cr.SetAnnotations(map[string]string{annotationRetryDelete: "true"})
+					cr.Status.AtProvider.ClusterStatus = string(DeleteFailed)
+					return cr
+				}(),
+			},
+			fields: fields{
+				executor: fakeexec.FakeExec{
+					CommandScript: []fakeexec.FakeCommandAction{
+						func(cmd string, args ...string) k8sexec.Cmd {
+							return &fakeexec.FakeCmd{
+								RunScript: []fakeexec.FakeAction{
+									readResourceFile("deleteOutput.json", nil),
+								},
+							}
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{executor: &tc.fields.executor, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			err := e.Delete(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if got, want := tc.fields.executor.CommandCalls, len(tc.fields.executor.CommandScript); got != want {
+				t.Errorf("\n%s\ne.Delete(...): want %d pcluster commands, got %d", tc.reason, want, got)
+			}
+		})
+	}
+}
+
+func TestDescribeClusterOutputUnmarshal(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("resources", "describeOutput.json"))
+	if err != nil {
+		t.Fatalf("couldn't read file: %s", err)
+	}
+
+	var got DescribeClusterOutput
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal(...): %s", err)
+	}
+
+	want := OutputCluster{
+		ClusterName:               "test-cluster",
+		CloudformationStackStatus: "CREATE_IN_PROGRESS",
+		CloudformationStackArn:    "arn:aws:cloudformation:us-west-2:12345:stack/test-cluster/01faf160-8bc3-11ed-9c4c-0255eea00be7",
+		ClusterStatus:             "CREATE_IN_PROGRESS",
+		Region:                    "us-west-2",
+		Version:                   "3.4.0",
+		Scheduler:                 SchedulerType{SchedulerType: "slurm"},
+	}
+	if diff := cmp.Diff(want, got.OutputCluster); diff != "" {
+		t.Errorf("json.Unmarshal(...): -want embedded OutputCluster, +got:\n%s\n", diff)
+	}
+	if got.ComputeFleetStatus != "UNKNOWN" {
+		t.Errorf("json.Unmarshal(...): want ComputeFleetStatus %q, got %q", "UNKNOWN", got.ComputeFleetStatus)
+	}
+}
+
+func TestSetDescribeStatus(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("resources", "describeOutput.json"))
+	if err != nil {
+		t.Fatalf("couldn't read file: %s", err)
+	}
+	var output DescribeClusterOutput
+	if err := json.Unmarshal(b, &output); err != nil {
+		t.Fatalf("json.Unmarshal(...): %s", err)
+	}
+
+	cr := makeCluster()
+	setDescribeStatus(output, cr)
+	if got, want := cr.Status.AtProvider.ConfigurationURL, "https://test.cluster.dot.com"; got != want {
+		t.Errorf("setDescribeStatus(...): want ConfigurationURL %q, got %q", want, got)
+	}
+	if got, want := cr.Status.AtProvider.ClusterStatus, CreateInProgress; got != want {
+		t.Errorf("setDescribeStatus(...): want ClusterStatus %q, got %q", want, got)
+	}
+	if got, want := cr.Status.AtProvider.CloudformationStackStatus, "CREATE_IN_PROGRESS"; got != want {
+		t.Errorf("setDescribeStatus(...): want CloudformationStackStatus %q, got %q", want, got)
+	}
+}
+
+func TestSchedulerStatus(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		describe string
+		config   string
+		want     v1alpha1.SchedulerType
+	}{
+		"Slurm": {
+			reason:   "A Slurm cluster's scheduler version and partitions should be recorded.",
+			describe: "describeSlurm.json",
+			config:   "slurmConfig.yaml",
+			want: v1alpha1.SchedulerType{
+				SchedulerType: "slurm",
+				Name:          "slurm",
+				Version:       "23-02-4-1",
+				Queues: []v1alpha1.SchedulerQueue{
+					{Name: "compute", ComputeResources: []string{"c5", "c5n"}},
+					{Name: "gpu", ComputeResources: []string{"g4dn"}},
+				},
+			},
+		},
+		"Batch": {
+			reason:   "An AWS Batch cluster's job queues and compute environments should be recorded.",
+			describe: "describeBatch.json",
+			config:   "batchConfig.yaml",
+			want: v1alpha1.SchedulerType{
+				SchedulerType: "awsbatch",
+				Name:          "awsbatch",
+				Version:       "3.4.0",
+				Queues:        []v1alpha1.SchedulerQueue{{Name: "batch", ComputeResources: []string{"optimal"}}},
+			},
+		},
+		"NoMetadata": {
+			reason:   "Only the scheduler type should be recorded if pcluster reports nothing else.",
+			describe: "describeOutput.json",
+			want:     v1alpha1.SchedulerType{SchedulerType: "slurm"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var output DescribeClusterOutput
+			if err := json.Unmarshal([]byte(readFile(t, tc.describe)), &output); err != nil {
+				t.Fatalf("json.Unmarshal(...): %s", err)
+			}
+			cr := makeCluster()
+			if tc.config != "" {
+				cr.Spec.ForProvider.ClusterConfiguration = readFile(t, tc.config)
+			}
+			setDescribeStatus(output, cr)
+			setSchedulerQueues(logging.NewNopLogger(), cr, cr.Spec.ForProvider.ClusterConfiguration)
+			if diff := cmp.Diff(tc.want, cr.Status.AtProvider.Scheduler); diff != "" {
+				t.Errorf("\n%s\nsetDescribeStatus(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHeadNodeIAM(t *testing.T) {
+	type want struct {
+		profile string
+		role    string
+	}
+
+	cases := map[string]struct {
+		reason   string
+		config   string
+		imported string
+		want     want
+	}{
+		"InstanceProfile": {
+			reason: "The head node's configured instance profile should be recorded.",
+			config: "HeadNode:\n  Iam:\n    InstanceProfile: arn:aws:iam::123456789012:instance-profile/hpc\n",
+			want:   want{profile: "arn:aws:iam::123456789012:instance-profile/hpc"},
+		},
+		"InstanceRole": {
+			reason: "The head node's configured instance role should be recorded.",
+			config: "HeadNode:\n  Iam:\n    InstanceRole: arn:aws:iam::123456789012:role/hpc\n",
+			want:   want{role: "arn:aws:iam::123456789012:role/hpc"},
+		},
+		"Imported": {
+			reason:   "The instance role of an imported cluster without a configuration should be recorded.",
+			imported: "HeadNode:\n  Iam:\n    InstanceRole: arn:aws:iam::123456789012:role/hpc\n",
+			want:     want{role: "arn:aws:iam::123456789012:role/hpc"},
+		},
+		"CreatedByPcluster": {
+			reason: "Nothing should be recorded when pcluster creates the head node's role.",
+			config: "HeadNode:\n  InstanceType: t3.micro\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var output DescribeClusterOutput
+			if err := json.Unmarshal([]byte(readFile(t, "describeOutput.json")), &output); err != nil {
+				t.Fatalf("json.Unmarshal(...): %s", err)
+			}
+			cr := makeCluster()
+			cr.Spec.ForProvider.ClusterConfiguration = tc.config
+			cr.Status.AtProvider.ImportedConfiguration = tc.imported
+			setDescribeStatus(output, cr)
+			setHeadNodeIAM(logging.NewNopLogger(), cr, tc.config)
+			got := want{profile: cr.Status.AtProvider.HeadNode.InstanceProfile, role: cr.Status.AtProvider.HeadNode.InstanceRole}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nsetHeadNodeIAM(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if cr.Status.AtProvider.HeadNode.InstanceID != output.HeadNode.InstanceID {
+				t.Errorf("\n%s\nsetHeadNodeIAM(...): want the described head node kept, got %+v", tc.reason, cr.Status.AtProvider.HeadNode)
+			}
+		})
+	}
+}
+
+func TestLoginNodesUnmarshal(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		input  string
+		want   LoginNodes
+	}{
+		"NotConfigured": {
+			reason: "Clusters without login nodes should have no pools.",
+			input:  `{"clusterName": "test"}`,
+			want:   nil,
+		},
+		"SinglePool": {
+			reason: "A single pool reported as an object should be unmarshalled.",
+			input:  `{"loginNodes": {"status": "active", "address": "login.example.com"}}`,
+			want:   LoginNodes{{Status: "active", Address: "login.example.com"}},
+		},
+		"MultiplePools": {
+			reason: "Pools reported as a list should be unmarshalled.",
+			input:  `{"loginNodes": [{"poolName": "a", "status": "active"}, {"poolName": "b", "status": "pending"}]}`,
+			want:   LoginNodes{{PoolName: "a", Status: "active"}, {PoolName: "b", Status: "pending"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got DescribeClusterOutput
+			if err := json.Unmarshal([]byte(tc.input), &got); err != nil {
+				t.Fatalf("\n%s\njson.Unmarshal(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got.LoginNodes); diff != "" {
+				t.Errorf("\n%s\njson.Unmarshal(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRegion(t *testing.T) {
+	cases := map[string]struct {
+		reason        string
+		region        string
+		defaultRegion string
+		want          string
+	}{
+		"Cluster": {
+			reason:        "The Cluster's region should take precedence over the default region.",
+			region:        "us-east-1",
+			defaultRegion: "eu-west-1",
+			want:          "us-east-1",
+		},
+		"Default": {
+			reason:        "The default region should be used if the Cluster has no region.",
+			defaultRegion: "eu-west-1",
+			want:          "eu-west-1",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := makeCluster()
+			cr.Spec.ForProvider.Region = tc.region
+			e := external{defaultRegion: tc.defaultRegion}
+			if got := e.region(cr); got != tc.want {
+				t.Errorf("\n%s\ne.region(...): want %q, got %q", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLateInitialize(t *testing.T) {
+	cases := map[string]struct {
+		reason        string
+		region        string
+		defaultRegion string
+		want          string
+		wantLI        bool
+	}{
+		"Region": {
+			reason:        "The default region should be late-initialized if the Cluster has no region.",
+			defaultRegion: "eu-west-1",
+			want:          "eu-west-1",
+			wantLI:        true,
+		},
+		"RegionSet": {
+			reason:        "The Cluster's region should not be overwritten by the default region.",
+			region:        "us-east-1",
+			defaultRegion: "eu-west-1",
+			want:          "us-east-1",
+		},
+		"NoDefaultRegion": {
+			reason: "Nothing should be late-initialized without a default region.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := v1alpha1.ClusterParameters{Region: tc.region}
+			if got := lateInitialize(&p, tc.defaultRegion); got != tc.wantLI {
+				t.Errorf("\n%s\nlateInitialize(...): want %t, got %t", tc.reason, tc.wantLI, got)
+			}
+			if p.Region != tc.want {
+				t.Errorf("\n%s\nlateInitialize(...): want region %q, got %q", tc.reason, tc.want, p.Region)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   int
+	}{
+		"Exited": {
+			reason: "The exit status of a command that exited should be returned.",
+			err:    fmt.Errorf("pcluster failed: %w", fakeexec.FakeExitError{Status: 2}),
+			want:   2,
+		},
+		"NotExited": {
+			reason: "-1 should be returned for a command that did not exit.",
+			err:    errors.New("cannot start pcluster"),
+			want:   -1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := exitCode(tc.err); got != tc.want {
+				t.Errorf("\n%s\nexitCode(...): want %d, got %d", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	warning := []byte("WARNING: The configuration parameter 'Imds' is deprecated\n")
+	exitErr := errors.New("exit status 1")
+
+	cases := map[string]struct {
+		reason string
+		stdout string
+		stderr []byte
+		err    error
+		want   string
+	}{
+		"Succeeded": {
+			reason: "The result on stdout should be returned without what pcluster logged to stderr.",
+			stdout: `{"clusterName": "test"}`,
+			stderr: warning,
+			want:   `{"clusterName": "test"}`,
+		},
+		"FailedWithError": {
+			reason: "The JSON error on stdout should be returned.",
+			stdout: `{"message": "Bad Request"}`,
+			stderr: warning,
+			err:    exitErr,
+			want:   `{"message": "Bad Request"}`,
+		},
+		"FailedWithTraceback": {
+			reason: "stderr should be returned if the command failed without reporting an error.",
+			stderr: []byte("Traceback (most recent call last):\nKeyError: 'Stacks'\n"),
+			err:    exitErr,
+			want:   "Traceback (most recent call last):\nKeyError: 'Stacks'\n",
+		},
+		"FailedSilently": {
+			reason: "stdout should be returned if the command failed with nothing on stderr.",
+			stdout: "unexpected",
+			err:    exitErr,
+			want:   "unexpected",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := commandOutput([]byte(tc.stdout), tc.stderr, tc.err)
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("\n%s\ncommandOutput(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		b      string
+		want   string
+	}{
+		"Short": {
+			reason: "Output no longer than the limit should be returned unchanged.",
+			b:      "error",
+			want:   "error",
+		},
+		"Long": {
+			reason: "Output longer than the limit should be cut and marked as truncated.",
+			b:      "error message",
+			want:   "error...(truncated)",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := truncate([]byte(tc.b), 5); got != tc.want {
+				t.Errorf("\n%s\ntruncate(...): want %q, got %q", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateClusterName(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		name   string
+		want   error
+	}{
+		"Valid": {
+			reason: "Letters, digits, and hyphens should be valid.",
+			name:   "test-Cluster-1",
+		},
+		"MaxLength": {
+			reason: "A name of exactly 60 characters should be valid.",
+			name:   strings.Repeat("a", 60),
+		},
+		"TooLong": {
+			reason: "A name of 61 characters should be invalid.",
+			name:   strings.Repeat("a", 61),
+			want:   errors.Errorf("%s %q: must be at most 60 characters", errBadName, strings.Repeat("a", 61)),
+		},
+		"StartsWithDigit": {
+			reason: "A name starting with a digit should be invalid.",
+			name:   "1test",
+			want:   errors.Errorf("%s %q: must start with a letter and contain only letters, digits, and hyphens", errBadName, "1test"),
+		},
+		"Underscore": {
+			reason: "A name containing an underscore should be invalid.",
+			name:   "test_cluster",
+			want:   errors.Errorf("%s %q: must start with a letter and contain only letters, digits, and hyphens", errBadName, "test_cluster"),
+		},
+		"Dot": {
+			reason: "Names may contain dots in Kubernetes, but not in pcluster.",
+			name:   "test.cluster",
+			want:   errors.Errorf("%s %q: must start with a letter and contain only letters, digits, and hyphens", errBadName, "test.cluster"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateClusterName(tc.name)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nvalidateClusterName(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateRegion(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		region  string
+		wantErr bool
+	}{
+		"Valid": {
+			reason: "A standard region should be valid.",
+			region: "us-west-2",
+		},
+		"GovCloud": {
+			reason: "A GovCloud region should be valid.",
+			region: "us-gov-west-1",
+		},
+		"Invalid": {
+			reason:  "A region without a number should be invalid.",
+			region:  "us-eastish",
+			wantErr: true,
+		},
+		"Empty": {
+			reason:  "An empty region should be invalid.",
+			region:  "",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateRegion(tc.region)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("\n%s\nvalidateRegion(%q): want error %t, got %v", tc.reason, tc.region, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGetChangeSet(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("resources", "notUpToDate.json"))
+	if err != nil {
+		t.Fatalf("couldn't read file: %s", err)
+	}
+
+	want := []v1alpha1.Change{{
+		Parameter:      "HeadNode.Ssh.AllowedIps",
+		CurrentValue:   "-",
+		RequestedValue: "512.512.512.512/32",
+	}}
+	if diff := cmp.Diff(want, getChangeSet(b)); diff != "" {
+		t.Errorf("getChangeSet(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestDryRunUpdate(t *testing.T) {
+	stale := []v1alpha1.Change{{Parameter: "HeadNode.InstanceType", CurrentValue: "t3.micro", RequestedValue: "t3.large"}}
+
+	cases := map[string]struct {
+		reason   string
+		action   fakeexec.FakeAction
+		upToDate bool
+		want     []v1alpha1.Change
+	}{
+		"NotUpToDate": {
+			reason: "The changes an update would apply should replace those previously recorded.",
+			action: readResourceFile("notUpToDate.json", errors.New("exit status 1")),
+			want: []v1alpha1.Change{{
+				Parameter:      "HeadNode.Ssh.AllowedIps",
+				CurrentValue:   "-",
+				RequestedValue: "512.512.512.512/32",
+			}},
+		},
+		"UpToDate": {
+			reason:   "The recorded changes should be cleared once the cluster is up to date.",
+			action:   readResourceFile("upToDate.json", errors.New("exit status 1")),
+			upToDate: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			executor := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+				func(cmd string, args ...string) k8sexec.Cmd {
+					return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{tc.action}}
+				},
+			}}
+			e := &external{executor: executor, logger: logging.NewNopLogger()}
+			cr := makeCluster()
+			cr.Status.AtProvider.UpdateChangeSet = stale
+			cr.Status.AtProvider.PendingChanges = stale
+			upToDate, err := e.dryRunUpdate(context.Background(), logging.NewNopLogger(), cr)
+			if err != nil {
+				t.Fatalf("\n%s\ne.dryRunUpdate(...): %s", tc.reason, err)
+			}
+			if upToDate != tc.upToDate {
+				t.Errorf("\n%s\ne.dryRunUpdate(...): want up to date %t, got %t", tc.reason, tc.upToDate, upToDate)
+			}
+			if diff := cmp.Diff(tc.want, cr.Status.AtProvider.UpdateChangeSet); diff != "" {
+				t.Errorf("\n%s\ne.dryRunUpdate(...): -want change set, +got change set:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, cr.Status.AtProvider.PendingChanges); diff != "" {
+				t.Errorf("\n%s\ne.dryRunUpdate(...): -want pending changes, +got pending changes:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestListClusters(t *testing.T) {
+	pages := []string{
+		`{"clusters": [{"clusterName": "a"}, {"clusterName": "b"}], "nextToken": "page2"}`,
+		`{"clusters": [{"clusterName": "c"}]}`,
+	}
+	var gotArgs [][]string
+	executor := fakeexec.FakeExec{}
+	for _, page := range pages {
+		page := page
+		executor.CommandScript = append(executor.CommandScript, func(cmd string, args ...string) k8sexec.Cmd {
+			gotArgs = append(gotArgs, args)
+			return &fakeexec.FakeCmd{
+				RunScript: []fakeexec.FakeAction{
+					func() ([]byte, []byte, error) { return []byte(page), nil, nil },
+				},
+			}
+		})
+	}
+
+	e := external{executor: &executor, logger: logging.NewNopLogger()}
+	got, err := e.listClusters(context.Background(), logging.NewNopLogger(), "us-east-1")
+	if err != nil {
+		t.Fatalf("e.listClusters(...): %s", err)
+	}
+	want := []OutputCluster{{ClusterName: "a"}, {ClusterName: "b"}, {ClusterName: "c"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("e.listClusters(...): -want, +got:\n%s\n", diff)
+	}
+	wantArgs := [][]string{
+		{"list-clusters", "--region", "us-east-1"},
+		{"list-clusters", "--region", "us-east-1", "--next-token", "page2"},
+	}
+	if diff := cmp.Diff(wantArgs, gotArgs); diff != "" {
+		t.Errorf("e.listClusters(...): -want args, +got args:\n%s\n", diff)
+	}
+}
+
+func TestResolveClusterConfiguration(t *testing.T) {
+	errBoom := errors.New("boom")
+	configMap := func(obj client.Object) error {
+		obj.(*corev1.ConfigMap).Data = map[string]string{"config.yaml": "Image:\n  Os: alinux2\n"}
+		return nil
+	}
+	withRef := func(cr *v1alpha1.Cluster) *v1alpha1.Cluster {
+		cr.Spec.ForProvider.ClusterConfiguration = ""
+		cr.Spec.ForProvider.ClusterConfigurationRef = &v1alpha1.ConfigMapKeySelector{Name: "cm", Namespace: "ns", Key: "config.yaml"}
+		return cr
+	}
+	secret := func(obj client.Object) error {
+		obj.(*corev1.Secret).Data = map[string][]byte{"config.yaml": []byte("Image:\n  CustomAmi: ami-123\n")}
+		return nil
+	}
+	withSecretRef := func(cr *v1alpha1.Cluster) *v1alpha1.Cluster {
+		cr.Spec.ForProvider.ClusterConfiguration = ""
+		cr.Spec.ForProvider.ClusterConfigurationSecretRef = &xpv1.SecretKeySelector{
+			SecretReference: xpv1.SecretReference{Name: "s", Namespace: "ns"},
+			Key:             "config.yaml",
+		}
+		return cr
+	}
+
+	type want struct {
+		config string
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Client
+		cr     *v1alpha1.Cluster
+		want   want
+	}{
+		"Inline": {
+			reason: "An inline configuration should be returned as is.",
+			cr:     makeCluster(),
+			want:   want{config: "Image:\n        Os: alinux2\n"},
+		},
+		"ConfigMap": {
+			reason: "A configuration in a ConfigMap should be returned.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil, configMap)},
+			cr:     withRef(makeCluster()),
+			want:   want{config: "Image:\n  Os: alinux2\n"},
+		},
+		"ConfigMapGetError": {
+			reason: "Errors getting the ConfigMap should be returned.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			cr:     withRef(makeCluster()),
+			want:   want{err: errors.Wrap(errBoom, errGetConfigMap)},
+		},
+		"ConfigMapMissingKey": {
+			reason: "A missing ConfigMap key should return an error.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+			cr:     withRef(makeCluster()),
+			want:   want{err: errors.Errorf("%s: key %q not found in %s/%s", errGetConfigMap, "config.yaml", "ns", "cm")},
+		},
+		"Secret": {
+			reason: "A configuration in a Secret should be returned.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil, secret)},
+			cr:     withSecretRef(makeCluster()),
+			want:   want{config: "Image:\n  CustomAmi: ami-123\n"},
+		},
+		"SecretMissingKey": {
+			reason: "A missing Secret key should return an error.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil)},
+			cr:     withSecretRef(makeCluster()),
+			want:   want{err: errors.Errorf("%s: key %q not found in %s/%s", errGetSecret, "config.yaml", "ns", "s")},
+		},
+		"BothRefsSet": {
+			reason: "Setting both a ConfigMap and a Secret reference should return an error.",
+			cr:     withSecretRef(withRef(makeCluster())),
+			want:   want{err: errors.New(errConfigSource)},
+		},
+		"BothSet": {
+			reason: "Setting both an inline configuration and a reference should return an error.",
+			cr: func() *v1alpha1.Cluster {
+				cr := withRef(makeCluster())
+				cr.Spec.ForProvider.ClusterConfiguration = "Image: {}"
+				return cr
+			}(),
+			want: want{err: errors.New(errConfigSource)},
+		},
+		"URL": {
+			reason: "A configuration URL should leave nothing to resolve, as pcluster downloads it.",
+			cr: func() *v1alpha1.Cluster {
+				cr := makeCluster()
+				cr.Spec.ForProvider.ClusterConfiguration = ""
+				cr.Spec.ForProvider.ClusterConfigurationURL = "s3://bucket/cluster-config.yaml"
+				return cr
+			}(),
+		},
+		"URLAndInline": {
+			reason: "Setting both an inline configuration and a configuration URL should return an error.",
+			cr: func() *v1alpha1.Cluster {
+				cr := makeCluster()
+				cr.Spec.ForProvider.ClusterConfigurationURL = "s3://bucket/cluster-config.yaml"
+				return cr
+			}(),
+			want: want{err: errors.New(errConfigSource)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: tc.kube}
+			got, err := e.resolveClusterConfiguration(context.Background(), tc.cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.resolveClusterConfiguration(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.config, got); diff != "" {
+				t.Errorf("\n%s\ne.resolveClusterConfiguration(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStackProgress(t *testing.T) {
+	var output StackEventsOutput
+	if err := json.Unmarshal([]byte(readFile(t, "stackEventsInProgress.json")), &output); err != nil {
+		t.Fatalf("json.Unmarshal(...): %s", err)
+	}
+	// Events of an earlier, completed operation should be ignored.
+	earlier := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := append(output.Events,
+		StackEvent{LogicalResourceID: "test", ResourceStatus: "CREATE_IN_PROGRESS", Timestamp: earlier},
+		StackEvent{LogicalResourceID: "OldQueue", ResourceStatus: "DELETE_COMPLETE", Timestamp: earlier.Add(time.Minute)},
+	)
+	if got, want := stackProgress(events, "test"), "2/3 resources"; got != want {
+		t.Errorf("stackProgress(...): want %q, got %q", want, got)
+	}
+}
+
+func TestLatestFailureReason(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("resources", "stackEvents.json"))
+	if err != nil {
+		t.Fatalf("couldn't read file: %s", err)
+	}
+	var output StackEventsOutput
+	if err := json.Unmarshal(b, &output); err != nil {
+		t.Fatalf("json.Unmarshal(...): %s", err)
+	}
+
+	want := "HeadNode CREATE_FAILED: Your requested instance type (t2.micro) is not supported in your requested Availability Zone (us-east-1e)."
+	if got := latestFailureReason(output.Events); got != want {
+		t.Errorf("latestFailureReason(...): want %q, got %q", want, got)
+	}
+	if got := latestFailureReason(nil); got != "" {
+		t.Errorf("latestFailureReason(nil): want empty reason, got %q", got)
+	}
+}
+
+func TestTagArgs(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		tags   []v1alpha1.Tag
+		want   []string
+	}{
+		"NoTags": {
+			reason: "No --tags flag should be added when there are no tags.",
+			tags:   nil,
+			want:   []string{},
+		},
+		"SimpleTags": {
+			reason: "Each tag should be passed as its own --tags flag.",
+			tags:   []v1alpha1.Tag{{Key: "team", Value: "hpc"}, {Key: "env", Value: "dev"}},
+			want:   []string{"--tags", "Key=team,Value=hpc", "--tags", "Key=env,Value=dev"},
+		},
+		"SpecialCharacters": {
+			reason: "Keys and values containing shorthand syntax characters should be quoted.",
+			tags:   []v1alpha1.Tag{{Key: "cost center", Value: `a,b="c"`}},
+			want:   []string{"--tags", `Key="cost center",Value="a,b=\"c\""`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tagArgs(tc.tags)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ntagArgs(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSetComputeFleetStatus(t *testing.T) {
+	type want struct {
+		status      string
+		lastUpdated string
+	}
+
+	cases := map[string]struct {
+		reason string
+		action fakeexec.FakeAction
+		want   want
+	}{
+		"Described": {
+			reason: "The compute fleet's status and when it last changed should be recorded.",
+			action: readResourceFile("describeComputeFleet.json", nil),
+			want:   want{status: FleetStopRequested, lastUpdated: "2023-09-12T16:20:31Z"},
+		},
+		"Failed": {
+			reason: "The status should be left as is if the compute fleet cannot be described.",
+			action: readResourceFile("notFound.json", fmt.Errorf("exit status 1")),
+			want:   want{status: FleetRunning},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			executor := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+				func(cmd string, args ...string) k8sexec.Cmd {
+					return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{tc.action}}
+				},
+			}}
+			cr := makeCluster()
+			cr.Status.AtProvider.ComputeFleetStatus = FleetRunning
+			e := external{executor: executor, logger: logging.NewNopLogger()}
+			e.setComputeFleetStatus(context.Background(), logging.NewNopLogger(), cr)
+			got := want{status: cr.Status.AtProvider.ComputeFleetStatus, lastUpdated: cr.Status.AtProvider.ComputeFleetLastUpdatedTime}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ne.setComputeFleetStatus(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFleetState(t *testing.T) {
+	type want struct {
+		upToDate    bool
+		converging  bool
+		needsUpdate bool
+	}
+
+	cases := map[string]struct {
+		reason   string
+		desired  string
+		observed FleetStatus
+		want     want
+	}{
+		"Unmanaged": {
+			reason:   "A fleet whose state isn't managed should be left as is.",
+			observed: FleetStopRequested,
+			want:     want{upToDate: true},
+		},
+		"Running": {
+			reason:   "A running fleet should be up to date if it should be running.",
+			desired:  FleetRunning,
+			observed: FleetRunning,
+			want:     want{upToDate: true},
+		},
+		"Stop": {
+			reason:   "A running fleet should be stopped if it should be stopped.",
+			desired:  FleetStopped,
+			observed: FleetRunning,
+			want:     want{needsUpdate: true},
+		},
+		"Start": {
+			reason:   "A stopped fleet should be started if it should be running.",
+			desired:  FleetRunning,
+			observed: FleetStopped,
+			want:     want{needsUpdate: true},
+		},
+		"StartRequested": {
+			reason:   "A fleet that has been asked to start should be converging on running.",
+			desired:  FleetRunning,
+			observed: FleetStartRequested,
+			want:     want{upToDate: true, converging: true},
+		},
+		"Starting": {
+			reason:   "A starting fleet should be converging on running.",
+			desired:  FleetRunning,
+			observed: FleetStarting,
+			want:     want{upToDate: true, converging: true},
+		},
+		"Enabled": {
+			reason:   "An enabled AWS Batch fleet should be converging on running.",
+			desired:  FleetRunning,
+			observed: FleetEnabled,
+			want:     want{upToDate: true, converging: true},
+		},
+		"StopRequested": {
+			reason:   "A fleet that has been asked to stop should be converging on stopped.",
+			desired:  FleetStopped,
+			observed: FleetStopRequested,
+			want:     want{upToDate: true, converging: true},
+		},
+		"Stopping": {
+			reason:   "A stopping fleet should be converging on stopped.",
+			desired:  FleetStopped,
+			observed: FleetStopping,
+			want:     want{upToDate: true, converging: true},
+		},
+		"Disabled": {
+			reason:   "A disabled AWS Batch fleet should be converging on stopped.",
+			desired:  FleetStopped,
+			observed: FleetDisabled,
+			want:     want{upToDate: true, converging: true},
+		},
+		"StoppingButShouldRun": {
+			reason:   "A stopping fleet that should be running should be out of date, but left to stop before it is started.",
+			desired:  FleetRunning,
+			observed: FleetStopping,
+			want:     want{converging: true},
+		},
+		"StartRequestedButShouldStop": {
+			reason:   "A fleet that has been asked to start but should be stopped should be out of date, but left to start before it is stopped.",
+			desired:  FleetStopped,
+			observed: FleetStartRequested,
+			want:     want{converging: true},
+		},
+		"Unknown": {
+			reason:   "A fleet whose status is unknown should be left as is.",
+			desired:  FleetRunning,
+			observed: "UNKNOWN",
+			want:     want{upToDate: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{
+				upToDate:    fleetUpToDate(tc.desired, tc.observed),
+				converging:  isFleetConverging(tc.desired, tc.observed),
+				needsUpdate: fleetNeedsUpdate(tc.desired, tc.observed),
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nfleet state of %q wanting %q: -want, +got:\n%s\n", tc.reason, tc.observed, tc.desired, diff)
+			}
+		})
+	}
+}
+
+func TestUpdateFleetConverging(t *testing.T) {
+	for _, status := range []FleetStatus{FleetStartRequested, FleetStarting, FleetEnabled, FleetStopRequested, FleetStopping, FleetDisabled} {
+		t.Run(status, func(t *testing.T) {
+			executor := &fakeexec.FakeExec{}
+			e := &external{executor: executor, logger: logging.NewNopLogger(), recorder: &recordingRecorder{}}
+			cr := makeCluster()
+			cr.Spec.ForProvider.ComputeFleetState = FleetRunning
+			cr.Status.AtProvider.ComputeFleetStatus = status
+			if _, err := e.Update(context.Background(), cr); err != nil {
+				t.Errorf("e.Update(...) with the compute fleet %s: %s", status, err)
+			}
+			if executor.CommandCalls != 0 {
+				t.Errorf("e.Update(...) with the compute fleet %s: want no pcluster commands, got %d", status, executor.CommandCalls)
+			}
+		})
+	}
+}
+
+func TestSetInstanceCounts(t *testing.T) {
+	type want struct {
+		compute int
+		counts  []v1alpha1.InstanceCount
+	}
+
+	cases := map[string]struct {
+		reason  string
+		actions []fakeexec.FakeCommandAction
+		want    want
+	}{
+		"Described": {
+			reason: "Instances on every page should be counted by node type and state.",
+			actions: []fakeexec.FakeCommandAction{
+				func(cmd string, args ...string) k8sexec.Cmd {
+					return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("describeClusterInstances.json", nil)}}
+				},
+				fakeOutput(`{"instances": [{"instanceId": "i-0a1b2c3d4e5f60004", "state": "running", "nodeType": "ComputeNode", "queueName": "gpu"}]}`, nil),
+			},
+			want: want{
+				compute: 3,
+				counts: []v1alpha1.InstanceCount{
+					{NodeType: "ComputeNode", State: "pending", Count: 1},
+					{NodeType: "ComputeNode", State: "running", Count: 2},
+					{NodeType: "HeadNode", State: "running", Count: 1},
+				},
+			},
+		},
+		"FleetStopped": {
+			reason: "No instances should be counted while the compute fleet is stopped.",
+			actions: []fakeexec.FakeCommandAction{
+				fakeOutput(`{"instances": []}`, nil),
+			},
+			want: want{},
+		},
+		"Failed": {
+			reason: "The counts should be left as is if the instances cannot be described.",
+			actions: []fakeexec.FakeCommandAction{
+				fakeOutput("", errors.New("exit status 1")),
+			},
+			want: want{compute: 1, counts: []v1alpha1.InstanceCount{{NodeType: "ComputeNode", State: "running", Count: 1}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			executor := &fakeexec.FakeExec{CommandScript: tc.actions}
+			cr := makeCluster()
+			cr.Status.AtProvider.ComputeInstanceCount = 1
+			cr.Status.AtProvider.InstanceCounts = []v1alpha1.InstanceCount{{NodeType: "ComputeNode", State: "running", Count: 1}}
+			e := external{executor: executor, logger: logging.NewNopLogger()}
+			e.setInstanceCounts(context.Background(), logging.NewNopLogger(), cr)
+			got := want{compute: cr.Status.AtProvider.ComputeInstanceCount, counts: cr.Status.AtProvider.InstanceCounts}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ne.setInstanceCounts(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if executor.CommandCalls != len(tc.actions) {
+				t.Errorf("\n%s\ne.setInstanceCounts(...): want %d pcluster commands, got %d", tc.reason, len(tc.actions), executor.CommandCalls)
+			}
+		})
+	}
+}
+
+func TestValidateYAML(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		config string
+		want   error
+	}{
+		"Valid": {
+			reason: "A well-formed configuration should be valid.",
+			config: "Image:\n  Os: alinux2\nHeadNode:\n  InstanceType: t3.medium\n",
+		},
+		"Empty": {
+			reason: "An empty configuration is left for pcluster to reject.",
+		},
+		"NestedMapping": {
+			reason: "A syntax error should be reported with its line.",
+			config: "HeadNode:\n  InstanceType: t3.medium\n  Ssh: KeyName: test\n",
+			want:   errors.Wrap(errors.New("yaml: line 3: mapping values are not allowed in this context"), errConfigYAML),
+		},
+		"Tab": {
+			reason: "Tabs used for indentation should be reported.",
+			config: "Image:\n\tOs: alinux2\n",
+			want:   errors.Wrap(errors.New("yaml: line 2: found character that cannot start any token"), errConfigYAML),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateYAML(tc.config)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nvalidateYAML(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// recordingRecorder records the reasons of the events it is asked to emit.
+type recordingRecorder struct {
+	reasons []event.Reason
+}
+
+func (r *recordingRecorder) Event(_ runtime.Object, e event.Event) {
+	r.reasons = append(r.reasons, e.Reason)
+}
+
+func (r *recordingRecorder) WithAnnotations(_ ...string) event.Recorder {
+	return r
+}
+
+func TestRecordVersionDrift(t *testing.T) {
+	type want struct {
+		drift  string
+		events []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason   string
+		observed string
+		cli      string
+		drift    string
+		want     want
+	}{
+		"SameVersion": {
+			reason:   "No event should be emitted when the cluster and pcluster versions match.",
+			observed: "3.7.0",
+			cli:      "3.7.0",
+		},
+		"OlderCluster": {
+			reason:   "An event should be emitted when the cluster was built by an older pcluster.",
+			observed: "3.4.0",
+			cli:      "3.7.0",
+			want:     want{drift: "3.4.0 to 3.7.0", events: []event.Reason{reasonVersionDrift}},
+		},
+		"AlreadyReported": {
+			reason:   "No event should be emitted when the drift was already reported.",
+			observed: "3.4.0",
+			cli:      "3.7.0",
+			drift:    "3.4.0 to 3.7.0",
+			want:     want{drift: "3.4.0 to 3.7.0"},
+		},
+		"DriftChanged": {
+			reason:   "An event should be emitted when pcluster's version changed since the drift was reported.",
+			observed: "3.4.0",
+			cli:      "3.8.0",
+			drift:    "3.4.0 to 3.7.0",
+			want:     want{drift: "3.4.0 to 3.8.0", events: []event.Reason{reasonVersionDrift}},
+		},
+		"Resolved": {
+			reason:   "The drift should be cleared once the versions match.",
+			observed: "3.7.0",
+			cli:      "3.7.0",
+			drift:    "3.4.0 to 3.7.0",
+		},
+		"Unknown": {
+			reason: "No event should be emitted before the cluster version is known.",
+			cli:    "3.7.0",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &recordingRecorder{}
+			e := external{version: version.MustParseGeneric(tc.cli), logger: logging.NewNopLogger(), recorder: r}
+			cr := makeCluster()
+			cr.Status.AtProvider.Version = tc.observed
+			cr.Status.AtProvider.VersionDrift = tc.drift
+			e.recordVersionDrift(logging.NewNopLogger(), cr)
+			if diff := cmp.Diff(tc.want.events, r.reasons); diff != "" {
+				t.Errorf("\n%s\ne.recordVersionDrift(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+			if got := cr.Status.AtProvider.VersionDrift; got != tc.want.drift {
+				t.Errorf("\n%s\ne.recordVersionDrift(...): want drift %q, got %q", tc.reason, tc.want.drift, got)
+			}
+		})
+	}
+}
+
+func TestPreview(t *testing.T) {
+	dryRunSucceeded := `{"message": "Request would have succeeded, but DryRun flag is set."}`
+
+	cases := map[string]struct {
+		reason  string
+		cr      func(cr *v1alpha1.Cluster)
+		run     func(e *external, cr *v1alpha1.Cluster) error
+		outputs []string
+		want    []event.Reason
+		wantCS  []v1alpha1.Change
+	}{
+		"Create": {
+			reason: "A create should only be validated.",
+			run: func(e *external, cr *v1alpha1.Cluster) error {
+				_, err := e.Create(context.Background(), cr)
+				return err
+			},
+			outputs: []string{dryRunSucceeded},
+			want:    []event.Reason{reasonDryRun},
+		},
+		"Update": {
+			reason: "An update should only be validated, and its changes recorded.",
+			cr: func(cr *v1alpha1.Cluster) {
+				cr.Spec.ForProvider.ComputeFleetState = FleetStopped
+				cr.Status.AtProvider.ComputeFleetStatus = FleetRunning
+			},
+			run: func(e *external, cr *v1alpha1.Cluster) error {
+				_, err := e.Update(context.Background(), cr)
+				return err
+			},
+			outputs: []string{readFile(t, "notUpToDate.json")},
+			want:    []event.Reason{reasonDryRun, reasonDryRun},
+			wantCS: []v1alpha1.Change{{
+				Parameter:      "HeadNode.Ssh.AllowedIps",
+				RequestedValue: "512.512.512.512/32",
+				CurrentValue:   "-",
+			}},
+		},
+		"Delete": {
+			reason: "Nothing should be deleted.",
+			run: func(e *external, cr *v1alpha1.Cluster) error {
+				return e.Delete(context.Background(), cr)
+			},
+			want: []event.Reason{reasonDryRun},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var gotArgs [][]string
+			executor := &fakeexec.FakeExec{}
+			for _, output := range tc.outputs {
+				output := output
+				executor.CommandScript = append(executor.CommandScript, func(cmd string, args ...string) k8sexec.Cmd {
+					gotArgs = append(gotArgs, args)
+					return fakeOutput(output, errors.New("error"))(cmd, args...)
+				})
+			}
+			r := &recordingRecorder{}
+			e := &external{executor: executor, preview: true, logger: logging.NewNopLogger(), recorder: r}
+			cr := makeCluster()
+			if tc.cr != nil {
+				tc.cr(cr)
+			}
+
+			if err := tc.run(e, cr); err != nil {
+				t.Errorf("\n%s\n%s: %s", tc.reason, name, err)
+			}
+			if got, want := executor.CommandCalls, len(tc.outputs); got != want {
+				t.Errorf("\n%s\n%s: want %d pcluster commands, got %d", tc.reason, name, want, got)
+			}
+			for _, args := range gotArgs {
+				if !strings.Contains(strings.Join(args, " "), "--dryrun true") {
+					t.Errorf("\n%s\n%s: want only dry-run commands, got %v", tc.reason, name, args)
+				}
+			}
+			if diff := cmp.Diff(tc.want, r.reasons); diff != "" {
+				t.Errorf("\n%s\n%s: -want events, +got events:\n%s\n", tc.reason, name, diff)
+			}
+			if diff := cmp.Diff(tc.wantCS, cr.Status.AtProvider.UpdateChangeSet); diff != "" {
+				t.Errorf("\n%s\n%s: -want change set, +got change set:\n%s\n", tc.reason, name, diff)
+			}
+		})
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("resources", path))
+	if err != nil {
+		t.Fatalf("couldn't read file: %s", err)
+	}
+	return string(b)
+}
+
+func TestCreateArgs(t *testing.T) {
+	var gotArgs []string
+	var gotConfig string
+	executor := &fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			describeNotFound,
+			func(cmd string, args ...string) k8sexec.Cmd {
+				gotArgs = args
+				fc := &fakeexec.FakeCmd{}
+				fc.RunScript = []fakeexec.FakeAction{
+					func() ([]byte, []byte, error) {
+						b, err := os.ReadFile(filepath.Join(fc.Dirs[0], "hpc.yaml"))
+						gotConfig = string(b)
+						if err != nil {
+							return nil, nil, err
+						}
+						return readResourceFile("createOutput.json", nil)()
+					},
+				}
+				return fc
+			},
+		},
+	}
+	e := external{executor: executor, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.Spec.ForProvider.ClusterConfigurationFileName = "hpc.yaml"
+	cr.Spec.ForProvider.SuppressValidators = []string{"ALL"}
+	cr.Spec.ForProvider.ExtraArgs = []string{"--validation-failure-level", "WARNING"}
+
+	if _, err := e.Create(context.Background(), cr); err != nil {
+		t.Fatalf("e.Create(...): %s", err)
+	}
+	want := []string{
+		"create-cluster",
+		"--cluster-name", "test",
+		"--region", "us-east-1",
+		"--cluster-configuration", "hpc.yaml",
+		"--suppress-validators", "ALL",
+		"--validation-failure-level", "WARNING",
+	}
+	if diff := cmp.Diff(want, gotArgs); diff != "" {
+		t.Errorf("e.Create(...): -want args, +got args:\n%s\n", diff)
+	}
+	if gotConfig != cr.Spec.ForProvider.ClusterConfiguration {
+		t.Errorf("e.Create(...): want configuration written to %s, got %q", "hpc.yaml", gotConfig)
+	}
+}
+
+func TestCreateConfigurationURL(t *testing.T) {
+	var gotArgs []string
+	var gotFiles []os.DirEntry
+	executor := &fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			describeNotFound,
+			func(cmd string, args ...string) k8sexec.Cmd {
+				gotArgs = args
+				fc := &fakeexec.FakeCmd{}
+				fc.RunScript = []fakeexec.FakeAction{
+					func() ([]byte, []byte, error) {
+						files, err := os.ReadDir(fc.Dirs[0])
+						gotFiles = files
+						if err != nil {
+							return nil, nil, err
+						}
+						return readResourceFile("createOutput.json", nil)()
+					},
+				}
+				return fc
+			},
+		},
+	}
+	e := external{executor: executor, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.Spec.ForProvider.ClusterConfiguration = ""
+	cr.Spec.ForProvider.ClusterConfigurationURL = "https://example.com/configs/hpc.yaml"
+
+	if _, err := e.Create(context.Background(), cr); err != nil {
+		t.Fatalf("e.Create(...): %s", err)
+	}
+	want := []string{
+		"create-cluster",
+		"--cluster-name", "test",
+		"--region", "us-east-1",
+		"--cluster-configuration", "https://example.com/configs/hpc.yaml",
+	}
+	if diff := cmp.Diff(want, gotArgs); diff != "" {
+		t.Errorf("e.Create(...): -want args, +got args:\n%s\n", diff)
+	}
+	if len(gotFiles) != 0 {
+		t.Errorf("e.Create(...): want no configuration file written, got %d files", len(gotFiles))
+	}
+}
+
+func TestValidateConfigURL(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		p      v1alpha1.ClusterParameters
+		want   error
+	}{
+		"None": {
+			reason: "No configuration URL should be valid.",
+		},
+		"S3": {
+			reason: "An s3 URL should be valid.",
+			p:      v1alpha1.ClusterParameters{ClusterConfigurationURL: "s3://bucket/configs/hpc.yaml"},
+		},
+		"HTTPS": {
+			reason: "An https URL should be valid.",
+			p:      v1alpha1.ClusterParameters{ClusterConfigurationURL: "https://example.com/hpc.yaml?versionId=1"},
+		},
+		"HTTP": {
+			reason: "An http URL should be rejected.",
+			p:      v1alpha1.ClusterParameters{ClusterConfigurationURL: "http://example.com/hpc.yaml"},
+			want:   errors.Errorf("%s %q: must be an https:// or s3:// URL of a file", errConfigURL, "http://example.com/hpc.yaml"),
+		},
+		"NoFile": {
+			reason: "A URL of a bucket rather than a file should be rejected.",
+			p:      v1alpha1.ClusterParameters{ClusterConfigurationURL: "s3://bucket/"},
+			want:   errors.Errorf("%s %q: must be an https:// or s3:// URL of a file", errConfigURL, "s3://bucket/"),
+		},
+		"Overrides": {
+			reason: "A URL should be rejected with settings that change the configuration.",
+			p:      v1alpha1.ClusterParameters{ClusterConfigurationURL: "s3://bucket/hpc.yaml", HeadNodeSubnet: "subnet-11111111"},
+			want:   errors.Errorf("%s: configValues, configValuesSecretRef, headNodeSubnet, computeSubnets, and retainSharedStorage need the configuration itself", errConfigURL),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, validateConfigURL(tc.p), test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nvalidateConfigURL(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateArgs(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		fileName string
+		args     []string
+		want     error
+	}{
+		"Valid": {
+			reason:   "A file name and extra arguments the provider doesn't set should be valid.",
+			fileName: "hpc.yaml",
+			args:     []string{"--validation-failure-level", "WARNING"},
+		},
+		"FilePath": {
+			reason:   "A configuration file name that is a path should be rejected.",
+			fileName: "../hpc.yaml",
+			want:     errors.Errorf("%s %q: must be a file name", errBadFileName, "../hpc.yaml"),
+		},
+		"ReservedOption": {
+			reason: "An extra argument the provider sets should be rejected.",
+			args:   []string{"--region", "eu-west-1"},
+			want:   errors.Errorf("%s: --region is set by the provider", errExtraArgs),
+		},
+		"ReservedOptionWithValue": {
+			reason: "An extra argument the provider sets should be rejected when its value is joined to it.",
+			args:   []string{"--cluster-name=other"},
+			want:   errors.Errorf("%s: --cluster-name is set by the provider", errExtraArgs),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := v1alpha1.ClusterParameters{ClusterConfigurationFileName: tc.fileName, ExtraArgs: tc.args}
+			if diff := cmp.Diff(tc.want, validateArgs(p), test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nvalidateArgs(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		output string
+		want   string
+	}{
+		"JSON": {
+			reason: "The message of a JSON error should be returned.",
+			output: `{"message": "Bad Request: Cluster 'test' does not exist."}`,
+			want:   "Bad Request: Cluster 'test' does not exist.",
+		},
+		"WarningsThenJSON": {
+			reason: "The message of a JSON error should be returned when warnings precede it.",
+			output: "WARNING: pcluster 3.8.0 is deprecated\n{\n  \"message\": \"Bad Request: Invalid region.\"\n}\n",
+			want:   "Bad Request: Invalid region.",
+		},
+		"Traceback": {
+			reason: "The last line of output that isn't JSON should be returned.",
+			output: "Traceback (most recent call last):\n  File \"pcluster\", line 8, in <module>\nbotocore.exceptions.NoCredentialsError: Unable to locate credentials\n\n",
+			want:   "botocore.exceptions.NoCredentialsError: Unable to locate credentials",
+		},
+		"Empty": {
+			reason: "No output should return an empty message.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := errorMessage([]byte(tc.output)); got != tc.want {
+				t.Errorf("\n%s\nerrorMessage(...): want %q, got %q", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetErrorStatus(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		output  string
+		want    errStatus
+		wantErr error
+	}{
+		"NotFound": {
+			reason: "A not-found error should be recognized.",
+			output: readFile(t, "notFound.json"),
+			want:   errStatusNotFound,
+		},
+		"NotFoundInRegion": {
+			reason: "A not-found error that names the region should be recognized.",
+			output: readFile(t, "notFoundRegion.json"),
+			want:   errStatusNotFound,
+		},
+		"StackNotFound": {
+			reason: "A not-found error that names the stack should be recognized.",
+			output: readFile(t, "notFoundStack.json"),
+			want:   errStatusNotFound,
+		},
+		"OtherClusterNotFound": {
+			reason: "A not-found error for a cluster whose name contains this one's should not be recognized.",
+			output: `{"message": "Cluster 'test-2' does not exist in region us-east-1."}`,
+			want:   errStatusEmpty,
+		},
+		"NameAlsoInLongerName": {
+			reason: "A not-found error should be recognized when the name also appears as part of a longer one.",
+			output: `{"message": "Stack with id test-2-test does not exist; cluster 'test' does not exist."}`,
+			want:   errStatusNotFound,
+		},
+		"OtherResourceNotFound": {
+			reason: "A not-found error for another resource should not be recognized.",
+			output: `{"message": "Subnet 'subnet-0123' does not exist."}`,
+			want:   errStatusEmpty,
+		},
+		"WarningsThenJSON": {
+			reason: "A JSON error should be recognized when warnings precede it.",
+			output: "WARNING: pcluster 3.8.0 is deprecated\n{\"message\": \"Bad Request: No changes found in your cluster configuration.\"}",
+			want:   errStatusUpToDate,
+		},
+		"OtherJSON": {
+			reason: "Other JSON errors should be reported as empty.",
+			output: `{"message": "Bad Request: Invalid region."}`,
+			want:   errStatusEmpty,
+		},
+		"PlainText": {
+			reason:  "Output that isn't JSON should be reported as empty, with the output surfaced as an error.",
+			output:  "Traceback (most recent call last):\nbotocore.exceptions.NoCredentialsError: Unable to locate credentials\n",
+			want:    errStatusEmpty,
+			wantErr: errors.Errorf("%s: %s", errUnexpectedOutput, "botocore.exceptions.NoCredentialsError: Unable to locate credentials"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := getErrorStatus([]byte(tc.output), "test")
+			if got != tc.want {
+				t.Errorf("\n%s\ngetErrorStatus(...): want %q, got %q", tc.reason, tc.want, got)
+			}
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ngetErrorStatus(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveArgs(t *testing.T) {
+	var gotArgs [][]string
+	record := func(action fakeexec.FakeCommandAction) fakeexec.FakeCommandAction {
+		return func(cmd string, args ...string) k8sexec.Cmd {
+			gotArgs = append(gotArgs, args)
+			return action(cmd, args...)
+		}
+	}
+	executor := &fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			record(describeWithStatus(UpdateFailed)),
+			record(fakeOutput(readFile(t, "upToDate.json"), errors.New("error"))),
+			record(fakeOutput(readFile(t, "stackEvents.json"), nil)),
+		},
+	}
+	e := external{executor: executor, defaultRegion: "eu-west-1", logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.Spec.ForProvider.Region = ""
+
+	if _, err := e.Observe(context.Background(), cr); err != nil {
+		t.Fatalf("e.Observe(...): %s", err)
+	}
+	if len(gotArgs) != len(executor.CommandScript) {
+		t.Fatalf("e.Observe(...): want %d pcluster commands, got %d", len(executor.CommandScript), len(gotArgs))
+	}
+	for _, args := range gotArgs {
+		if !strings.Contains(strings.Join(args, " "), "--region eu-west-1") {
+			t.Errorf("e.Observe(...): want %s to be passed the cluster's region, got %v", args[0], args)
+		}
+	}
+}
+
+func TestObserveDeleted(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		policy  v1alpha1.ManagementPolicy
+		preview bool
+		want    []event.Reason
+	}{
+		"ObserveOnly": {
+			reason: "A deleted ObserveOnly Cluster should be reported as not existing, so it is finalized without deleting the cluster.",
+			policy: v1alpha1.ManagementObserveOnly,
+		},
+		"Preview": {
+			reason:  "A Cluster deleted in preview should be reported as not existing, once the deletion has been previewed.",
+			preview: true,
+			want:    []event.Reason{reasonDryRun},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fe := &fakeexec.FakeExec{}
+			r := &recordingRecorder{}
+			e := external{executor: fe, logger: logging.NewNopLogger(), recorder: r, preview: tc.preview}
+			cr := makeCluster()
+			cr.Spec.ManagementPolicy = tc.policy
+			now := metav1.Now()
+			cr.SetDeletionTimestamp(&now)
+
+			got, err := e.Observe(context.Background(), cr)
+			if err != nil {
+				t.Fatalf("\n%s\ne.Observe(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(managed.ExternalObservation{ResourceExists: false}, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, r.reasons); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+			if fe.CommandCalls != 0 {
+				t.Errorf("\n%s\ne.Observe(...): want no pcluster commands, got %d", tc.reason, fe.CommandCalls)
 			}
 		})
 	}
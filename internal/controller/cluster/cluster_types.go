@@ -1,6 +1,10 @@
 package cluster
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
 
 type OutputCluster struct {
 	ClusterName               string        `json:"clusterName"`
@@ -18,20 +22,135 @@ type Tag struct {
 }
 
 type SchedulerType struct {
-	SchedulerType string `json:"type"`
+	SchedulerType string             `json:"type"`
+	Metadata      *SchedulerMetadata `json:"metadata,omitempty"`
+}
+
+// SchedulerMetadata is only reported by describe-cluster.
+type SchedulerMetadata struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// schedulingConfiguration is the part of a cluster configuration that
+// configures its scheduler.
+type schedulingConfiguration struct {
+	Scheduling struct {
+		Scheduler      string               `yaml:"Scheduler"`
+		SlurmQueues    []queueConfiguration `yaml:"SlurmQueues"`
+		AwsBatchQueues []queueConfiguration `yaml:"AwsBatchQueues"`
+	} `yaml:"Scheduling"`
+}
+
+type headNodeConfiguration struct {
+	HeadNode struct {
+		Iam struct {
+			InstanceProfile string `yaml:"InstanceProfile"`
+			InstanceRole    string `yaml:"InstanceRole"`
+		} `yaml:"Iam"`
+	} `yaml:"HeadNode"`
+}
+
+// preflightConfiguration is the part of a cluster configuration the preflight
+// checks made before creating the cluster look at.
+type preflightConfiguration struct {
+	Image struct {
+		Os        string `yaml:"Os"`
+		CustomAmi string `yaml:"CustomAmi"`
+	} `yaml:"Image"`
+	HeadNode struct {
+		InstanceType string `yaml:"InstanceType"`
+		Image        struct {
+			CustomAmi string `yaml:"CustomAmi"`
+		} `yaml:"Image"`
+	} `yaml:"HeadNode"`
+	Scheduling struct {
+		SlurmQueues []struct {
+			Name  string `yaml:"Name"`
+			Image struct {
+				CustomAmi string `yaml:"CustomAmi"`
+			} `yaml:"Image"`
+			ComputeResources []struct {
+				Name         string `yaml:"Name"`
+				InstanceType string `yaml:"InstanceType"`
+				Instances    []struct {
+					InstanceType string `yaml:"InstanceType"`
+				} `yaml:"Instances"`
+			} `yaml:"ComputeResources"`
+		} `yaml:"SlurmQueues"`
+	} `yaml:"Scheduling"`
+}
+
+// slurmAccountingConfiguration is the part of a cluster configuration that
+// configures Slurm accounting.
+type slurmAccountingConfiguration struct {
+	Scheduling struct {
+		Scheduler     string `yaml:"Scheduler"`
+		SlurmSettings struct {
+			Database *struct {
+				URI               string `yaml:"Uri"`
+				UserName          string `yaml:"UserName"`
+				PasswordSecretArn string `yaml:"PasswordSecretArn"`
+			} `yaml:"Database"`
+			ExternalSlurmdbd *struct {
+				Host string `yaml:"Host"`
+				Port *int   `yaml:"Port"`
+			} `yaml:"ExternalSlurmdbd"`
+		} `yaml:"SlurmSettings"`
+	} `yaml:"Scheduling"`
+}
+
+type queueConfiguration struct {
+	Name             string `yaml:"Name"`
+	ComputeResources []struct {
+		Name string `yaml:"Name"`
+	} `yaml:"ComputeResources"`
+}
+
+// HeadNode is only present once the head node has been launched. The public
+// IP address is omitted when the head node is in a private subnet.
+type HeadNode struct {
+	LaunchTime       time.Time `json:"launchTime"`
+	InstanceID       string    `json:"instanceId"`
+	PublicIPAddress  string    `json:"publicIpAddress,omitempty"`
+	InstanceType     string    `json:"instanceType"`
+	State            string    `json:"state"`
+	PrivateIPAddress string    `json:"privateIpAddress"`
+}
+
+type LoginNodePool struct {
+	PoolName string `json:"poolName"`
+	Status   string `json:"status"`
+	Address  string `json:"address"`
+	Scheme   string `json:"scheme"`
+}
+
+// LoginNodes are only present when login nodes are configured. pcluster 3.7
+// reports a single pool as an object, later versions report a list of pools.
+type LoginNodes []LoginNodePool
+
+func (l *LoginNodes) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		var pool LoginNodePool
+		if err := json.Unmarshal(b, &pool); err != nil {
+			return err
+		}
+		*l = LoginNodes{pool}
+		return nil
+	}
+	var pools []LoginNodePool
+	if err := json.Unmarshal(b, &pools); err != nil {
+		return err
+	}
+	*l = pools
+	return nil
 }
 
 type DescribeClusterOutput struct {
-	OutputCluster `json:"inline"`
-	CreationTime  time.Time `json:"creationTime"`
-	HeadNode      struct {
-		LaunchTime       time.Time `json:"launchTime"`
-		InstanceID       string    `json:"instanceId"`
-		PublicIPAddress  string    `json:"publicIpAddress"`
-		InstanceType     string    `json:"instanceType"`
-		State            string    `json:"state"`
-		PrivateIPAddress string    `json:"privateIpAddress"`
-	} `json:"headNode"`
+	OutputCluster `json:",inline"`
+	CreationTime  time.Time  `json:"creationTime"`
+	HeadNode      HeadNode   `json:"headNode"`
+	LoginNodes    LoginNodes `json:"loginNodes,omitempty"`
 	//Version              string `json:"version"`
 	ClusterConfiguration struct {
 		URL string `json:"url"`
@@ -47,7 +166,8 @@ type DescribeClusterOutput struct {
 }
 
 type CreateClusterOutput struct {
-	Cluster OutputCluster `json:"cluster"`
+	Cluster            OutputCluster       `json:"cluster"`
+	ValidationMessages []ValidationMessage `json:"validationMessages,omitempty"`
 }
 
 type DeleteClusterOutput struct {
@@ -56,9 +176,104 @@ type DeleteClusterOutput struct {
 
 type UpdateClusterOutput struct {
 	Cluster   OutputCluster `json:"cluster"`
-	ChangeSet []any         `json:"changeSet,omitempty"`
+	ChangeSet []Change      `json:"changeSet,omitempty"`
 }
 
-type errorOutput struct {
+type Change struct {
+	Parameter      string      `json:"parameter"`
+	CurrentValue   changeValue `json:"currentValue"`
+	RequestedValue changeValue `json:"requestedValue"`
+}
+
+// changeValue is a configuration value in a change set. Values are usually
+// strings, but may be any JSON value, which is kept as is.
+type changeValue string
+
+func (v *changeValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = changeValue(s)
+		return nil
+	}
+	*v = changeValue(b)
+	return nil
+}
+
+type ListClustersOutput struct {
+	Clusters  []OutputCluster `json:"clusters"`
+	NextToken string          `json:"nextToken,omitempty"`
+}
+
+type StackEvent struct {
+	EventID              string    `json:"eventId"`
+	LogicalResourceID    string    `json:"logicalResourceId"`
+	ResourceType         string    `json:"resourceType"`
+	ResourceStatus       string    `json:"resourceStatus"`
+	ResourceStatusReason string    `json:"resourceStatusReason,omitempty"`
+	Timestamp            time.Time `json:"timestamp"`
+}
+
+type StackEventsOutput struct {
+	Events    []StackEvent `json:"events"`
+	NextToken string       `json:"nextToken,omitempty"`
+}
+
+type ExportClusterLogsOutput struct {
+	URL     string `json:"url"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type LogEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+type LogEventsOutput struct {
+	Events    []LogEvent `json:"events"`
+	NextToken string     `json:"nextToken,omitempty"`
+	PrevToken string     `json:"prevToken,omitempty"`
+}
+
+type DescribeComputeFleetOutput struct {
+	Status                string    `json:"status"`
+	LastStatusUpdatedTime time.Time `json:"lastStatusUpdatedTime"`
+}
+
+// ClusterInstance is an instance of a cluster. Compute instances have a queue,
+// head nodes don't.
+type ClusterInstance struct {
+	InstanceID   string    `json:"instanceId"`
+	InstanceType string    `json:"instanceType"`
+	State        string    `json:"state"`
+	NodeType     string    `json:"nodeType"`
+	QueueName    string    `json:"queueName,omitempty"`
+	LaunchTime   time.Time `json:"launchTime"`
+}
+
+type DescribeClusterInstancesOutput struct {
+	Instances []ClusterInstance `json:"instances"`
+	NextToken string            `json:"nextToken,omitempty"`
+}
+
+type UpdateComputeFleetOutput struct {
+	Status                string    `json:"status"`
+	LastStatusUpdatedTime time.Time `json:"lastStatusUpdatedTime"`
+}
+
+type ValidationMessage struct {
+	ID      string `json:"id"`
+	Level   string `json:"level"`
+	Type    string `json:"type"`
 	Message string `json:"message"`
 }
+
+type errorOutput struct {
+	Message                       string              `json:"message"`
+	ConfigurationValidationErrors []ValidationMessage `json:"configurationValidationErrors,omitempty"`
+	ValidationMessages            []ValidationMessage `json:"validationMessages,omitempty"`
+}
+
+type VersionOutput struct {
+	Version string `json:"version"`
+}
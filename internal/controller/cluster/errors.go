@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Errors that pcluster commands fail with, which callers may check for with
+// errors.Is. The errors returned wrap the command's error, so its message is
+// unchanged.
+var (
+	// ErrClusterNotFound is returned when the cluster does not exist.
+	ErrClusterNotFound = errors.New("cluster not found")
+
+	// ErrUpdateInProgress is returned when the cluster can't be changed
+	// because an operation on it is in progress.
+	ErrUpdateInProgress = errors.New("cluster operation in progress")
+
+	// ErrValidationFailed is returned when the cluster configuration fails
+	// validation at ERROR level.
+	ErrValidationFailed = errors.New(errValidation)
+
+	// ErrThrottled is returned when AWS throttled a command more often than
+	// it is retried.
+	ErrThrottled = errors.New("pcluster was throttled by AWS")
+
+	// ErrInterrupted is returned when a command was killed before it
+	// finished, because it timed out or the reconcile was cancelled, e.g.
+	// when the provider shut down. It says nothing about the cluster, so the
+	// command should just be run again.
+	ErrInterrupted = errors.New("pcluster command was interrupted")
+)
+
+// A pclusterError is the error of a failed pcluster command, of the kind of
+// failure its output reports.
+type pclusterError struct {
+	kind error
+	err  error
+}
+
+func (e *pclusterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *pclusterError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true if target is the kind of failure.
+func (e *pclusterError) Is(target error) bool {
+	return target == e.kind
+}
+
+// classifyError wraps err, the error of a failed pcluster command, in the
+// error matching the failure the command's output reports, if any.
+func classifyError(cmdOutput []byte, clusterName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var kind error
+	switch status, _ := getErrorStatus(cmdOutput, clusterName); status {
+	case errStatusNotFound:
+		kind = ErrClusterNotFound
+	case errStatusInProgress:
+		kind = ErrUpdateInProgress
+	default:
+		return err
+	}
+	return &pclusterError{kind: kind, err: err}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	cases := map[string]struct {
+		reason string
+		output string
+		want   error
+	}{
+		"NotFound": {
+			reason: "A cluster that does not exist should be reported as not found.",
+			output: `{"message": "Cluster 'test' does not exist or belongs to an incompatible ParallelCluster major version."}`,
+			want:   ErrClusterNotFound,
+		},
+		"InProgress": {
+			reason: "An operation in progress should be reported as such.",
+			output: `{"message": "Cannot execute update while stack is in UPDATE_IN_PROGRESS status."}`,
+			want:   ErrUpdateInProgress,
+		},
+		"Other": {
+			reason: "Other failures should be returned as is.",
+			output: `{"message": "Bad Request"}`,
+		},
+		"Unexpected": {
+			reason: "Output without a JSON error should be returned as is.",
+			output: "Traceback (most recent call last):\nKeyError: 'Stacks'",
+		},
+	}
+
+	kinds := []error{ErrClusterNotFound, ErrUpdateInProgress, ErrValidationFailed, ErrThrottled}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := classifyError([]byte(tc.output), "test", exitErr)
+			for _, k := range kinds {
+				if got, want := errors.Is(err, k), k == tc.want; got != want {
+					t.Errorf("\n%s\nerrors.Is(err, %q): want %t, got %t", tc.reason, k, want, got)
+				}
+			}
+			if !errors.Is(err, exitErr) {
+				t.Errorf("\n%s\nclassifyError(...): want the command's error wrapped, got %v", tc.reason, err)
+			}
+			if got, want := err.Error(), exitErr.Error(); got != want {
+				t.Errorf("\n%s\nclassifyError(...).Error(): want %q, got %q", tc.reason, want, got)
+			}
+		})
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		call   func(e *external) error
+		before []fakeexec.FakeCommandAction
+		output string
+		want   error
+	}{
+		"CreateValidationFailed": {
+			reason: "A create that fails validation should return ErrValidationFailed.",
+			call: func(e *external) error {
+				_, err := e.Create(context.Background(), makeCluster())
+				return err
+			},
+			before: []fakeexec.FakeCommandAction{describeNotFound},
+			output: readFile(t, "validationFailed.json"),
+			want:   ErrValidationFailed,
+		},
+		"DeleteNotFound": {
+			reason: "A delete of a cluster that does not exist should return ErrClusterNotFound.",
+			call: func(e *external) error {
+				return e.Delete(context.Background(), makeCluster())
+			},
+			output: `{"message": "Cluster 'test' does not exist or belongs to an incompatible ParallelCluster major version."}`,
+			want:   ErrClusterNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fe := &fakeexec.FakeExec{CommandScript: append(tc.before, fakeOutput(tc.output, errors.New("exit status 1")))}
+			e := &external{executor: fe, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			if err := tc.call(e); !errors.Is(err, tc.want) {
+				t.Errorf("\n%s\nerrors.Is(err, %q): want true, got false for %v", tc.reason, tc.want, err)
+			}
+		})
+	}
+}
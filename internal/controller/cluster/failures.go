@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	// exitCodeUsage is the exit code of a pcluster command that was passed
+	// invalid arguments.
+	exitCodeUsage = 2
+
+	// nonRetryableCooldown is how long a command that can't succeed without
+	// a change to the cluster's spec isn't retried for. A configuration
+	// referenced by the spec may change without the spec changing, so such
+	// commands are eventually retried anyway.
+	nonRetryableCooldown = 10 * time.Minute
+
+	// annotationCreateFailure records the most recent failure of
+	// create-cluster as JSON. The status Create sets is discarded when the
+	// managed reconciler persists its critical annotations, but annotations
+	// Create sets are persisted with them.
+	annotationCreateFailure = "awspcluster.crossplane.io/create-failure"
+
+	errNotRetried = "not retrying a command that failed because of the cluster's spec or configuration until the spec changes"
+)
+
+// isRetryable returns false if a failed pcluster command would fail again
+// unless the cluster's spec or configuration changes: if it was passed invalid
+// arguments, its configuration failed validation, or pcluster rejected the
+// request.
+func isRetryable(cmdOutput []byte, err error) bool {
+	if errors.Is(err, ErrInterrupted) {
+		return true
+	}
+	if exitCode(err) == exitCodeUsage {
+		return false
+	}
+	if isThrottled(cmdOutput) {
+		return true
+	}
+	pErr, ok := parseErrorOutput(cmdOutput)
+	if !ok {
+		return true
+	}
+	for _, m := range pErr.ConfigurationValidationErrors {
+		if m.Level == ValidationError {
+			return false
+		}
+	}
+	return !strings.HasPrefix(pErr.Message, "Bad Request")
+}
+
+// recordCommandFailure records the failure of a pcluster command that creates
+// or updates the cluster.
+func recordCommandFailure(cr *v1alpha1.Cluster, command string, cmdOutput []byte, err error) {
+	cr.Status.AtProvider.LastCommandFailure = &v1alpha1.CommandFailure{
+		Command:            command,
+		ExitCode:           exitCode(err),
+		Retryable:          isRetryable(cmdOutput, err),
+		Message:            errorMessage(cmdOutput),
+		ObservedGeneration: cr.GetGeneration(),
+		Time:               metav1.Now(),
+	}
+}
+
+// recordOperation records a pcluster command that changed the cluster.
+func recordOperation(cr *v1alpha1.Cluster, command string, changes int) {
+	cr.Status.AtProvider.LastOperation = &v1alpha1.Operation{
+		Command:            command,
+		Changes:            changes,
+		ObservedGeneration: cr.GetGeneration(),
+		Time:               metav1.Now(),
+	}
+}
+
+// recordCreated records the create-cluster that created the cluster, once the
+// cluster is observed. The status Create sets is discarded by the managed
+// reconciler, but the time the cluster was created is persisted.
+func recordCreated(cr *v1alpha1.Cluster) {
+	created := meta.GetExternalCreateSucceeded(cr)
+	if created.IsZero() {
+		return
+	}
+	if op := cr.Status.AtProvider.LastOperation; op != nil && !op.Time.Time.Before(created) {
+		return
+	}
+	cr.Status.AtProvider.LastOperation = &v1alpha1.Operation{
+		Command:            "create-cluster",
+		ObservedGeneration: cr.GetGeneration(),
+		Time:               metav1.NewTime(created),
+	}
+}
+
+// persistCreateFailure records the cluster's create-cluster failure in an
+// annotation, so it survives the managed reconciler discarding its status.
+func persistCreateFailure(cr *v1alpha1.Cluster) {
+	b, err := json.Marshal(cr.Status.AtProvider.LastCommandFailure)
+	if err != nil {
+		return
+	}
+	meta.AddAnnotations(cr, map[string]string{annotationCreateFailure: string(b)})
+}
+
+// restoreCreateFailure restores the create-cluster failure recorded by
+// persistCreateFailure to the status, if the most recent attempt to create the
+// cluster failed.
+func restoreCreateFailure(cr *v1alpha1.Cluster) {
+	v, ok := cr.GetAnnotations()[annotationCreateFailure]
+	if !ok {
+		return
+	}
+	failed := meta.GetExternalCreateFailed(cr)
+	if failed.IsZero() || failed.Before(meta.GetExternalCreateSucceeded(cr)) {
+		return
+	}
+	f := &v1alpha1.CommandFailure{}
+	if err := json.Unmarshal([]byte(v), f); err != nil {
+		return
+	}
+	cr.Status.AtProvider.LastCommandFailure = f
+}
+
+// clearCreateFailure forgets how creating the cluster last failed, now that it
+// has been created. It returns true if the annotation recording the failure
+// was removed, which must be persisted.
+func clearCreateFailure(cr *v1alpha1.Cluster) bool {
+	if f := cr.Status.AtProvider.LastCommandFailure; f != nil && f.Command == "create-cluster" {
+		cr.Status.AtProvider.LastCommandFailure = nil
+	}
+	if _, ok := cr.GetAnnotations()[annotationCreateFailure]; !ok {
+		return false
+	}
+	meta.RemoveAnnotations(cr, annotationCreateFailure)
+	return true
+}
+
+// notRetriedError returns an error if command last failed in a way it would
+// again, unless the cluster's spec has since changed, an update has been
+// forced, or the failure is older than nonRetryableCooldown.
+func notRetriedError(cr *v1alpha1.Cluster, command string) error {
+	f := cr.Status.AtProvider.LastCommandFailure
+	if f == nil || f.Retryable || f.Command != command || f.ObservedGeneration != cr.GetGeneration() || isForceReconcileRequested(cr) {
+		return nil
+	}
+	if time.Since(f.Time.Time) > nonRetryableCooldown {
+		return nil
+	}
+	return errors.Errorf("%s: %s", errNotRetried, f.Message)
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeexec "k8s.io/utils/exec/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		output string
+		err    error
+		want   bool
+	}{
+		"Usage": {
+			reason: "A command passed invalid arguments should not be retryable.",
+			output: "pcluster: error: unrecognized arguments: --bogus",
+			err:    fakeexec.FakeExitError{Status: 2},
+		},
+		"ValidationFailed": {
+			reason: "A command whose configuration failed validation should not be retryable.",
+			output: readFile(t, "validationFailed.json"),
+			err:    fakeexec.FakeExitError{Status: 1},
+		},
+		"BadRequest": {
+			reason: "A command pcluster rejected should not be retryable.",
+			output: `{"message": "Bad Request: Cluster name 'test' already exists."}`,
+			err:    fakeexec.FakeExitError{Status: 1},
+		},
+		"Throttled": {
+			reason: "A throttled command should be retryable.",
+			output: `{"message": "Bad Request: An error occurred (Throttling): Rate exceeded"}`,
+			err:    fakeexec.FakeExitError{Status: 1},
+			want:   true,
+		},
+		"Traceback": {
+			reason: "A command that failed unexpectedly should be retryable.",
+			output: "Traceback (most recent call last):\nKeyError: 'Stacks'",
+			err:    fakeexec.FakeExitError{Status: 1},
+			want:   true,
+		},
+		"TimedOut": {
+			reason: "A command that did not exit should be retryable.",
+			err:    errors.New("pcluster create-cluster timed out: context deadline exceeded"),
+			want:   true,
+		},
+		"Interrupted": {
+			reason: "A command that was killed should be retryable, whatever output it had written.",
+			output: `{"message": "Bad Request"}`,
+			err:    &pclusterError{kind: ErrInterrupted, err: errors.New("pcluster create-cluster was cancelled: context canceled")},
+			want:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := isRetryable([]byte(tc.output), tc.err); got != tc.want {
+				t.Errorf("\n%s\nisRetryable(...): want %t, got %t", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNotRetried(t *testing.T) {
+	failed := fakeOutput(readFile(t, "validationFailed.json"), fakeexec.FakeExitError{Status: 1})
+	fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{describeNotFound, failed, describeNotFound, failed}}
+	e := external{executor: fe, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.SetGeneration(1)
+
+	if _, err := e.Create(context.Background(), cr); err == nil {
+		t.Fatal("e.Create(...): want error, got nil")
+	}
+	f := cr.Status.AtProvider.LastCommandFailure
+	if f == nil || f.Command != "create-cluster" || f.ExitCode != 1 || f.Retryable {
+		t.Fatalf("e.Create(...): want a non-retryable create-cluster failure with exit code 1, got %+v", f)
+	}
+
+	_, err := e.Create(context.Background(), cr)
+	if err == nil || !strings.HasPrefix(err.Error(), errNotRetried) {
+		t.Errorf("e.Create(...): want the failed create not retried, got %v", err)
+	}
+	if fe.CommandCalls != 2 {
+		t.Errorf("e.Create(...): want describe-cluster and create-cluster run once, got %d runs", fe.CommandCalls)
+	}
+
+	cr.SetGeneration(2)
+	if _, err := e.Create(context.Background(), cr); err == nil || strings.HasPrefix(err.Error(), errNotRetried) {
+		t.Errorf("e.Create(...): want the create retried once the spec changed, got %v", err)
+	}
+	if fe.CommandCalls != 4 {
+		t.Errorf("e.Create(...): want describe-cluster and create-cluster run again, got %d runs", fe.CommandCalls)
+	}
+}
+
+func TestRecordOperation(t *testing.T) {
+	updated := `{"cluster": {"clusterName": "test", "clusterStatus": "UPDATE_IN_PROGRESS"}, "changeSet": [{"parameter": "HeadNode.Ssh.AllowedIps", "requestedValue": "10.0.0.0/16", "currentValue": "-"}, {"parameter": "Tags", "requestedValue": "[]", "currentValue": "-"}]}`
+
+	cases := map[string]struct {
+		reason string
+		script []fakeexec.FakeCommandAction
+		op     func(e external, cr *v1alpha1.Cluster) error
+		want   *v1alpha1.Operation
+	}{
+		"Update": {
+			reason: "A successful update-cluster should be recorded with the number of changes it applied.",
+			script: []fakeexec.FakeCommandAction{fakeOutput(updated, nil)},
+			op: func(e external, cr *v1alpha1.Cluster) error {
+				_, err := e.Update(context.Background(), cr)
+				return err
+			},
+			want: &v1alpha1.Operation{Command: "update-cluster", Changes: 2, ObservedGeneration: 3},
+		},
+		"Delete": {
+			reason: "A successful delete-cluster should be recorded.",
+			script: []fakeexec.FakeCommandAction{fakeOutput(readFile(t, "deleteOutput.json"), nil)},
+			op: func(e external, cr *v1alpha1.Cluster) error {
+				return e.Delete(context.Background(), cr)
+			},
+			want: &v1alpha1.Operation{Command: "delete-cluster", ObservedGeneration: 3},
+		},
+		"Failed": {
+			reason: "A failed command should not be recorded.",
+			script: []fakeexec.FakeCommandAction{fakeOutput(`{"message": "Bad Request"}`, fakeexec.FakeExitError{Status: 1})},
+			op: func(e external, cr *v1alpha1.Cluster) error {
+				_, err := e.Update(context.Background(), cr)
+				return err
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{executor: &fakeexec.FakeExec{CommandScript: tc.script}, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			cr := makeCluster()
+			cr.SetGeneration(3)
+			if err := tc.op(e, cr); err != nil && tc.want != nil {
+				t.Fatalf("\n%s\n%s(...): %s", tc.reason, name, err)
+			}
+			if diff := cmp.Diff(tc.want, cr.Status.AtProvider.LastOperation, cmpopts.IgnoreFields(v1alpha1.Operation{}, "Time")); diff != "" {
+				t.Errorf("\n%s\n%s(...): -want operation, +got operation:\n%s\n", tc.reason, name, diff)
+			}
+		})
+	}
+}
+
+// newTestReconciler returns a managed reconciler backed by a fake API server,
+// which connects to e, and the fake API server's client.
+func newTestReconciler(t *testing.T, e *external, cr *v1alpha1.Cluster) (*managed.Reconciler, client.Client) {
+	t.Helper()
+	s := runtime.NewScheme()
+	if err := v1alpha1.SchemeBuilder.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme(...): %s", err)
+	}
+	kube := fakeclient.NewClientBuilder().WithScheme(s).WithObjects(cr).Build()
+	r := managed.NewReconciler(&fake.Manager{Client: kube, Scheme: s},
+		resource.ManagedKind(v1alpha1.ClusterGroupVersionKind),
+		managed.WithExternalConnecter(managed.ExternalConnectorFn(func(context.Context, resource.Managed) (managed.ExternalClient, error) {
+			return e, nil
+		})),
+		managed.WithLogger(logging.NewNopLogger()),
+		managed.WithRecorder(event.NewNopRecorder()),
+	)
+	return r, kube
+}
+
+func TestNotRetriedByReconciler(t *testing.T) {
+	failed := fakeOutput(readFile(t, "validationFailed.json"), fakeexec.FakeExitError{Status: 1})
+	fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+		// The first reconcile fails to create the cluster.
+		describeNotFound, describeNotFound, failed,
+		// The second doesn't try again, as the spec hasn't changed.
+		describeNotFound,
+	}}
+	e := &external{executor: fe, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.SetGeneration(1)
+	r, kube := newTestReconciler(t, e, cr)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.GetName()}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("r.Reconcile(...): %s", err)
+		}
+	}
+	if fe.CommandCalls != 4 {
+		t.Errorf("r.Reconcile(...): want create-cluster run once, got %d pcluster commands", fe.CommandCalls)
+	}
+	got := &v1alpha1.Cluster{}
+	if err := kube.Get(context.Background(), req.NamespacedName, got); err != nil {
+		t.Fatalf("kube.Get(...): %s", err)
+	}
+	f := got.Status.AtProvider.LastCommandFailure
+	if f == nil || f.Command != "create-cluster" || f.Retryable {
+		t.Errorf("r.Reconcile(...): want the non-retryable create-cluster failure persisted, got %+v", f)
+	}
+}
+
+func TestRecordCreatedByReconciler(t *testing.T) {
+	fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+		// The first reconcile creates the cluster.
+		describeNotFound, describeNotFound, fakeOutput(readFile(t, "createOutput.json"), nil),
+		// The second observes it being created.
+		describeWithStatus(CreateInProgress), fakeOutput("", errors.New("error")),
+	}}
+	e := &external{executor: fe, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.SetGeneration(1)
+	r, kube := newTestReconciler(t, e, cr)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.GetName()}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("r.Reconcile(...): %s", err)
+		}
+	}
+	got := &v1alpha1.Cluster{}
+	if err := kube.Get(context.Background(), req.NamespacedName, got); err != nil {
+		t.Fatalf("kube.Get(...): %s", err)
+	}
+	want := &v1alpha1.Operation{Command: "create-cluster", ObservedGeneration: 1}
+	if diff := cmp.Diff(want, got.Status.AtProvider.LastOperation, cmpopts.IgnoreFields(v1alpha1.Operation{}, "Time")); diff != "" {
+		t.Errorf("r.Reconcile(...): -want operation, +got operation:\n%s\n", diff)
+	}
+}
+
+func TestClearCreateFailureByReconciler(t *testing.T) {
+	fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+		describeWithStatus(CreateInProgress), fakeOutput("", errors.New("error")),
+	}}
+	e := &external{executor: fe, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+	cr := makeCluster()
+	cr.SetAnnotations(map[string]string{annotationCreateFailure: `{"command": "create-cluster"}`})
+	r, kube := newTestReconciler(t, e, cr)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.GetName()}}
+
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("r.Reconcile(...): %s", err)
+	}
+	got := &v1alpha1.Cluster{}
+	if err := kube.Get(context.Background(), req.NamespacedName, got); err != nil {
+		t.Fatalf("kube.Get(...): %s", err)
+	}
+	if v, ok := got.GetAnnotations()[annotationCreateFailure]; ok {
+		t.Errorf("r.Reconcile(...): want the create failure annotation removed once the cluster exists, got %s", v)
+	}
+}
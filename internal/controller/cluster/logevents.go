@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	// annotationFetchLogEvents requests the most recent events of the named
+	// log stream be stored in a ConfigMap. They are fetched once for each
+	// stream it names.
+	annotationFetchLogEvents = "awspcluster.crossplane.io/fetch-log-events"
+
+	// annotationFetchLogEventsSince limits the fetched events to those since
+	// an RFC 3339 time. Changing it fetches the events again.
+	annotationFetchLogEventsSince = "awspcluster.crossplane.io/fetch-log-events-since"
+
+	reasonFetchLogEvents event.Reason = "FetchLogEvents"
+
+	// logEventsKey is the ConfigMap key the events are stored under.
+	logEventsKey = "events"
+
+	// maxLogEvents is the most events stored, and maxLogEventLength the
+	// longest. ConfigMaps are limited to 1MiB.
+	maxLogEvents      = 200
+	maxLogEventLength = 2048
+
+	// maxLogEventPages is the most pages of events read when fetching events
+	// since a time.
+	maxLogEventPages = 10
+
+	// defaultNamespace is the namespace the provider is assumed to run in if
+	// envPodNamespace is unset.
+	defaultNamespace = "crossplane-system"
+	envPodNamespace  = "POD_NAMESPACE"
+
+	errLogEventsSince = "invalid " + annotationFetchLogEventsSince + " annotation"
+	errStoreLogEvents = "cannot store log events"
+)
+
+// providerNamespace returns the namespace the provider runs in.
+func providerNamespace() string {
+	if ns := os.Getenv(envPodNamespace); ns != "" {
+		return ns
+	}
+	return defaultNamespace
+}
+
+// logEventsRequest returns the events the Cluster's annotations request, as
+// the log stream followed by the time they are requested since, if any.
+func logEventsRequest(cr *v1alpha1.Cluster) (string, bool) {
+	stream, ok := cr.GetAnnotations()[annotationFetchLogEvents]
+	if !ok {
+		return "", false
+	}
+	if since := cr.GetAnnotations()[annotationFetchLogEventsSince]; since != "" {
+		return stream + " since " + since, true
+	}
+	return stream, true
+}
+
+// reconcileLogEvents fetches the log events the Cluster's annotations request,
+// unless they were already fetched. The annotations are left in place, and
+// the request recorded in the status, so the result is persisted with it.
+func (c *external) reconcileLogEvents(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) {
+	req, ok := logEventsRequest(cr)
+	if !ok {
+		cr.Status.AtProvider.LogEventsHandled = ""
+		return
+	}
+	if req == cr.Status.AtProvider.LogEventsHandled {
+		return
+	}
+	cr.Status.AtProvider.LogEventsHandled = req
+	c.fetchLogEvents(ctx, log, cr, cr.GetAnnotations()[annotationFetchLogEvents])
+}
+
+// fetchLogEvents stores the most recent events of the cluster's log stream in
+// a ConfigMap owned by the Cluster, and records the result in the Cluster's
+// status. It never changes the cluster. Failures are recorded rather than
+// returned so a bad stream name does not block reconciliation.
+func (c *external) fetchLogEvents(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster, stream string) {
+	result := &v1alpha1.LogEvents{LogStreamName: stream, Time: metav1.Now()}
+	cr.Status.AtProvider.LogEvents = result
+
+	events, truncated, err := c.getLogEvents(ctx, log, cr, stream, cr.GetAnnotations()[annotationFetchLogEventsSince])
+	if err == nil {
+		result.ConfigMapRef, err = c.storeLogEvents(ctx, cr, events)
+	}
+	if err != nil {
+		result.Message = err.Error()
+		c.recorder.Event(cr, event.Warning(reasonFetchLogEvents, errors.Wrapf(err, "failed to fetch events of log stream %s", stream)))
+		return
+	}
+	result.Count = len(events)
+	result.Truncated = truncated
+	c.recorder.Event(cr, event.Normal(reasonFetchLogEvents, fmt.Sprintf("Stored %d events of log stream %s in ConfigMap %s/%s", len(events), stream, result.ConfigMapRef.Namespace, result.ConfigMapRef.Name)))
+}
+
+// getLogEvents returns at most maxLogEvents of the most recent events of the
+// log stream. If since is set only events since then are returned, reading
+// forward from then through at most maxLogEventPages pages. It returns true if
+// any events were dropped.
+func (c *external) getLogEvents(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster, stream, since string) ([]LogEvent, bool, error) {
+	limit := strconv.Itoa(maxLogEvents)
+	if since == "" {
+		output, err := c.execPcluster(ctx, log, "", c.pclusterArgs("get-cluster-log-events", cr, withArgs("--log-stream-name", stream, "--limit", limit))...)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get log events: %s: %w", errorMessage(output), err)
+		}
+		var eventsOutput LogEventsOutput
+		if err := json.Unmarshal(output, &eventsOutput); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal log events: %w", err)
+		}
+		return eventsOutput.Events, false, nil
+	}
+
+	if _, err := time.Parse(time.RFC3339, since); err != nil {
+		return nil, false, errors.Wrap(err, errLogEventsSince)
+	}
+	var events []LogEvent
+	truncated := false
+	token := ""
+	for page := 0; ; page++ {
+		if page == maxLogEventPages {
+			// The most recent events were not read.
+			return events, true, nil
+		}
+		args := c.pclusterArgs("get-cluster-log-events", cr, withArgs("--log-stream-name", stream, "--limit", limit))
+		if token == "" {
+			args = append(args, "--start-time", since, "--start-from-head", "true")
+		} else {
+			args = append(args, "--next-token", token)
+		}
+		output, err := c.execPcluster(ctx, log, "", args...)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get log events: %s: %w", errorMessage(output), err)
+		}
+		var eventsOutput LogEventsOutput
+		if err := json.Unmarshal(output, &eventsOutput); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal log events: %w", err)
+		}
+		events = append(events, eventsOutput.Events...)
+		if len(events) > maxLogEvents {
+			events = events[len(events)-maxLogEvents:]
+			truncated = true
+		}
+		// CloudWatch returns the same token once there are no more events.
+		if len(eventsOutput.Events) == 0 || eventsOutput.NextToken == "" || eventsOutput.NextToken == token {
+			return events, truncated, nil
+		}
+		token = eventsOutput.NextToken
+	}
+}
+
+// storeLogEvents stores the events in a ConfigMap owned by the Cluster, one
+// per line, so they are deleted with it.
+func (c *external) storeLogEvents(ctx context.Context, cr *v1alpha1.Cluster, events []LogEvent) (*v1alpha1.ConfigMapKeySelector, error) {
+	lines := make([]string, 0, len(events))
+	for _, e := range events {
+		lines = append(lines, e.Timestamp.UTC().Format(time.RFC3339)+" "+truncate([]byte(e.Message), maxLogEventLength))
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            cr.GetName() + "-log-events",
+			Namespace:       c.namespace,
+			OwnerReferences: []metav1.OwnerReference{meta.AsOwner(meta.TypedReferenceTo(cr, v1alpha1.ClusterGroupVersionKind))},
+		},
+		Data: map[string]string{logEventsKey: strings.Join(lines, "\n")},
+	}
+	if err := resource.NewAPIPatchingApplicator(c.kube).Apply(ctx, cm); err != nil {
+		return nil, errors.Wrap(err, errStoreLogEvents)
+	}
+	return &v1alpha1.ConfigMapKeySelector{Name: cm.Name, Namespace: cm.Namespace, Key: logEventsKey}, nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeexec "k8s.io/utils/exec/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestFetchLogEvents(t *testing.T) {
+	page := func(token, message string) fakeexec.FakeCommandAction {
+		return fakeOutput(`{"events": [{"timestamp": "2023-09-12T16:00:00.000Z", "message": "`+message+`"}], "nextToken": "`+token+`"}`, nil)
+	}
+
+	type want struct {
+		result *v1alpha1.LogEvents
+		data   string
+	}
+
+	cases := map[string]struct {
+		reason  string
+		since   string
+		actions []fakeexec.FakeCommandAction
+		want    want
+	}{
+		"Latest": {
+			reason:  "The most recent events should be stored in a ConfigMap.",
+			actions: []fakeexec.FakeCommandAction{page("f/1", "cloud-init started")},
+			want: want{
+				result: &v1alpha1.LogEvents{
+					LogStreamName: "ip-10-0-0-10.i-0a1b2c3d4e5f60001.cloud-init",
+					ConfigMapRef:  &v1alpha1.ConfigMapKeySelector{Name: "test-log-events", Namespace: defaultNamespace, Key: logEventsKey},
+					Count:         1,
+				},
+				data: "2023-09-12T16:00:00Z cloud-init started",
+			},
+		},
+		"Since": {
+			reason:  "Events since a time should be read page by page until the token no longer changes.",
+			since:   "2023-09-12T15:00:00Z",
+			actions: []fakeexec.FakeCommandAction{page("f/1", "first"), page("f/2", "second"), page("f/2", "third")},
+			want: want{
+				result: &v1alpha1.LogEvents{
+					LogStreamName: "ip-10-0-0-10.i-0a1b2c3d4e5f60001.cloud-init",
+					ConfigMapRef:  &v1alpha1.ConfigMapKeySelector{Name: "test-log-events", Namespace: defaultNamespace, Key: logEventsKey},
+					Count:         3,
+				},
+				data: "2023-09-12T16:00:00Z first\n2023-09-12T16:00:00Z second\n2023-09-12T16:00:00Z third",
+			},
+		},
+		"BadSince": {
+			reason: "An invalid time should be recorded without running pcluster.",
+			since:  "yesterday",
+			want: want{
+				result: &v1alpha1.LogEvents{
+					LogStreamName: "ip-10-0-0-10.i-0a1b2c3d4e5f60001.cloud-init",
+					Message:       `invalid awspcluster.crossplane.io/fetch-log-events-since annotation: parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": cannot parse "yesterday" as "2006"`,
+				},
+			},
+		},
+		"Failed": {
+			reason:  "A failure should be recorded rather than returned.",
+			actions: []fakeexec.FakeCommandAction{fakeOutput(`{"message": "Log stream not found"}`, errors.New("exit status 1"))},
+			want: want{
+				result: &v1alpha1.LogEvents{
+					LogStreamName: "ip-10-0-0-10.i-0a1b2c3d4e5f60001.cloud-init",
+					Message:       "failed to get log events: Log stream not found: exit status 1",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var data string
+			kube := &test.MockClient{
+				MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "test-log-events")),
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					cm := obj.(*corev1.ConfigMap)
+					if len(cm.OwnerReferences) != 1 || cm.OwnerReferences[0].Name != "test" {
+						t.Errorf("\n%s\ne.fetchLogEvents(...): want ConfigMap owned by the Cluster, got %v", tc.reason, cm.OwnerReferences)
+					}
+					data = cm.Data[logEventsKey]
+					return nil
+				},
+			}
+			fe := &fakeexec.FakeExec{CommandScript: tc.actions}
+			e := external{executor: fe, kube: kube, namespace: defaultNamespace, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			cr := makeCluster()
+			if tc.since != "" {
+				cr.SetAnnotations(map[string]string{annotationFetchLogEventsSince: tc.since})
+			}
+			e.fetchLogEvents(context.Background(), logging.NewNopLogger(), cr, "ip-10-0-0-10.i-0a1b2c3d4e5f60001.cloud-init")
+			if diff := cmp.Diff(tc.want.result, cr.Status.AtProvider.LogEvents, cmpopts.IgnoreFields(v1alpha1.LogEvents{}, "Time")); diff != "" {
+				t.Errorf("\n%s\ne.fetchLogEvents(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if data != tc.want.data {
+				t.Errorf("\n%s\ne.fetchLogEvents(...): want events %q, got %q", tc.reason, tc.want.data, data)
+			}
+			if fe.CommandCalls != len(tc.actions) {
+				t.Errorf("\n%s\ne.fetchLogEvents(...): want %d pcluster commands, got %d", tc.reason, len(tc.actions), fe.CommandCalls)
+			}
+		})
+	}
+}
+
+func TestReconcileLogEvents(t *testing.T) {
+	events := `{"events": [{"message": "cloud-init finished", "timestamp": "2023-01-01T00:00:00.000Z"}]}`
+
+	cases := map[string]struct {
+		reason      string
+		annotations map[string]string
+		handled     string
+		want        string
+		calls       int
+	}{
+		"Requested": {
+			reason:      "Requested events should be fetched, and the request recorded as handled.",
+			annotations: map[string]string{annotationFetchLogEvents: "cloud-init"},
+			want:        "cloud-init",
+			calls:       1,
+		},
+		"Handled": {
+			reason:      "Events already fetched should not be fetched again.",
+			annotations: map[string]string{annotationFetchLogEvents: "cloud-init"},
+			handled:     "cloud-init",
+			want:        "cloud-init",
+		},
+		"SinceChanged": {
+			reason:      "Events should be fetched again when the time they are requested since changes.",
+			annotations: map[string]string{annotationFetchLogEvents: "cloud-init", annotationFetchLogEventsSince: "2023-01-01T00:00:00Z"},
+			handled:     "cloud-init",
+			want:        "cloud-init since 2023-01-01T00:00:00Z",
+			calls:       1,
+		},
+		"Removed": {
+			reason:  "The handled request should be forgotten once the annotation is removed, so it can be made again.",
+			handled: "cloud-init",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := &test.MockClient{
+				MockGet:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "test-log-events")),
+				MockCreate: test.NewMockCreateFn(nil),
+			}
+			fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{fakeOutput(events, nil)}}
+			e := external{executor: fe, kube: kube, namespace: defaultNamespace, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			cr := makeCluster()
+			cr.SetAnnotations(tc.annotations)
+			cr.Status.AtProvider.LogEventsHandled = tc.handled
+
+			e.reconcileLogEvents(context.Background(), logging.NewNopLogger(), cr)
+			if got := cr.Status.AtProvider.LogEventsHandled; got != tc.want {
+				t.Errorf("\n%s\ne.reconcileLogEvents(...): want handled %q, got %q", tc.reason, tc.want, got)
+			}
+			if fe.CommandCalls != tc.calls {
+				t.Errorf("\n%s\ne.reconcileLogEvents(...): want %d pcluster commands, got %d", tc.reason, tc.calls, fe.CommandCalls)
+			}
+			if _, ok := cr.GetAnnotations()[annotationFetchLogEvents]; ok != (tc.annotations != nil) {
+				t.Errorf("\n%s\ne.reconcileLogEvents(...): want the annotation left in place", tc.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	// defaultLogExportTimeout bounds an export of a cluster's logs when the
+	// ProviderConfig sets no command timeout.
+	defaultLogExportTimeout = 30 * time.Minute
+
+	msgLogExportLost = "the export was interrupted, e.g. by the provider restarting; set the " + annotationExportLogs + " annotation again to retry"
+)
+
+// logExports tracks the exports of clusters' logs running in the background.
+// pcluster waits for the CloudWatch export task to finish, which can take far
+// longer than a reconcile, so exports are started by one reconcile and their
+// results recorded by a later one.
+type logExports struct {
+	mu    sync.Mutex
+	tasks map[string]*logExportTask
+}
+
+type logExportTask struct {
+	done   bool
+	result v1alpha1.LogExport
+}
+
+func newLogExports() *logExports {
+	return &logExports{tasks: map[string]*logExportTask{}}
+}
+
+// start runs export in the background for the named cluster, unless an export
+// of its logs is already running.
+func (l *logExports) start(name string, export func() v1alpha1.LogExport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.tasks[name]; ok {
+		return
+	}
+	t := &logExportTask{}
+	l.tasks[name] = t
+	go func() {
+		result := export()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		t.done, t.result = true, result
+	}()
+}
+
+// poll returns the result of the named cluster's export once it has finished,
+// forgetting the export, or nil while it is running. It returns false if no
+// export of the cluster's logs was started.
+func (l *logExports) poll(name string) (*v1alpha1.LogExport, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, ok := l.tasks[name]
+	if !ok {
+		return nil, false
+	}
+	if !t.done {
+		return nil, true
+	}
+	delete(l.tasks, name)
+	return &t.result, true
+}
+
+// reconcileLogExport records the result of an export of the cluster's logs
+// started by an earlier reconcile once it finishes, and starts exporting them
+// when the export-logs annotation names a bucket they weren't exported to.
+// Failures are recorded rather than returned so a bad bucket does not block
+// reconciliation.
+func (c *external) reconcileLogExport(log logging.Logger, cr *v1alpha1.Cluster) {
+	if c.logExports == nil {
+		return
+	}
+	s := &cr.Status.AtProvider
+	if s.LogExport != nil && s.LogExport.InProgress {
+		result, ok := c.logExports.poll(cr.Name)
+		switch {
+		case !ok:
+			s.LogExport.InProgress = false
+			s.LogExport.Message = msgLogExportLost
+			c.recorder.Event(cr, event.Warning(reasonExportLogs, errors.Errorf("failed to export logs to %s: %s", s.LogExport.Bucket, msgLogExportLost)))
+		case result == nil:
+			log.Debug("waiting for logs to be exported", "bucket", s.LogExport.Bucket)
+		case result.URL == "":
+			s.LogExport = result
+			c.recorder.Event(cr, event.Warning(reasonExportLogs, errors.Errorf("failed to export logs to %s: %s", result.Bucket, result.Message)))
+		default:
+			s.LogExport = result
+			c.recorder.Event(cr, event.Normal(reasonExportLogs, fmt.Sprintf("Exported logs to %s", result.URL)))
+		}
+	}
+
+	bucket, ok := cr.GetAnnotations()[annotationExportLogs]
+	if !ok {
+		s.LogExportHandled = ""
+		return
+	}
+	// A new bucket is exported to once the running export finishes.
+	if bucket == s.LogExportHandled || (s.LogExport != nil && s.LogExport.InProgress) {
+		return
+	}
+	s.LogExportHandled = bucket
+	s.LogExport = &v1alpha1.LogExport{Bucket: bucket, InProgress: true, Time: metav1.Now()}
+	target := cr.DeepCopy()
+	c.logExports.start(cr.Name, func() v1alpha1.LogExport {
+		return c.exportLogs(log, target, bucket)
+	})
+}
+
+// exportLogs exports the cluster's logs to the supplied S3 bucket, returning
+// the result. It is bound by the command timeout rather than the reconcile
+// that started it.
+func (c *external) exportLogs(log logging.Logger, cr *v1alpha1.Cluster, bucket string) v1alpha1.LogExport {
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = defaultLogExportTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := c.pclusterArgs("export-cluster-logs", cr, withArgs("--bucket", bucket))
+	output, err := c.execPcluster(ctx, log, "", args...)
+	result := v1alpha1.LogExport{Bucket: bucket, Time: metav1.Now()}
+	if err != nil {
+		result.Message = errorMessage(output)
+		if result.Message == "" {
+			result.Message = err.Error()
+		}
+		return result
+	}
+	var exportOutput ExportClusterLogsOutput
+	if err := json.Unmarshal(output, &exportOutput); err != nil {
+		result.Message = fmt.Sprintf("failed to unmarshal export output: %s", err)
+		return result
+	}
+	result.URL = exportOutput.URL
+	if result.URL == "" {
+		result.URL = exportOutput.Path
+	}
+	result.Message = exportOutput.Message
+	return result
+}
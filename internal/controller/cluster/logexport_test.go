@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+// waitForLogExport waits for the named cluster's export to finish, without
+// collecting its result.
+func waitForLogExport(t *testing.T, l *logExports, name string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		l.mu.Lock()
+		task, ok := l.tasks[name]
+		done := ok && task.done
+		l.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("export did not finish")
+}
+
+func TestReconcileLogExport(t *testing.T) {
+	exported := `{"path": "s3://logs/test-logs-202301010000.tar.gz", "message": "Successfully exported cluster's logs."}`
+	ignoreTime := cmpopts.IgnoreFields(v1alpha1.LogExport{}, "Time")
+
+	cases := map[string]struct {
+		reason  string
+		output  string
+		err     error
+		want    *v1alpha1.LogExport
+		reasons []event.Reason
+	}{
+		"Exported": {
+			reason:  "The export should be recorded once it finishes, with the URL of the exported logs.",
+			output:  exported,
+			want:    &v1alpha1.LogExport{Bucket: "logs", URL: "s3://logs/test-logs-202301010000.tar.gz", Message: "Successfully exported cluster's logs."},
+			reasons: []event.Reason{reasonExportLogs},
+		},
+		"Failed": {
+			reason:  "A failed export should be recorded with why it failed.",
+			output:  `{"message": "Bucket logs does not exist."}`,
+			err:     fakeexec.FakeExitError{Status: 1},
+			want:    &v1alpha1.LogExport{Bucket: "logs", Message: "Bucket logs does not exist."},
+			reasons: []event.Reason{reasonExportLogs},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{fakeOutput(tc.output, tc.err)}}
+			r := &recordingRecorder{}
+			e := external{executor: fe, logger: logging.NewNopLogger(), recorder: r, logExports: newLogExports()}
+			cr := makeCluster()
+			cr.SetAnnotations(map[string]string{annotationExportLogs: "logs"})
+
+			e.reconcileLogExport(logging.NewNopLogger(), cr)
+			if diff := cmp.Diff(&v1alpha1.LogExport{Bucket: "logs", InProgress: true}, cr.Status.AtProvider.LogExport, ignoreTime); diff != "" {
+				t.Errorf("\n%s\ne.reconcileLogExport(...): -want started export, +got:\n%s\n", tc.reason, diff)
+			}
+			waitForLogExport(t, e.logExports, cr.Name)
+
+			// Later reconciles record the result, without exporting again.
+			for i := 0; i < 2; i++ {
+				e.reconcileLogExport(logging.NewNopLogger(), cr)
+			}
+			if diff := cmp.Diff(tc.want, cr.Status.AtProvider.LogExport, ignoreTime); diff != "" {
+				t.Errorf("\n%s\ne.reconcileLogExport(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.reasons, r.reasons); diff != "" {
+				t.Errorf("\n%s\ne.reconcileLogExport(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+			if fe.CommandCalls != 1 {
+				t.Errorf("\n%s\ne.reconcileLogExport(...): want one export-cluster-logs run, got %d", tc.reason, fe.CommandCalls)
+			}
+			if cr.Status.AtProvider.LogExportHandled != "logs" {
+				t.Errorf("\n%s\ne.reconcileLogExport(...): want the annotation recorded as handled, got %q", tc.reason, cr.Status.AtProvider.LogExportHandled)
+			}
+		})
+	}
+}
+
+func TestReconcileLogExportAgain(t *testing.T) {
+	e := external{executor: &fakeexec.FakeExec{}, logger: logging.NewNopLogger(), recorder: &recordingRecorder{}, logExports: newLogExports()}
+	cr := makeCluster()
+	cr.Status.AtProvider.LogExportHandled = "logs"
+
+	// Removing the annotation allows the same bucket to be exported to again.
+	e.reconcileLogExport(logging.NewNopLogger(), cr)
+	if got := cr.Status.AtProvider.LogExportHandled; got != "" {
+		t.Errorf("e.reconcileLogExport(...): want the handled bucket forgotten, got %q", got)
+	}
+}
+
+func TestReconcileLogExportLost(t *testing.T) {
+	r := &recordingRecorder{}
+	e := external{executor: &fakeexec.FakeExec{}, logger: logging.NewNopLogger(), recorder: r, logExports: newLogExports()}
+	cr := makeCluster()
+	cr.SetAnnotations(map[string]string{annotationExportLogs: "logs"})
+	cr.Status.AtProvider.LogExportHandled = "logs"
+	cr.Status.AtProvider.LogExport = &v1alpha1.LogExport{Bucket: "logs", InProgress: true}
+
+	// The provider restarted while the export was running.
+	e.reconcileLogExport(logging.NewNopLogger(), cr)
+	want := &v1alpha1.LogExport{Bucket: "logs", Message: msgLogExportLost}
+	if diff := cmp.Diff(want, cr.Status.AtProvider.LogExport); diff != "" {
+		t.Errorf("e.reconcileLogExport(...): -want, +got:\n%s\n", diff)
+	}
+	if diff := cmp.Diff([]event.Reason{reasonExportLogs}, r.reasons); diff != "" {
+		t.Errorf("e.reconcileLogExport(...): -want events, +got events:\n%s\n", diff)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "awspcluster"
+
+	labelSubcommand = "subcommand"
+	labelRegion     = "region"
+
+	// subcommandDryRun labels update-cluster --dryrun separately from real
+	// updates, as it runs on every Observe.
+	subcommandDryRun = "dryrun"
+
+	errRegisterMetrics = "cannot register metrics"
+)
+
+// A metricsRecorder records pcluster invocations.
+type metricsRecorder interface {
+	recordCommand(subcommand, region string, d time.Duration, err error)
+}
+
+// prometheusMetrics records pcluster invocations as Prometheus metrics.
+type prometheusMetrics struct {
+	commands *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// pclusterMetrics are shared by all Cluster controllers, as metrics can only be
+// registered once.
+var pclusterMetrics = newPrometheusMetrics()
+
+func newPrometheusMetrics() *prometheusMetrics {
+	labels := []string{labelSubcommand, labelRegion}
+	return &prometheusMetrics{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "pcluster_commands_total",
+			Help:      "Number of pcluster commands run.",
+		}, labels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "pcluster_command_errors_total",
+			Help:      "Number of pcluster commands that failed.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "pcluster_command_duration_seconds",
+			Help:      "How long pcluster commands took to run.",
+			// pcluster starts a Python interpreter and calls AWS, so even
+			// the quickest commands take around a second.
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+		}, labels),
+	}
+}
+
+// register registers the metrics with r. Metrics that are already registered
+// are left as they are.
+func (m *prometheusMetrics) register(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.commands, m.failures, m.duration} {
+		if err := r.Register(c); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *prometheusMetrics) recordCommand(subcommand, region string, d time.Duration, err error) {
+	m.commands.WithLabelValues(subcommand, region).Inc()
+	if err != nil {
+		m.failures.WithLabelValues(subcommand, region).Inc()
+	}
+	m.duration.WithLabelValues(subcommand, region).Observe(d.Seconds())
+}
+
+// commandLabels returns the subcommand and region labels of the supplied
+// pcluster arguments. defaultRegion is used if no region is passed.
+func commandLabels(args []string, defaultRegion string) (string, string) {
+	subcommand, region := "", defaultRegion
+	if len(args) > 0 {
+		subcommand = args[0]
+	}
+	for i, a := range args {
+		switch {
+		case a == "--dryrun" && subcommand == "update-cluster":
+			subcommand = subcommandDryRun
+		case a == "--region" && i+1 < len(args):
+			region = args[i+1]
+		}
+	}
+	return subcommand, region
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	k8sexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	"github.com/crossplane-contrib/provider-awspcluster/internal/clients/pcluster"
+)
+
+func TestCommandLabels(t *testing.T) {
+	cases := map[string]struct {
+		reason         string
+		args           []string
+		defaultRegion  string
+		wantSubcommand string
+		wantRegion     string
+	}{
+		"Region": {
+			reason:         "The region passed to pcluster should be used.",
+			args:           []string{"delete-cluster", "--cluster-name", "test", "--region", "us-east-1"},
+			defaultRegion:  "eu-west-1",
+			wantSubcommand: "delete-cluster",
+			wantRegion:     "us-east-1",
+		},
+		"DefaultRegion": {
+			reason:         "The default region should be used if no region is passed to pcluster.",
+			args:           []string{"describe-cluster", "--cluster-name", "test"},
+			defaultRegion:  "eu-west-1",
+			wantSubcommand: "describe-cluster",
+			wantRegion:     "eu-west-1",
+		},
+		"DryRun": {
+			reason:         "A dry-run update should be labelled separately from an update.",
+			args:           []string{"update-cluster", "--dryrun", "true", "--cluster-name", "test"},
+			wantSubcommand: subcommandDryRun,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			subcommand, region := commandLabels(tc.args, tc.defaultRegion)
+			if subcommand != tc.wantSubcommand || region != tc.wantRegion {
+				t.Errorf("\n%s\ncommandLabels(...): want %q, %q, got %q, %q", tc.reason, tc.wantSubcommand, tc.wantRegion, subcommand, region)
+			}
+		})
+	}
+}
+
+func TestExecPclusterMetrics(t *testing.T) {
+	fe := &fakeexec.FakeExec{
+		CommandScript: []fakeexec.FakeCommandAction{
+			func(cmd string, args ...string) k8sexec.Cmd {
+				return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("describeOutput.json", nil)}}
+			},
+			func(cmd string, args ...string) k8sexec.Cmd {
+				return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("notFound.json", errors.New("error"))}}
+			},
+		},
+	}
+	m := newPrometheusMetrics()
+	e := &external{executor: fe, binary: pcluster.Binary, defaultRegion: "us-east-1", logger: logging.NewNopLogger(), metrics: m}
+	for i := 0; i < 2; i++ {
+		_, _ = e.execPcluster(context.Background(), e.logger, "", "describe-cluster", "--cluster-name", "test")
+	}
+
+	if got := testutil.ToFloat64(m.commands.WithLabelValues("describe-cluster", "us-east-1")); got != 2 {
+		t.Errorf("e.execPcluster(...): want 2 commands, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.failures.WithLabelValues("describe-cluster", "us-east-1")); got != 1 {
+		t.Errorf("e.execPcluster(...): want 1 failed command, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 1 {
+		t.Errorf("e.execPcluster(...): want 1 duration series, got %d", got)
+	}
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m := newPrometheusMetrics()
+	if err := m.register(r); err != nil {
+		t.Fatalf("m.register(...): %s", err)
+	}
+	if err := m.register(r); err != nil {
+		t.Errorf("m.register(...): want metrics to be registered more than once, got %s", err)
+	}
+	m.recordCommand("describe-cluster", "us-east-1", time.Second, nil)
+	if got := testutil.CollectAndCount(m.commands); got != 1 {
+		t.Errorf("m.recordCommand(...): want 1 series, got %d", got)
+	}
+}
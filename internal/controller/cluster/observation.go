@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+// An observation is what pcluster reports about a cluster during a single
+// call to Observe. The cluster is described, and its configuration resolved,
+// at most once, however many of the helpers that populate the Cluster's status
+// need them, so the work a reconcile does doesn't grow with the status.
+//
+// An observation lives only as long as the Observe that created it. The
+// cluster may change at any time, so nothing is carried over to later
+// reconciles; a new observation is made for each.
+type observation struct {
+	c   *external
+	log logging.Logger
+	cr  *v1alpha1.Cluster
+
+	described bool
+	cluster   DescribeClusterOutput
+	err       error
+
+	resolved  bool
+	config    string
+	configErr error
+}
+
+// newObservation returns an observation of the supplied Cluster.
+func (c *external) newObservation(log logging.Logger, cr *v1alpha1.Cluster) *observation {
+	return &observation{c: c, log: log, cr: cr}
+}
+
+// describe returns the cluster as described by describe-cluster, which is only
+// run the first time it's called. An error wraps ErrClusterNotFound if the
+// cluster doesn't exist.
+func (o *observation) describe(ctx context.Context) (DescribeClusterOutput, error) {
+	if !o.described {
+		o.cluster, o.err = o.describeCluster(ctx)
+		o.described = true
+	}
+	return o.cluster, o.err
+}
+
+// configuration returns the cluster's resolved configuration, which is only
+// resolved the first time it's called.
+func (o *observation) configuration(ctx context.Context) (string, error) {
+	if !o.resolved {
+		o.config, o.configErr = o.c.resolveClusterConfiguration(ctx, o.cr)
+		o.resolved = true
+	}
+	return o.config, o.configErr
+}
+
+func (o *observation) describeCluster(ctx context.Context) (DescribeClusterOutput, error) {
+	output, err := o.c.execPcluster(ctx, o.log, "", o.c.pclusterArgs("describe-cluster", o.cr)...)
+	if errors.Is(err, ErrInterrupted) {
+		return DescribeClusterOutput{}, fmt.Errorf("failed to run pcluster command: %w", err)
+	}
+	if err != nil {
+		// Only a reported not-found error means the cluster doesn't exist.
+		if _, sErr := getErrorStatus(output, o.cr.Name); sErr != nil {
+			return DescribeClusterOutput{}, fmt.Errorf("failed to run pcluster command: %s: %w", sErr, err)
+		}
+		return DescribeClusterOutput{}, fmt.Errorf("failed to run pcluster command: %s: %w", errorMessage(output), classifyError(output, o.cr.Name, err))
+	}
+	var cluster DescribeClusterOutput
+	if err := json.Unmarshal(output, &cluster); err != nil {
+		return DescribeClusterOutput{}, fmt.Errorf("failed to unmarshal describe response: %w", err)
+	}
+	// Unknown fields are ignored, so output of a different shape unmarshals
+	// without error but would leave the status empty.
+	if cluster.ClusterStatus == "" {
+		o.log.Info("pcluster output has an unexpected shape", "command", "describe-cluster", "output", truncate(output, maxLoggedOutput))
+		return DescribeClusterOutput{}, errors.New(errNoClusterStatus)
+	}
+	return cluster, nil
+}
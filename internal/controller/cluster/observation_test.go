@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8sexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestObservationDescribe(t *testing.T) {
+	cases := map[string]struct {
+		reason     string
+		action     fakeexec.FakeAction
+		wantStatus PClusterStatus
+		notFound   bool
+	}{
+		"Described": {
+			reason:     "The described cluster should be returned.",
+			action:     readResourceFile("describeOutput.json", nil),
+			wantStatus: CreateInProgress,
+		},
+		"NotFound": {
+			reason:   "A cluster that doesn't exist should be reported as not found.",
+			action:   readResourceFile("notFound.json", errors.New("exit status 1")),
+			notFound: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			executor := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+				func(cmd string, args ...string) k8sexec.Cmd {
+					return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{tc.action}}
+				},
+			}}
+			e := &external{executor: executor, logger: logging.NewNopLogger()}
+			obs := e.newObservation(logging.NewNopLogger(), makeCluster())
+
+			// Every helper sharing the observation should see the same
+			// result, from a single describe-cluster.
+			for i := 0; i < 3; i++ {
+				got, err := obs.describe(context.Background())
+				if notFound := errors.Is(err, ErrClusterNotFound); notFound != tc.notFound {
+					t.Errorf("\n%s\nobs.describe(...): want not found %t, got error %v", tc.reason, tc.notFound, err)
+				}
+				if got.ClusterStatus != tc.wantStatus {
+					t.Errorf("\n%s\nobs.describe(...): want status %q, got %q", tc.reason, tc.wantStatus, got.ClusterStatus)
+				}
+			}
+			if executor.CommandCalls != 1 {
+				t.Errorf("\n%s\nobs.describe(...): want 1 pcluster command, got %d", tc.reason, executor.CommandCalls)
+			}
+		})
+	}
+}
+
+func TestObservationConfiguration(t *testing.T) {
+	gets := 0
+	kube := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+		gets++
+		obj.(*corev1.ConfigMap).Data = map[string]string{"config.yaml": "Image:\n  Os: alinux2\n"}
+		return nil
+	}}
+	e := &external{kube: kube, logger: logging.NewNopLogger()}
+	cr := makeCluster()
+	cr.Spec.ForProvider.ClusterConfiguration = ""
+	cr.Spec.ForProvider.ClusterConfigurationRef = &v1alpha1.ConfigMapKeySelector{Name: "cm", Namespace: "ns", Key: "config.yaml"}
+	obs := e.newObservation(logging.NewNopLogger(), cr)
+
+	// Every helper sharing the observation should see the same configuration,
+	// from a single read of the ConfigMap.
+	for i := 0; i < 3; i++ {
+		got, err := obs.configuration(context.Background())
+		if err != nil {
+			t.Fatalf("obs.configuration(...): %s", err)
+		}
+		if want := "Image:\n  Os: alinux2\n"; got != want {
+			t.Errorf("obs.configuration(...): want %q, got %q", want, got)
+		}
+	}
+	if gets != 1 {
+		t.Errorf("obs.configuration(...): want the ConfigMap read once, got %d", gets)
+	}
+}
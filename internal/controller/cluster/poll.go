@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+)
+
+// defaultInProgressPollInterval is how often a cluster is polled while an
+// operation is in progress, unless its ProviderConfig says otherwise.
+const defaultInProgressPollInterval = 30 * time.Second
+
+// A pollIntervalReconciler requeues Clusters at their own poll interval, if
+// they have one, rather than the controller's. Clusters with an operation in
+// progress are requeued sooner, at their ProviderConfig's in-progress poll
+// interval.
+type pollIntervalReconciler struct {
+	reconcile.Reconciler
+	kube         client.Client
+	pollInterval time.Duration
+}
+
+// Reconcile the supplied request, replacing the controller's poll interval
+// with the Cluster's. Requeues for any other reason are left as is.
+func (r *pollIntervalReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	result, err := r.Reconciler.Reconcile(ctx, req)
+	if err != nil || result.RequeueAfter != r.pollInterval {
+		return result, err
+	}
+	cr := &v1alpha1.Cluster{}
+	if err := r.kube.Get(ctx, req.NamespacedName, cr); err != nil {
+		return result, nil
+	}
+	result.RequeueAfter = pollInterval(cr, r.pollInterval)
+	if isInProgress(cr.Status.AtProvider.ClusterStatus) {
+		pc := &apisv1alpha1.ProviderConfig{}
+		if err := r.kube.Get(ctx, types.NamespacedName{Name: cr.GetProviderConfigReference().Name}, pc); err != nil {
+			pc = nil
+		}
+		if d := inProgressPollInterval(pc); d < result.RequeueAfter {
+			result.RequeueAfter = d
+		}
+	}
+	return result, nil
+}
+
+// inProgressPollInterval returns the interval at which Clusters using the
+// supplied ProviderConfig, which may be nil, are polled while an operation is
+// in progress.
+func inProgressPollInterval(pc *apisv1alpha1.ProviderConfig) time.Duration {
+	if pc != nil && pc.Spec.InProgressPollInterval != nil && pc.Spec.InProgressPollInterval.Duration > 0 {
+		return pc.Spec.InProgressPollInterval.Duration
+	}
+	return defaultInProgressPollInterval
+}
+
+// pollInterval returns the interval at which the Cluster should be polled.
+func pollInterval(cr *v1alpha1.Cluster, def time.Duration) time.Duration {
+	if o := cr.Spec.ForProvider.PollIntervalOverride; o != nil && o.Duration > 0 {
+		return o.Duration
+	}
+	return def
+}
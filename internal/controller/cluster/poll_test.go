@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+)
+
+func TestPollInterval(t *testing.T) {
+	withOverride := func(d time.Duration) *v1alpha1.Cluster {
+		cr := makeCluster()
+		cr.Spec.ForProvider.PollIntervalOverride = &metav1.Duration{Duration: d}
+		return cr
+	}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.Cluster
+		want   time.Duration
+	}{
+		"Default": {
+			reason: "The controller's poll interval should be used when there is no override.",
+			cr:     makeCluster(),
+			want:   time.Minute,
+		},
+		"Override": {
+			reason: "The Cluster's poll interval should be used when set.",
+			cr:     withOverride(10 * time.Minute),
+			want:   10 * time.Minute,
+		},
+		"NotPositive": {
+			reason: "A non-positive override should be ignored.",
+			cr:     withOverride(0),
+			want:   time.Minute,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := pollInterval(tc.cr, time.Minute); got != tc.want {
+				t.Errorf("\n%s\npollInterval(...): want %s, got %s", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPollIntervalReconciler(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type args struct {
+		status   PClusterStatus
+		interval *metav1.Duration
+		pcErr    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   time.Duration
+	}{
+		"Complete": {
+			reason: "A cluster with no operation in progress should be polled at its usual interval.",
+			args:   args{status: CreateComplete, interval: &metav1.Duration{Duration: 10 * time.Second}},
+			want:   time.Minute,
+		},
+		"InProgress": {
+			reason: "A cluster with an operation in progress should be polled at the default in-progress interval.",
+			args:   args{status: CreateInProgress},
+			want:   defaultInProgressPollInterval,
+		},
+		"Configured": {
+			reason: "A cluster with an operation in progress should be polled at its ProviderConfig's in-progress interval.",
+			args:   args{status: UpdateInProgress, interval: &metav1.Duration{Duration: 10 * time.Second}},
+			want:   10 * time.Second,
+		},
+		"Longer": {
+			reason: "An in-progress interval longer than the usual interval should be ignored.",
+			args:   args{status: DeleteInProgress, interval: &metav1.Duration{Duration: 10 * time.Minute}},
+			want:   time.Minute,
+		},
+		"NoProviderConfig": {
+			reason: "The default in-progress interval should be used if the ProviderConfig can't be read.",
+			args:   args{status: CreateInProgress, pcErr: errBoom},
+			want:   defaultInProgressPollInterval,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+				switch o := obj.(type) {
+				case *v1alpha1.Cluster:
+					makeCluster().DeepCopyInto(o)
+					o.SetProviderConfigReference(&xpv1.Reference{Name: "default"})
+					o.Status.AtProvider.ClusterStatus = tc.args.status
+				case *apisv1alpha1.ProviderConfig:
+					if tc.args.pcErr != nil {
+						return tc.args.pcErr
+					}
+					o.Spec.InProgressPollInterval = tc.args.interval
+				}
+				return nil
+			}}
+			inner := reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{RequeueAfter: time.Minute}, nil
+			})
+			r := &pollIntervalReconciler{Reconciler: inner, kube: kube, pollInterval: time.Minute}
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if got.RequeueAfter != tc.want {
+				t.Errorf("\n%s\nr.Reconcile(...): want requeue after %s, got %s", tc.reason, tc.want, got.RequeueAfter)
+			}
+		})
+	}
+}
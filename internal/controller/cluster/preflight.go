@@ -0,0 +1,203 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/clients/pcluster"
+)
+
+const (
+	reasonPreflight event.Reason = "PreflightCheck"
+
+	errPreflight = "cluster failed preflight checks"
+
+	archX86 = "x86_64"
+	archArm = "arm64"
+)
+
+// defaultOfficialImageCacheTTL is how long the official images of an OS in a
+// region are reused for. They only change when pcluster is released.
+const defaultOfficialImageCacheTTL = time.Hour
+
+// armInstanceType matches the instance types of Graviton instance families,
+// such as a1, m6g, c7gn, and r6gd, which need arm64 images.
+var armInstanceType = regexp.MustCompile(`^(a1|[a-z]+\d+g[a-z]*)\.`)
+
+// An officialImageCache caches the architectures pcluster's official images
+// support, by region and OS, so they are only listed once in a while.
+type officialImageCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]officialImagesResult
+}
+
+type officialImagesResult struct {
+	architectures map[string]bool
+	expires       time.Time
+}
+
+func newOfficialImageCache(ttl time.Duration) *officialImageCache {
+	return &officialImageCache{ttl: ttl, now: time.Now, entries: map[string]officialImagesResult{}}
+}
+
+func (c *officialImageCache) get(region, os string) (map[string]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[region+"/"+os]
+	if !ok || !c.now().Before(r.expires) {
+		return nil, false
+	}
+	return r.architectures, true
+}
+
+func (c *officialImageCache) set(region, os string, architectures map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[region+"/"+os] = officialImagesResult{architectures: architectures, expires: c.now().Add(c.ttl)}
+}
+
+// instanceArchitecture returns the architecture images for the instance type
+// must be built for.
+func instanceArchitecture(instanceType string) string {
+	if armInstanceType.MatchString(instanceType) {
+		return archArm
+	}
+	return archX86
+}
+
+// officialArchitectures returns the architectures the official images of the
+// OS in the region support.
+func (c *external) officialArchitectures(ctx context.Context, log logging.Logger, region, os string) (map[string]bool, error) {
+	if c.officialImages != nil {
+		if archs, ok := c.officialImages.get(region, os); ok {
+			return archs, nil
+		}
+	}
+	run := func(ctx context.Context, args ...string) ([]byte, error) {
+		return c.execPcluster(ctx, log, "", args...)
+	}
+	images, err := pcluster.ListOfficialImages(ctx, run, region, os, "")
+	if err != nil {
+		return nil, err
+	}
+	archs := map[string]bool{}
+	for _, i := range images {
+		archs[i.Architecture] = true
+	}
+	if c.officialImages != nil {
+		c.officialImages.set(region, os, archs)
+	}
+	return archs, nil
+}
+
+// preflight checks the cluster's configuration for problems that would only
+// make pcluster fail part way through creating the cluster, such as an
+// instance type no official image can run on. The checks are best effort: any
+// that can't be made are skipped. pcluster has no way to read service quotas,
+// so they aren't checked. Each problem is emitted as an event, and only
+// returned as an error if the cluster asks for strict preflight checks.
+func (c *external) preflight(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) error {
+	config, err := c.clusterConfiguration(ctx, cr)
+	if err != nil {
+		// Creating the cluster will report why.
+		log.Debug("cannot resolve configuration for preflight checks", "error", err)
+		return nil
+	}
+	problems := c.preflightProblems(ctx, log, cr, config)
+	for _, p := range problems {
+		c.recorder.Event(cr, event.Warning(reasonPreflight, errors.New(p)))
+	}
+	if len(problems) == 0 || !cr.Spec.ForProvider.StrictPreflight {
+		return nil
+	}
+	return errors.Errorf("%s: %s", errPreflight, strings.Join(problems, "; "))
+}
+
+func (c *external) preflightProblems(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster, config string) []string {
+	var pc preflightConfiguration
+	if err := yaml.Unmarshal([]byte(config), &pc); err != nil {
+		log.Debug("cannot parse configuration for preflight checks", "error", err)
+		return nil
+	}
+	if pc.Image.Os == "" || pc.Image.CustomAmi != "" {
+		return nil
+	}
+
+	// Instance types that use a custom AMI, rather than an official image,
+	// can't be checked.
+	needs := map[string]string{}
+	if t := pc.HeadNode.InstanceType; t != "" && pc.HeadNode.Image.CustomAmi == "" {
+		needs[t] = "the head node"
+	}
+	for _, q := range pc.Scheduling.SlurmQueues {
+		if q.Image.CustomAmi != "" {
+			continue
+		}
+		for _, r := range q.ComputeResources {
+			types := []string{r.InstanceType}
+			for _, i := range r.Instances {
+				types = append(types, i.InstanceType)
+			}
+			for _, t := range types {
+				if _, ok := needs[t]; t != "" && !ok {
+					needs[t] = fmt.Sprintf("compute resource %s of queue %s", r.Name, q.Name)
+				}
+			}
+		}
+	}
+	if len(needs) == 0 {
+		return nil
+	}
+
+	archs, err := c.officialArchitectures(ctx, log, c.region(cr), pc.Image.Os)
+	if err != nil {
+		log.Debug("cannot list official images for preflight checks", "error", err)
+		return nil
+	}
+	if len(archs) == 0 {
+		return []string{fmt.Sprintf("pcluster has no official %s images in %s", pc.Image.Os, c.region(cr))}
+	}
+
+	types := make([]string, 0, len(needs))
+	for t := range needs {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	var problems []string
+	for _, t := range types {
+		if arch := instanceArchitecture(t); !archs[arch] {
+			problems = append(problems, fmt.Sprintf("instance type %s of %s needs an %s image, but pcluster has no official %s %s images in %s", t, needs[t], arch, arch, pc.Image.Os, c.region(cr)))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestPreflight(t *testing.T) {
+	x86Only := `{"images": [{"amiId": "ami-0123", "os": "alinux2", "name": "aws-parallelcluster-3.7.0-amzn2-hvm-x86_64", "version": "3.7.0", "architecture": "x86_64"}]}`
+	config := `Image:
+  Os: alinux2
+HeadNode:
+  InstanceType: t3.medium
+Scheduling:
+  Scheduler: slurm
+  SlurmQueues:
+    - Name: compute
+      ComputeResources:
+        - Name: graviton
+          InstanceType: c7g.xlarge
+`
+	armProblem := "instance type c7g.xlarge of compute resource graviton of queue compute needs an arm64 image, but pcluster has no official arm64 alinux2 images in us-east-1"
+
+	type want struct {
+		err     error
+		reasons []event.Reason
+		calls   int
+	}
+
+	cases := map[string]struct {
+		reason string
+		config string
+		strict bool
+		output string
+		err    error
+		want   want
+	}{
+		"Supported": {
+			reason: "No problems should be found when official images support every instance type.",
+			config: "Image:\n  Os: alinux2\nHeadNode:\n  InstanceType: t3.medium\n",
+			output: x86Only,
+			want:   want{calls: 1},
+		},
+		"Unsupported": {
+			reason: "An instance type no official image supports should only be warned about.",
+			config: config,
+			output: x86Only,
+			want:   want{reasons: []event.Reason{reasonPreflight}, calls: 1},
+		},
+		"Strict": {
+			reason: "An instance type no official image supports should be an error if preflight checks are strict.",
+			config: config,
+			strict: true,
+			output: x86Only,
+			want: want{
+				err:     errors.Errorf("%s: %s", errPreflight, armProblem),
+				reasons: []event.Reason{reasonPreflight},
+				calls:   1,
+			},
+		},
+		"CustomAmi": {
+			reason: "Instance types using a custom AMI can't be checked, so no images should be listed.",
+			config: "Image:\n  Os: alinux2\n  CustomAmi: ami-0123\nHeadNode:\n  InstanceType: c7g.xlarge\n",
+			strict: true,
+		},
+		"NoInstanceTypes": {
+			reason: "A configuration without instance types has nothing to check.",
+			config: "Image:\n  Os: alinux2\n",
+			strict: true,
+		},
+		"ListFailed": {
+			reason: "A failure to list official images should skip the checks.",
+			config: config,
+			strict: true,
+			output: `{"message": "Unable to locate credentials."}`,
+			err:    fakeexec.FakeExitError{Status: 1},
+			want:   want{calls: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{fakeOutput(tc.output, tc.err)}}
+			r := &recordingRecorder{}
+			e := &external{executor: fe, logger: logging.NewNopLogger(), recorder: r, officialImages: newOfficialImageCache(defaultOfficialImageCacheTTL)}
+			cr := makeCluster()
+			cr.Spec.ForProvider.ClusterConfiguration = tc.config
+			cr.Spec.ForProvider.StrictPreflight = tc.strict
+
+			got := e.preflight(context.Background(), logging.NewNopLogger(), cr)
+			if diff := cmp.Diff(tc.want.err, got, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.preflight(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reasons, r.reasons); diff != "" {
+				t.Errorf("\n%s\ne.preflight(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+			if fe.CommandCalls != tc.want.calls {
+				t.Errorf("\n%s\ne.preflight(...): want %d pcluster commands, got %d", tc.reason, tc.want.calls, fe.CommandCalls)
+			}
+		})
+	}
+}
+
+func TestInstanceArchitecture(t *testing.T) {
+	cases := map[string]string{
+		"t3.medium":     archX86,
+		"c5n.18xlarge":  archX86,
+		"g5.xlarge":     archX86,
+		"a1.large":      archArm,
+		"m6g.large":     archArm,
+		"c7gn.16xlarge": archArm,
+		"r6gd.xlarge":   archArm,
+	}
+	for instanceType, want := range cases {
+		if got := instanceArchitecture(instanceType); got != want {
+			t.Errorf("instanceArchitecture(%q): want %s, got %s", instanceType, want, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	k8sexec "k8s.io/utils/exec"
+
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/clients/pcluster"
+)
+
+const (
+	// readinessCacheTTL is how long the result of a readiness check is
+	// reused for, so that frequent probes don't each run pcluster.
+	readinessCacheTTL = 10 * time.Second
+
+	// readinessTimeout bounds how long a readiness check may run pcluster.
+	readinessTimeout = 10 * time.Second
+
+	errNotRunnable = "pcluster is not runnable"
+)
+
+// A ReadinessChecker reports whether the default pcluster binary is runnable,
+// i.e. whether the virtual environment it is installed in is ready. It is both
+// a readiness check and an HTTP handler that responds with pcluster's version.
+type ReadinessChecker struct {
+	ttl     time.Duration
+	now     func() time.Time
+	version func(ctx context.Context) (*version.Version, error)
+	log     logging.Logger
+
+	mu      sync.Mutex
+	expires time.Time
+	v       *version.Version
+	err     error
+}
+
+// NewReadinessChecker returns a readiness check that succeeds when the default
+// pcluster binary runs, and fails otherwise.
+func NewReadinessChecker(log logging.Logger) *ReadinessChecker {
+	return &ReadinessChecker{
+		ttl: readinessCacheTTL,
+		now: time.Now,
+		version: func(ctx context.Context) (*version.Version, error) {
+			binary, env, err := pcluster.ResolveBinary(apisv1alpha1.ProviderConfigSpec{})
+			if err != nil {
+				return nil, err
+			}
+			ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+			defer cancel()
+			return pclusterVersion(ctx, k8sexec.New(), binary, env)
+		},
+		log: log,
+	}
+}
+
+// Check returns an error if pcluster is not runnable. It is a healthz.Checker.
+func (c *ReadinessChecker) Check(r *http.Request) error {
+	if _, err := c.check(r.Context()); err != nil {
+		return errors.Wrap(err, errNotRunnable)
+	}
+	return nil
+}
+
+// ServeHTTP responds with the version of the default pcluster binary when it
+// runs, and fails otherwise.
+func (c *ReadinessChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v, err := c.check(r.Context())
+	if err != nil {
+		http.Error(w, errors.Wrap(err, errNotRunnable).Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintf(w, "pcluster %s\n", v)
+}
+
+// check returns the version of the default pcluster binary, running it unless
+// the result of the last check is still fresh.
+func (c *ReadinessChecker) check(ctx context.Context) (*version.Version, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.now().Before(c.expires) {
+		return c.v, c.err
+	}
+	c.v, c.err = c.version(ctx)
+	c.expires = c.now().Add(c.ttl)
+	if c.err != nil {
+		c.log.Debug("pcluster is not runnable", "error", c.err)
+	}
+	return c.v, c.err
+}
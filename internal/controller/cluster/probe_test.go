@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+func TestReadinessChecker(t *testing.T) {
+	cases := map[string]struct {
+		reason     string
+		version    *version.Version
+		err        error
+		want       error
+		wantStatus int
+		wantBody   string
+	}{
+		"Runnable": {
+			reason:     "The check should succeed, and respond with pcluster's version, when pcluster runs.",
+			version:    version.MustParseGeneric("3.7.0"),
+			wantStatus: http.StatusOK,
+			wantBody:   "pcluster 3.7.0\n",
+		},
+		"NotRunnable": {
+			reason:     "The check should fail when pcluster does not run.",
+			err:        errors.New("pcluster file not found"),
+			want:       errors.Wrap(errors.New("pcluster file not found"), errNotRunnable),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "pcluster is not runnable: pcluster file not found\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			runs := 0
+			c := &ReadinessChecker{
+				ttl: time.Minute,
+				now: func() time.Time { return now },
+				version: func(_ context.Context) (*version.Version, error) {
+					runs++
+					return tc.version, tc.err
+				},
+				log: logging.NewNopLogger(),
+			}
+			for i := 0; i < 2; i++ {
+				err := c.Check(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+				if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+					t.Errorf("\n%s\nc.Check(...): -want error, +got error:\n%s\n", tc.reason, diff)
+				}
+			}
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pcluster", nil))
+			if w.Code != tc.wantStatus {
+				t.Errorf("\n%s\nc.ServeHTTP(...): want status %d, got %d", tc.reason, tc.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != tc.wantBody {
+				t.Errorf("\n%s\nc.ServeHTTP(...): want body %q, got %q", tc.reason, tc.wantBody, got)
+			}
+			if runs != 1 {
+				t.Errorf("\n%s\nc.Check(...): want pcluster run once while the result is fresh, got %d runs", tc.reason, runs)
+			}
+			now = now.Add(time.Minute)
+			_ = c.Check(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if runs != 2 {
+				t.Errorf("\n%s\nc.Check(...): want pcluster run again once the result expired, got %d runs", tc.reason, runs)
+			}
+		})
+	}
+}
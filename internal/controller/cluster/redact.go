@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"regexp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// accessKeyIDRegex matches long-term (AKIA) and temporary (ASIA) access
+	// key IDs.
+	accessKeyIDRegex = regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`)
+
+	// secretKeyRegex matches a secret access key or session token assigned
+	// to a key that names it, as in credentials files, env vars, and JSON.
+	secretKeyRegex = regexp.MustCompile(`(?i)((?:aws_)?(?:secret_?access_?key|session_?token)"?\s*[=:]\s*"?)[A-Za-z0-9/+=]+`)
+
+	// secretARNRegex matches the ARN of a Secrets Manager secret.
+	secretARNRegex = regexp.MustCompile(`arn:aws[a-z-]*:secretsmanager:[^\s"',]+`)
+)
+
+// redact masks anything in s that resembles AWS credentials or the ARN of a
+// secret.
+func redact(s string) string {
+	s = accessKeyIDRegex.ReplaceAllString(s, redacted)
+	s = secretKeyRegex.ReplaceAllString(s, "${1}"+redacted)
+	return secretARNRegex.ReplaceAllString(s, redacted)
+}
+
+// A redactingLogger redacts the messages and values it logs. pcluster's
+// arguments and output may include a cluster's configuration, which in turn
+// may include credentials.
+type redactingLogger struct {
+	logging.Logger
+}
+
+func (l redactingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.Logger.Info(redact(msg), redactValues(keysAndValues)...)
+}
+
+func (l redactingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.Logger.Debug(redact(msg), redactValues(keysAndValues)...)
+}
+
+func (l redactingLogger) WithValues(keysAndValues ...interface{}) logging.Logger {
+	return redactingLogger{Logger: l.Logger.WithValues(redactValues(keysAndValues)...)}
+}
+
+// redactValues redacts every string, byte slice, and error in keysAndValues.
+func redactValues(keysAndValues []interface{}) []interface{} {
+	out := make([]interface{}, len(keysAndValues))
+	for i, v := range keysAndValues {
+		switch t := v.(type) {
+		case string:
+			out[i] = redact(t)
+		case []byte:
+			out[i] = redact(string(t))
+		case error:
+			out[i] = redact(t.Error())
+		default:
+			out[i] = v
+		}
+	}
+	return out
+}
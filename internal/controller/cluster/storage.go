@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	errRetainSharedStorage = "cannot retain the shared storage of the cluster configuration"
+
+	deletionPolicyRetain = "Retain"
+)
+
+// sharedStorageSettings maps each type of shared storage pcluster can create
+// to its settings, and the setting that names existing storage instead.
+var sharedStorageSettings = map[string]struct{ settings, existing string }{
+	"Ebs":       {settings: "EbsSettings", existing: "VolumeId"},
+	"Efs":       {settings: "EfsSettings", existing: "FileSystemId"},
+	"FsxLustre": {settings: "FsxLustreSettings", existing: "FileSystemId"},
+}
+
+// applyRetainSharedStorage returns config with the DeletionPolicy of the shared
+// storage pcluster creates set to Retain, so it outlives the cluster. Storage
+// that sets its own policy, or that already existed, is left as is; pcluster
+// never deletes storage it didn't create. The configuration is returned
+// unchanged unless retain is true.
+func applyRetainSharedStorage(config string, retain bool) (string, error) {
+	if !retain {
+		return config, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &doc); err != nil {
+		return "", errors.Wrap(err, errConfigYAML)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", errors.Errorf("%s: configuration is not a mapping", errRetainSharedStorage)
+	}
+	storage := lookup(doc.Content[0], "SharedStorage")
+	if storage == nil || storage.Kind != yaml.SequenceNode {
+		return config, nil
+	}
+	for _, s := range storage.Content {
+		t := lookup(s, "StorageType")
+		if t == nil {
+			continue
+		}
+		ss, ok := sharedStorageSettings[t.Value]
+		if !ok {
+			continue
+		}
+		settings := mappingValue(s, ss.settings)
+		if lookup(settings, ss.existing) != nil || lookup(settings, "DeletionPolicy") != nil {
+			continue
+		}
+		setMappingValue(settings, "DeletionPolicy", &yaml.Node{Kind: yaml.ScalarNode, Value: deletionPolicyRetain})
+	}
+	out, err := encodeConfig(&doc)
+	return out, errors.Wrap(err, errRetainSharedStorage)
+}
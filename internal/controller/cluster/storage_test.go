@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestApplyRetainSharedStorage(t *testing.T) {
+	config := `Image:
+  Os: alinux2
+SharedStorage:
+  - MountDir: /scratch
+    Name: scratch
+    StorageType: FsxLustre
+    FsxLustreSettings:
+      StorageCapacity: 1200
+  - MountDir: /home
+    Name: home
+    StorageType: Efs
+  - MountDir: /data
+    Name: data
+    StorageType: Efs
+    EfsSettings:
+      FileSystemId: fs-0123456789abcdef0
+  - MountDir: /tmp-ebs
+    Name: tmp
+    StorageType: Ebs
+    EbsSettings:
+      DeletionPolicy: Delete
+`
+
+	type want struct {
+		config string
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		config string
+		retain bool
+		want   want
+	}{
+		"NotRequested": {
+			reason: "The configuration should be unchanged unless storage should be retained.",
+			config: config,
+			want:   want{config: config},
+		},
+		"Retain": {
+			reason: "Storage pcluster creates should be retained, unless it sets its own deletion policy.",
+			config: config,
+			retain: true,
+			want: want{config: `Image:
+  Os: alinux2
+SharedStorage:
+  - MountDir: /scratch
+    Name: scratch
+    StorageType: FsxLustre
+    FsxLustreSettings:
+      StorageCapacity: 1200
+      DeletionPolicy: Retain
+  - MountDir: /home
+    Name: home
+    StorageType: Efs
+    EfsSettings:
+      DeletionPolicy: Retain
+  - MountDir: /data
+    Name: data
+    StorageType: Efs
+    EfsSettings:
+      FileSystemId: fs-0123456789abcdef0
+  - MountDir: /tmp-ebs
+    Name: tmp
+    StorageType: Ebs
+    EbsSettings:
+      DeletionPolicy: Delete
+`},
+		},
+		"NoSharedStorage": {
+			reason: "A configuration without shared storage should be unchanged.",
+			config: "Image:\n  Os: alinux2\n",
+			retain: true,
+			want:   want{config: "Image:\n  Os: alinux2\n"},
+		},
+		"NotAMapping": {
+			reason: "A configuration that isn't a mapping should be rejected.",
+			config: "- alinux2\n",
+			retain: true,
+			want:   want{err: errors.Errorf("%s: configuration is not a mapping", errRetainSharedStorage)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := applyRetainSharedStorage(tc.config, tc.retain)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\napplyRetainSharedStorage(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.config, got); diff != "" {
+				t.Errorf("\n%s\napplyRetainSharedStorage(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
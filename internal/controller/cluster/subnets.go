@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	errBadSubnet       = "invalid subnet ID"
+	errSubnetOverrides = "cannot apply subnet overrides to the cluster configuration"
+)
+
+// subnetRegex matches subnet IDs, which have 8 or 17 hexadecimal digits.
+var subnetRegex = regexp.MustCompile(`^subnet-([0-9a-f]{8}|[0-9a-f]{17})$`)
+
+// validateSubnets returns an error if any of the override subnets is not a
+// subnet ID.
+func validateSubnets(p v1alpha1.ClusterParameters) error {
+	if p.HeadNodeSubnet != "" {
+		if err := validateSubnet(p.HeadNodeSubnet); err != nil {
+			return err
+		}
+	}
+	for _, s := range p.ComputeSubnets {
+		if err := validateSubnet(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSubnet returns an error if s is not a subnet ID.
+func validateSubnet(s string) error {
+	if !subnetRegex.MatchString(s) {
+		return errors.Errorf("%s %q: must match %s", errBadSubnet, s, subnetRegex)
+	}
+	return nil
+}
+
+// applySubnetOverrides returns config with the head node's subnet and every
+// queue's subnets replaced by those of the cluster, if it overrides them. The
+// configuration is returned unchanged when there are no overrides.
+func applySubnetOverrides(config string, p v1alpha1.ClusterParameters) (string, error) {
+	if p.HeadNodeSubnet == "" && len(p.ComputeSubnets) == 0 {
+		return config, nil
+	}
+	if err := validateSubnets(p); err != nil {
+		return "", err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &doc); err != nil {
+		return "", errors.Wrap(err, errConfigYAML)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", errors.Errorf("%s: configuration is not a mapping", errSubnetOverrides)
+	}
+	root := doc.Content[0]
+	if p.HeadNodeSubnet != "" {
+		networking := mappingValue(mappingValue(root, "HeadNode"), "Networking")
+		setMappingValue(networking, "SubnetId", &yaml.Node{Kind: yaml.ScalarNode, Value: p.HeadNodeSubnet})
+	}
+	if len(p.ComputeSubnets) > 0 {
+		scheduling := lookup(root, "Scheduling")
+		for _, key := range []string{"SlurmQueues", "AwsBatchQueues"} {
+			queues := lookup(scheduling, key)
+			if queues == nil || queues.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, q := range queues.Content {
+				if q.Kind != yaml.MappingNode {
+					continue
+				}
+				setMappingValue(mappingValue(q, "Networking"), "SubnetIds", subnetList(p.ComputeSubnets))
+			}
+		}
+	}
+	out, err := encodeConfig(&doc)
+	return out, errors.Wrap(err, errSubnetOverrides)
+}
+
+// encodeConfig returns the YAML of a cluster configuration, indented as the
+// examples in pcluster's documentation are.
+func encodeConfig(doc *yaml.Node) (string, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// lookup returns the value of key in the mapping m, or nil if m is not a
+// mapping or has no such key.
+func lookup(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the mapping that is the value of key in the mapping m,
+// adding an empty one if there is none.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if v := lookup(m, key); v != nil && v.Kind == yaml.MappingNode {
+		return v
+	}
+	v := &yaml.Node{Kind: yaml.MappingNode}
+	setMappingValue(m, key, v)
+	return v
+}
+
+// setMappingValue sets the value of key in the mapping m.
+func setMappingValue(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
+
+// subnetList returns a sequence of the supplied subnets.
+func subnetList(subnets []string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, s := range subnets {
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s})
+	}
+	return n
+}
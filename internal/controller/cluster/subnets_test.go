@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestApplySubnetOverrides(t *testing.T) {
+	config := `# Base configuration.
+Image:
+  Os: alinux2
+HeadNode:
+  InstanceType: t3.medium
+  Networking:
+    SubnetId: subnet-00000000
+Scheduling:
+  Scheduler: slurm
+  SlurmQueues:
+    - Name: compute
+      Networking:
+        SubnetIds:
+          - subnet-00000000
+    - Name: gpu
+`
+
+	type want struct {
+		config string
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		config string
+		p      v1alpha1.ClusterParameters
+		want   want
+	}{
+		"NoOverrides": {
+			reason: "The configuration should be unchanged when no subnets are overridden.",
+			config: config,
+			want:   want{config: config},
+		},
+		"Overrides": {
+			reason: "The head node's subnet and every queue's subnets should be replaced.",
+			config: config,
+			p: v1alpha1.ClusterParameters{
+				HeadNodeSubnet: "subnet-11111111",
+				ComputeSubnets: []string{"subnet-22222222", "subnet-0123456789abcdef0"},
+			},
+			want: want{config: `# Base configuration.
+Image:
+  Os: alinux2
+HeadNode:
+  InstanceType: t3.medium
+  Networking:
+    SubnetId: subnet-11111111
+Scheduling:
+  Scheduler: slurm
+  SlurmQueues:
+    - Name: compute
+      Networking:
+        SubnetIds:
+          - subnet-22222222
+          - subnet-0123456789abcdef0
+    - Name: gpu
+      Networking:
+        SubnetIds:
+          - subnet-22222222
+          - subnet-0123456789abcdef0
+`},
+		},
+		"MissingHeadNodeNetworking": {
+			reason: "The head node's networking should be added if the configuration has none.",
+			config: "Image:\n  Os: alinux2\n",
+			p:      v1alpha1.ClusterParameters{HeadNodeSubnet: "subnet-11111111"},
+			want:   want{config: "Image:\n  Os: alinux2\nHeadNode:\n  Networking:\n    SubnetId: subnet-11111111\n"},
+		},
+		"BadSubnet": {
+			reason: "A subnet that isn't a subnet ID should be rejected.",
+			config: config,
+			p:      v1alpha1.ClusterParameters{ComputeSubnets: []string{"subnet-xyz"}},
+			want:   want{err: errors.Errorf("%s %q: must match %s", errBadSubnet, "subnet-xyz", subnetRegex)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := applySubnetOverrides(tc.config, tc.p)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\napplySubnetOverrides(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.config, got); diff != "" {
+				t.Errorf("\n%s\napplySubnetOverrides(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"sort"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	reasonUpdateCluster event.Reason = "UpdateCluster"
+	reasonUpdateTags    event.Reason = "UpdateClusterTags"
+
+	// suppressAllValidators suppresses every configuration validator.
+	suppressAllValidators = "ALL"
+)
+
+// outdatedTags returns the keys of the desired tags that the cluster lacks, or
+// has a different value for, sorted. Tags that aren't desired are ignored, as
+// pcluster tags the cluster too.
+func outdatedTags(desired []v1alpha1.Tag, observed []Tag) []string {
+	values := make(map[string]string, len(observed))
+	for _, t := range observed {
+		values[t.Key] = t.Value
+	}
+	var out []string
+	for _, t := range desired {
+		if v, ok := values[t.Key]; !ok || v != t.Value {
+			out = append(out, t.Key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isTagOnlyUpdate returns true if Observe found that only the cluster's tags
+// need updating. Forced updates are full updates.
+func isTagOnlyUpdate(cr *v1alpha1.Cluster) bool {
+	return len(cr.Status.AtProvider.OutdatedTags) > 0 && !isForceReconcileRequested(cr)
+}
+
+// tagUpdateArgs returns the options of an update that only changes the
+// cluster's tags. The configuration is unchanged, so it needn't be validated
+// again, which is most of the work of an update-cluster.
+func tagUpdateArgs(preview bool) []argOption {
+	return []argOption{
+		withConfiguration(),
+		withTags(),
+		withArgs("--suppress-validators", suppressAllValidators),
+		withDryRun(preview),
+		withExtraArgs(),
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	k8sexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestOutdatedTags(t *testing.T) {
+	observed := []Tag{
+		{Key: "parallelcluster:version", Value: "3.4.0"},
+		{Key: "team", Value: "hpc"},
+		{Key: "env", Value: "dev"},
+	}
+
+	cases := map[string]struct {
+		reason  string
+		desired []v1alpha1.Tag
+		want    []string
+	}{
+		"UpToDate": {
+			reason:  "Tags the cluster has should not be outdated, whatever other tags it has.",
+			desired: []v1alpha1.Tag{{Key: "team", Value: "hpc"}},
+		},
+		"Outdated": {
+			reason:  "Missing tags, and tags with different values, should be outdated.",
+			desired: []v1alpha1.Tag{{Key: "team", Value: "hpc"}, {Key: "owner", Value: "alice"}, {Key: "env", Value: "prod"}},
+			want:    []string{"env", "owner"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, outdatedTags(tc.desired, observed)); diff != "" {
+				t.Errorf("\n%s\noutdatedTags(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestTagOnlyUpdate(t *testing.T) {
+	upToDate := func(cmd string, args ...string) k8sexec.Cmd {
+		return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("upToDate.json", errors.New("exit status 1"))}}
+	}
+	notUpToDate := func(cmd string, args ...string) k8sexec.Cmd {
+		return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("notUpToDate.json", errors.New("exit status 1"))}}
+	}
+	updated := `{"cluster": {"clusterName": "test", "clusterStatus": "UPDATE_IN_PROGRESS"}}`
+
+	cases := map[string]struct {
+		reason     string
+		dryRun     fakeexec.FakeCommandAction
+		wantTags   []string
+		wantReason event.Reason
+		wantArgs   string
+	}{
+		"TagsOnly": {
+			reason:     "A cluster whose configuration is up to date should only have its tags updated.",
+			dryRun:     upToDate,
+			wantTags:   []string{"team"},
+			wantReason: reasonUpdateTags,
+			wantArgs:   "--suppress-validators ALL",
+		},
+		"Configuration": {
+			reason:     "A cluster whose configuration changed should be fully updated, with its tags.",
+			dryRun:     notUpToDate,
+			wantReason: reasonUpdateCluster,
+			wantArgs:   "--tags Key=team,Value=hpc",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var args []string
+			update := func(cmd string, a ...string) k8sexec.Cmd {
+				args = a
+				return fakeOutput(updated, nil)(cmd, a...)
+			}
+			fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+				describeWithStatus(CreateComplete), tc.dryRun, fakeOutput(`{"clusters": []}`, nil), fakeOutput("", errors.New("error")), fakeOutput("", errors.New("error")),
+				update,
+			}}
+			r := &recordingRecorder{}
+			e := external{executor: fe, logger: logging.NewNopLogger(), recorder: r}
+			cr := makeCluster()
+			cr.Spec.ForProvider.Tags = []v1alpha1.Tag{{Key: "team", Value: "hpc"}}
+
+			o, err := e.Observe(context.Background(), cr)
+			if err != nil {
+				t.Fatalf("\n%s\ne.Observe(...): %s", tc.reason, err)
+			}
+			if o.ResourceUpToDate {
+				t.Errorf("\n%s\ne.Observe(...): want not up to date, got up to date", tc.reason)
+			}
+			if diff := cmp.Diff(tc.wantTags, cr.Status.AtProvider.OutdatedTags); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want outdated tags, +got:\n%s\n", tc.reason, diff)
+			}
+			r.reasons = nil
+			if _, err := e.Update(context.Background(), cr); err != nil {
+				t.Fatalf("\n%s\ne.Update(...): %s", tc.reason, err)
+			}
+			if got := strings.Join(args, " "); !strings.Contains(got, tc.wantArgs) {
+				t.Errorf("\n%s\ne.Update(...): want args including %q, got %q", tc.reason, tc.wantArgs, got)
+			}
+			if diff := cmp.Diff([]event.Reason{tc.wantReason}, r.reasons); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want event reasons, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
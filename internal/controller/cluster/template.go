@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	errGetValuesSecret = "cannot get cluster configuration values Secret"
+	errDuplicateValue  = "cluster configuration value is set in both configValues and configValuesSecretRef"
+	errRenderConfig    = "cannot render cluster configuration template"
+)
+
+// renderConfiguration returns config rendered as a Go template with the
+// cluster's configuration values. The configuration is returned unchanged if
+// the cluster has no values, so that configurations that aren't templates
+// needn't escape {{.
+func (c *external) renderConfiguration(ctx context.Context, cr *v1alpha1.Cluster, config string) (string, error) {
+	p := cr.Spec.ForProvider
+	if len(p.ConfigValues) == 0 && p.ConfigValuesSecretRef == nil {
+		return config, nil
+	}
+	values := make(map[string]string, len(p.ConfigValues))
+	for k, v := range p.ConfigValues {
+		values[k] = v
+	}
+	if ref := p.ConfigValuesSecretRef; ref != nil {
+		s := &corev1.Secret{}
+		if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+			return "", errors.Wrap(err, errGetValuesSecret)
+		}
+		for k, v := range s.Data {
+			if _, ok := values[k]; ok {
+				return "", errors.Errorf("%s: %s", errDuplicateValue, k)
+			}
+			values[k] = string(v)
+		}
+	}
+	return renderTemplate(configFileName(cr), config, values)
+}
+
+// renderTemplate renders config as a Go template with the supplied values.
+// Referring to a value that isn't set is an error.
+func renderTemplate(name, config string, values map[string]string) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(config)
+	if err != nil {
+		return "", errors.Wrap(err, errRenderConfig)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, values); err != nil {
+		return "", errors.Wrap(err, errRenderConfig)
+	}
+	return b.String(), nil
+}
+
+// validateTemplate returns an error if the inline cluster configuration can't
+// be rendered with the cluster's configuration values, or isn't well-formed
+// YAML once it is.
+func validateTemplate(p v1alpha1.ClusterParameters) error {
+	config := p.ClusterConfiguration
+	if len(p.ConfigValues) > 0 {
+		var err error
+		if config, err = renderTemplate(clusterConfigFileName, config, p.ConfigValues); err != nil {
+			return err
+		}
+	}
+	return validateYAML(config)
+}
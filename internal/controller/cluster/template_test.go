@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestRenderConfiguration(t *testing.T) {
+	template := "HeadNode:\n  InstanceType: {{ .instanceType }}\n  Networking:\n    SubnetId: {{ .subnet }}\n"
+	secret := func(obj client.Object) error {
+		obj.(*corev1.Secret).Data = map[string][]byte{"subnet": []byte("subnet-0123456789abcdef0")}
+		return nil
+	}
+
+	cases := map[string]struct {
+		reason  string
+		kube    client.Client
+		config  string
+		values  map[string]string
+		ref     *xpv1.SecretReference
+		want    string
+		wantErr string
+	}{
+		"NoValues": {
+			reason: "A configuration without values should not be rendered.",
+			config: "Image:\n  Os: {{ .os }}\n",
+			want:   "Image:\n  Os: {{ .os }}\n",
+		},
+		"Values": {
+			reason: "The configuration should be rendered with the cluster's values.",
+			config: template,
+			values: map[string]string{"instanceType": "t3.medium", "subnet": "subnet-0123456789abcdef0"},
+			want:   "HeadNode:\n  InstanceType: t3.medium\n  Networking:\n    SubnetId: subnet-0123456789abcdef0\n",
+		},
+		"SecretValues": {
+			reason: "Values from the referenced Secret should be rendered too.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(nil, secret)},
+			config: template,
+			values: map[string]string{"instanceType": "t3.medium"},
+			ref:    &xpv1.SecretReference{Name: "values", Namespace: "ns"},
+			want:   "HeadNode:\n  InstanceType: t3.medium\n  Networking:\n    SubnetId: subnet-0123456789abcdef0\n",
+		},
+		"MissingValue": {
+			reason:  "Referring to a value that isn't set should return an error.",
+			config:  template,
+			values:  map[string]string{"instanceType": "t3.medium"},
+			wantErr: `map has no entry for key "subnet"`,
+		},
+		"DuplicateValue": {
+			reason:  "A value set in both the spec and the Secret should return an error.",
+			kube:    &test.MockClient{MockGet: test.NewMockGetFn(nil, secret)},
+			config:  template,
+			values:  map[string]string{"subnet": "subnet-01234567"},
+			ref:     &xpv1.SecretReference{Name: "values", Namespace: "ns"},
+			wantErr: errDuplicateValue,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{kube: tc.kube}
+			cr := makeCluster()
+			cr.Spec.ForProvider.ConfigValues = tc.values
+			cr.Spec.ForProvider.ConfigValuesSecretRef = tc.ref
+			got, err := e.renderConfiguration(context.Background(), cr, tc.config)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("\n%s\ne.renderConfiguration(...): want error containing %q, got %v", tc.reason, tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\n%s\ne.renderConfiguration(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.renderConfiguration(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	p := v1alpha1.ClusterParameters{
+		ClusterConfiguration: "Image:\n  Os: {{ .os }}\n",
+		ConfigValues:         map[string]string{"os": "alinux2"},
+	}
+	if err := validateTemplate(p); err != nil {
+		t.Errorf("validateTemplate(...): want a template that renders to be valid, got %s", err)
+	}
+	p.ConfigValues = map[string]string{"arch": "x86_64"}
+	if err := validateTemplate(p); err == nil {
+		t.Errorf("validateTemplate(...): want an error for a missing value, got nil")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const (
+	// annotationValidateOnly makes a Cluster only validate its configuration
+	// while it is "true". The cluster is never created, updated, or deleted,
+	// and the results are recorded in the Cluster's status.
+	annotationValidateOnly = "awspcluster.crossplane.io/validate-only"
+
+	msgInvalidConfiguration = "cluster configuration is invalid"
+)
+
+// isValidateOnly returns true if the Cluster should only validate its
+// configuration.
+func isValidateOnly(cr *v1alpha1.Cluster) bool {
+	return cr.GetAnnotations()[annotationValidateOnly] == "true"
+}
+
+// validateOnly validates the Cluster's configuration with a dry-run
+// create-cluster and records the results. The cluster is reported as existing
+// and up to date, so it is never created or updated, until the Cluster is
+// deleted.
+func (c *external) validateOnly(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster) (managed.ExternalObservation, error) {
+	if meta.WasDeleted(cr) {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+	if err := validateRegion(c.region(cr)); err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if err := validateArgs(cr.Spec.ForProvider); err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	// Unless the Cluster sets its own validation failure level, only errors
+	// fail validation, so a configuration with warnings is reported as valid.
+	level := withArgs("--validation-failure-level", ValidationError)
+	if cr.Spec.ForProvider.ValidationFailureLevel != "" {
+		level = withValidationFailureLevel()
+	}
+	args := c.pclusterArgs("create-cluster", cr,
+		withConfiguration(),
+		withSuppressValidators(),
+		level,
+		withDryRun(true),
+		withExtraArgs(),
+	)
+	log.Debug("validating cluster configuration")
+	output, err := c.execute(ctx, log, cr, args)
+	v, ok := validationResults(output)
+	if ok {
+		v.Valid = isDryRunSuccess(output, err, cr.Name)
+	}
+	// A command that failed without reporting a problem with the
+	// configuration failed for some other reason.
+	if !ok || (!v.Valid && len(v.Errors)+len(v.Warnings) == 0) {
+		return managed.ExternalObservation{}, fmt.Errorf("failed to validate using pcluster cli: %s: %w", errorMessage(output), err)
+	}
+	v.ObservedGeneration = cr.GetGeneration()
+	cr.Status.AtProvider.Validation = v
+
+	cr.SetConditions(xpv1.Available())
+	if !v.Valid {
+		failures := v.Errors
+		if len(failures) == 0 {
+			failures = v.Warnings
+		}
+		msgs := make([]string, len(failures))
+		for i, f := range failures {
+			msgs[i] = fmt.Sprintf("%s: %s", f.Validator, f.Message)
+		}
+		cr.SetConditions(xpv1.Unavailable().WithMessage(msgInvalidConfiguration + ": " + strings.Join(msgs, "; ")))
+	}
+	return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+}
+
+// validationResults returns the validation errors and warnings reported by a
+// dry-run create-cluster, and whether its output could be parsed.
+func validationResults(output []byte) (*v1alpha1.Validation, bool) {
+	var pErr errorOutput
+	if err := json.Unmarshal(output, &pErr); err != nil {
+		return nil, false
+	}
+	v := &v1alpha1.Validation{Time: metav1.Now()}
+	for _, m := range append(pErr.ConfigurationValidationErrors, pErr.ValidationMessages...) {
+		r := v1alpha1.ValidationResult{Validator: m.Type, Message: m.Message}
+		switch m.Level {
+		case ValidationError:
+			v.Errors = append(v.Errors, r)
+		case ValidationWarning:
+			v.Warnings = append(v.Warnings, r)
+		}
+	}
+	return v, true
+}
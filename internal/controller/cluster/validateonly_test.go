@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestValidateOnly(t *testing.T) {
+	errExit := errors.New("exit status 1")
+	dryRunWithWarning := `{
+  "message": "Request would have succeeded, but DryRun flag is set.",
+  "validationMessages": [{"level": "WARNING", "type": "KeyPairValidator", "message": "No key pair."}]
+}`
+
+	type want struct {
+		o          managed.ExternalObservation
+		err        error
+		validation *v1alpha1.Validation
+		ready      corev1.ConditionStatus
+	}
+
+	cases := map[string]struct {
+		reason  string
+		level   string
+		deleted bool
+		action  fakeexec.FakeAction
+		want    want
+	}{
+		"Valid": {
+			reason: "A configuration with only warnings should be reported as valid, with its warnings.",
+			action: func() ([]byte, []byte, error) { return []byte(dryRunWithWarning), nil, errExit },
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true},
+				validation: &v1alpha1.Validation{
+					Valid:              true,
+					Warnings:           []v1alpha1.ValidationResult{{Validator: "KeyPairValidator", Message: "No key pair."}},
+					ObservedGeneration: 2,
+				},
+				ready: corev1.ConditionTrue,
+			},
+		},
+		"Invalid": {
+			reason: "A configuration with errors should be reported as invalid, with its errors and warnings listed separately.",
+			action: readResourceFile("validationFailed.json", errExit),
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true},
+				validation: &v1alpha1.Validation{
+					Errors: []v1alpha1.ValidationResult{
+						{Validator: "InstanceTypeValidator", Message: "The instance type 't2.nano' is not supported."},
+						{Validator: "SubnetsValidator", Message: "The subnet 'subnet-0123' does not exist."},
+					},
+					Warnings:           []v1alpha1.ValidationResult{{Validator: "KeyPairValidator", Message: "If you do not specify a key pair, you can't connect to the instance."}},
+					ObservedGeneration: 2,
+				},
+				ready: corev1.ConditionFalse,
+			},
+		},
+		"Strict": {
+			reason: "A configuration with warnings should be reported as invalid if the Cluster's validation failure level is WARNING.",
+			level:  "WARNING",
+			action: func() ([]byte, []byte, error) {
+				return []byte(`{"message": "Invalid cluster configuration.", "configurationValidationErrors": [{"level": "WARNING", "type": "KeyPairValidator", "message": "No key pair."}]}`), nil, errExit
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true},
+				validation: &v1alpha1.Validation{
+					Warnings:           []v1alpha1.ValidationResult{{Validator: "KeyPairValidator", Message: "No key pair."}},
+					ObservedGeneration: 2,
+				},
+				ready: corev1.ConditionFalse,
+			},
+		},
+		"Failed": {
+			reason: "A failure to validate should be returned, without recording a result.",
+			action: func() ([]byte, []byte, error) {
+				return []byte(`{"message": "Unable to locate credentials."}`), nil, errExit
+			},
+			want: want{
+				err: fmt.Errorf("failed to validate using pcluster cli: %s: %w", "Unable to locate credentials.", errExit),
+			},
+		},
+		"Deleted": {
+			reason:  "A deleted Cluster should be reported as not existing, without validating it.",
+			deleted: true,
+			want:    want{o: managed.ExternalObservation{ResourceExists: false}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var gotArgs []string
+			executor := &fakeexec.FakeExec{}
+			if tc.action != nil {
+				executor.CommandScript = []fakeexec.FakeCommandAction{
+					func(cmd string, args ...string) k8sexec.Cmd {
+						gotArgs = args
+						return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{tc.action}}
+					},
+				}
+			}
+			e := &external{executor: executor, logger: logging.NewNopLogger(), recorder: &recordingRecorder{}}
+			cr := makeCluster()
+			cr.SetAnnotations(map[string]string{annotationValidateOnly: "true"})
+			cr.SetGeneration(2)
+			cr.Spec.ForProvider.ValidationFailureLevel = tc.level
+			if tc.deleted {
+				now := metav1.Now()
+				cr.SetDeletionTimestamp(&now)
+			}
+
+			got, err := e.Observe(context.Background(), cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.validation, cr.Status.AtProvider.Validation, cmpopts.IgnoreFields(v1alpha1.Validation{}, "Time")); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want validation, +got validation:\n%s\n", tc.reason, diff)
+			}
+			if tc.want.ready != "" {
+				if got := cr.GetCondition(xpv1.TypeReady).Status; got != tc.want.ready {
+					t.Errorf("\n%s\ne.Observe(...): want Ready %s, got %s", tc.reason, tc.want.ready, got)
+				}
+			}
+			level := tc.level
+			if level == "" {
+				level = ValidationError
+			}
+			if gotArgs != nil && !strings.Contains(strings.Join(gotArgs, " "), "--validation-failure-level "+level+" --dryrun true") {
+				t.Errorf("\n%s\ne.Observe(...): want a dry-run create-cluster failing at %s, got %v", tc.reason, level, gotArgs)
+			}
+		})
+	}
+}
+
+func TestValidateOnlyDelete(t *testing.T) {
+	executor := &fakeexec.FakeExec{}
+	e := &external{executor: executor, logger: logging.NewNopLogger(), recorder: &recordingRecorder{}}
+	cr := makeCluster()
+	cr.SetAnnotations(map[string]string{annotationValidateOnly: "true"})
+	if err := e.Delete(context.Background(), cr); err != nil {
+		t.Errorf("e.Delete(...): %s", err)
+	}
+	if executor.CommandCalls != 0 {
+		t.Errorf("e.Delete(...): want no pcluster commands, got %d", executor.CommandCalls)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	k8sexec "k8s.io/utils/exec"
+)
+
+const (
+	// defaultMinimumVersion is the oldest pcluster version the provider
+	// supports when the ProviderConfig does not specify one. Earlier versions
+	// use an incompatible CLI.
+	defaultMinimumVersion = "3.0.0"
+
+	errVersion         = "cannot get pcluster version"
+	errMinimumVersion  = "invalid minimum pcluster version"
+	errUnsupported     = "unsupported pcluster version"
+	errParseVersion    = "cannot parse pcluster version"
+	errUnmarshalOutput = "cannot unmarshal pcluster version output"
+)
+
+// pclusterVersion returns the version of the supplied pcluster binary. The
+// returned error wraps errFindBinary if the binary cannot be found.
+func pclusterVersion(ctx context.Context, executor k8sexec.Interface, binary string, env []string) (*version.Version, error) {
+	cmd := executor.CommandContext(ctx, binary, "version")
+	cmd.SetEnv(env)
+	cmd.SetStdin(bytes.NewReader(nil))
+	var stdout, stderr bytes.Buffer
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	err := cmd.Run()
+	if errors.Is(err, k8sexec.ErrExecutableNotFound) {
+		return nil, errors.Wrapf(err, "%s %q", errFindBinary, binary)
+	}
+	if err != nil {
+		return nil, errors.Wrap(fmt.Errorf("%s %w", commandOutput(stdout.Bytes(), stderr.Bytes(), err), err), errVersion)
+	}
+	var versionOutput VersionOutput
+	if err := json.Unmarshal(stdout.Bytes(), &versionOutput); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalOutput)
+	}
+	v, err := version.ParseGeneric(versionOutput.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseVersion)
+	}
+	return v, nil
+}
+
+// checkVersion returns an error if v is older than minimum. The default
+// minimum is used if minimum is empty.
+func checkVersion(v *version.Version, minimum string) error {
+	if minimum == "" {
+		minimum = defaultMinimumVersion
+	}
+	m, err := version.ParseGeneric(minimum)
+	if err != nil {
+		return errors.Wrap(err, errMinimumVersion)
+	}
+	if !v.AtLeast(m) {
+		return errors.Errorf("%s %s: must be at least %s", errUnsupported, v, m)
+	}
+	return nil
+}
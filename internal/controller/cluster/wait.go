@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const reasonWait event.Reason = "WaitForCompletion"
+
+// defaultWaitInterval is how often describe-cluster is polled while waiting
+// for an operation to finish.
+const defaultWaitInterval = 15 * time.Second
+
+// waitForCompletion polls describe-cluster until the operation the cluster is
+// undergoing finishes, emitting an event each time its status changes, and
+// returns the last status observed. The cluster's status is left as is, as
+// what Create writes to it is not persisted. It waits at most the command
+// timeout, if one is set, and never beyond the reconcile's deadline. Giving up
+// is not an error: the operation carries on, and later reconciles observe it as
+// usual.
+func (c *external) waitForCompletion(ctx context.Context, log logging.Logger, cr *v1alpha1.Cluster, status PClusterStatus) PClusterStatus {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	interval := c.waitInterval
+	if interval <= 0 {
+		interval = defaultWaitInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debug("stopped waiting for cluster", "status", status)
+			c.recorder.Event(cr, event.Normal(reasonWait, "Stopped waiting for the cluster, which is still "+status))
+			return status
+		case <-time.After(interval):
+		}
+
+		observed, err := c.newObservation(log, cr).describe(ctx)
+		if errors.Is(err, ErrClusterNotFound) {
+			// Only a deleted cluster disappears.
+			c.recorder.Event(cr, event.Normal(reasonWait, "Cluster is "+DeleteComplete))
+			return DeleteComplete
+		}
+		if err != nil {
+			log.Debug("cannot describe cluster while waiting", "error", err)
+			continue
+		}
+		if observed.ClusterStatus != status {
+			status = observed.ClusterStatus
+			if strings.HasSuffix(status, "_FAILED") {
+				c.recorder.Event(cr, event.Warning(reasonWait, errors.New("Cluster is "+status)))
+			} else {
+				c.recorder.Event(cr, event.Normal(reasonWait, "Cluster is "+status))
+			}
+		}
+		if !isInProgress(status) {
+			return status
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/google/go-cmp/cmp"
+	fakeexec "k8s.io/utils/exec/testing"
+)
+
+func TestCreateWaitForCompletion(t *testing.T) {
+	inProgress := make([]fakeexec.FakeCommandAction, 1000)
+	for i := range inProgress {
+		inProgress[i] = describeWithStatus(CreateInProgress)
+	}
+	create := []fakeexec.FakeCommandAction{describeNotFound, fakeOutput(readFile(t, "createOutput.json"), nil)}
+
+	type want struct {
+		reasons []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason  string
+		timeout time.Duration
+		script  []fakeexec.FakeCommandAction
+		want    want
+	}{
+		"Complete": {
+			reason: "Create should wait until the cluster is created, emitting an event when its status changes.",
+			script: append(create, describeWithStatus(CreateInProgress), describeWithStatus(CreateComplete)),
+			want: want{
+				reasons: []event.Reason{reasonWait},
+			},
+		},
+		"Failed": {
+			reason: "Create should stop waiting once the cluster fails to be created.",
+			script: append(create, describeWithStatus(CreateFailed)),
+			want: want{
+				reasons: []event.Reason{reasonWait},
+			},
+		},
+		"TimedOut": {
+			reason:  "Create should stop waiting, without failing, once the command timeout has passed.",
+			timeout: 50 * time.Millisecond,
+			script:  append(create, inProgress...),
+			want: want{
+				reasons: []event.Reason{reasonWait},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &recordingRecorder{}
+			fe := &fakeexec.FakeExec{CommandScript: tc.script}
+			e := external{executor: fe, logger: logging.NewNopLogger(), recorder: r, timeout: tc.timeout, wait: true, waitInterval: time.Millisecond}
+			cr := makeCluster()
+
+			if _, err := e.Create(context.Background(), cr); err != nil {
+				t.Fatalf("\n%s\ne.Create(...): %s", tc.reason, err)
+			}
+			// What Create writes to the status is not persisted, so the
+			// wait leaves it be.
+			if got := cr.Status.AtProvider.ClusterStatus; got != CreateInProgress {
+				t.Errorf("\n%s\ne.Create(...): want status %s, got %s", tc.reason, CreateInProgress, got)
+			}
+			if diff := cmp.Diff(tc.want.reasons, r.reasons); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+			if tc.timeout == 0 && fe.CommandCalls != len(tc.script) {
+				t.Errorf("\n%s\ne.Create(...): want %d pcluster commands, got %d", tc.reason, len(tc.script), fe.CommandCalls)
+			}
+		})
+	}
+}
+
+func TestDeleteWaitForCompletion(t *testing.T) {
+	r := &recordingRecorder{}
+	fe := &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+		fakeOutput(`{"cluster": {"clusterName": "test", "clusterStatus": "DELETE_IN_PROGRESS"}}`, nil),
+		describeWithStatus(DeleteInProgress),
+		describeNotFound,
+	}}
+	e := external{executor: fe, logger: logging.NewNopLogger(), recorder: r, wait: true, waitInterval: time.Millisecond}
+	cr := makeCluster()
+
+	if err := e.Delete(context.Background(), cr); err != nil {
+		t.Fatalf("e.Delete(...): %s", err)
+	}
+	if got := cr.Status.AtProvider.ClusterStatus; got != DeleteComplete {
+		t.Errorf("e.Delete(...): want status %s, got %s", DeleteComplete, got)
+	}
+	if fe.CommandCalls != 3 {
+		t.Errorf("e.Delete(...): want delete-cluster and two describe-cluster runs, got %d runs", fe.CommandCalls)
+	}
+}
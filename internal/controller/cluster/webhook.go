@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+const errNoConfig = "one of clusterConfiguration, clusterConfigurationRef, clusterConfigurationSecretRef, and clusterConfigurationUrl must be set"
+
+// SetupWebhook adds a webhook that validates Clusters to the supplied manager.
+func SetupWebhook(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.Cluster{}).
+		WithValidator(&validator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-awspcluster-crossplane-io-v1alpha1-cluster,mutating=false,failurePolicy=fail,groups=awspcluster.crossplane.io,resources=clusters,versions=v1alpha1,name=clusters.awspcluster.crossplane.io,sideEffects=None,admissionReviewVersions=v1
+
+// A validator rejects Clusters that pcluster would reject, so mistakes are
+// reported when a Cluster is applied rather than after a failed pcluster
+// command.
+type validator struct{}
+
+func (v *validator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	return validateCluster(obj)
+}
+
+// ValidateUpdate only validates changes to the spec. Clusters that are being
+// deleted, or whose spec is unchanged, are admitted even if invalid, so that
+// Clusters created before a check was added can still have their metadata,
+// e.g. their finalizers, updated.
+func (v *validator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldCR, ok := oldObj.(*v1alpha1.Cluster)
+	if !ok {
+		return errors.New(errNotCluster)
+	}
+	newCR, ok := newObj.(*v1alpha1.Cluster)
+	if !ok {
+		return errors.New(errNotCluster)
+	}
+	if meta.WasDeleted(newCR) || equality.Semantic.DeepEqual(oldCR.Spec, newCR.Spec) {
+		return nil
+	}
+	return validateCluster(newObj)
+}
+
+func (v *validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// validateCluster returns an Invalid error listing every field of the Cluster
+// that pcluster would reject.
+func validateCluster(obj runtime.Object) error {
+	cr, ok := obj.(*v1alpha1.Cluster)
+	if !ok {
+		return errors.New(errNotCluster)
+	}
+
+	var errs field.ErrorList
+	if err := validateClusterName(cr.GetName()); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), cr.GetName(), err.Error()))
+	}
+	p := cr.Spec.ForProvider
+	fp := field.NewPath("spec", "forProvider")
+	// The region may instead be defaulted by the ProviderConfig.
+	if err := validateRegion(p.Region); p.Region != "" && err != nil {
+		errs = append(errs, field.Invalid(fp.Child("region"), p.Region, err.Error()))
+	}
+	if err := validateConfigFileName(p.ClusterConfigurationFileName); err != nil {
+		errs = append(errs, field.Invalid(fp.Child("clusterConfigurationFileName"), p.ClusterConfigurationFileName, err.Error()))
+	}
+	if err := validateSubnet(p.HeadNodeSubnet); p.HeadNodeSubnet != "" && err != nil {
+		errs = append(errs, field.Invalid(fp.Child("headNodeSubnet"), p.HeadNodeSubnet, err.Error()))
+	}
+	for i, s := range p.ComputeSubnets {
+		if err := validateSubnet(s); err != nil {
+			errs = append(errs, field.Invalid(fp.Child("computeSubnets").Index(i), s, err.Error()))
+		}
+	}
+	if err := validateExtraArgs(p.ExtraArgs); err != nil {
+		errs = append(errs, field.Invalid(fp.Child("extraArgs"), p.ExtraArgs, err.Error()))
+	}
+	if err := validateConfigURL(p); err != nil {
+		errs = append(errs, field.Invalid(fp.Child("clusterConfigurationUrl"), p.ClusterConfigurationURL, err.Error()))
+	}
+	switch {
+	case p.ClusterConfiguration != "" && p.ConfigValuesSecretRef == nil:
+		// A configuration rendered with values from a Secret is validated
+		// once it is rendered.
+		if err := validateTemplate(p); err != nil {
+			errs = append(errs, field.Invalid(fp.Child("clusterConfiguration"), "", err.Error()))
+		}
+	case p.ClusterConfiguration != "":
+	case p.ClusterConfigurationRef == nil && p.ClusterConfigurationSecretRef == nil && p.ClusterConfigurationURL == "":
+		errs = append(errs, field.Required(fp.Child("clusterConfiguration"), errNoConfig))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	gk := schema.GroupKind{Group: v1alpha1.Group, Kind: v1alpha1.ClusterKind}
+	return kerrors.NewInvalid(gk, cr.GetName(), errs)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+)
+
+func TestValidateCluster(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		cr     func(cr *v1alpha1.Cluster)
+		want   []string
+	}{
+		"Valid": {
+			reason: "A valid Cluster should be admitted.",
+		},
+		"ConfigurationRef": {
+			reason: "A Cluster whose configuration is in a ConfigMap should be admitted.",
+			cr: func(cr *v1alpha1.Cluster) {
+				cr.Spec.ForProvider.ClusterConfiguration = ""
+				cr.Spec.ForProvider.ClusterConfigurationRef = &v1alpha1.ConfigMapKeySelector{Name: "test", Namespace: "default", Key: "config.yaml"}
+			},
+		},
+		"ConfigurationURL": {
+			reason: "A Cluster whose configuration pcluster downloads should be admitted.",
+			cr: func(cr *v1alpha1.Cluster) {
+				cr.Spec.ForProvider.ClusterConfiguration = ""
+				cr.Spec.ForProvider.ClusterConfigurationURL = "s3://bucket/cluster-config.yaml"
+			},
+		},
+		"BadConfigurationURL": {
+			reason: "A Cluster whose configuration URL isn't an https or s3 URL should be rejected.",
+			cr: func(cr *v1alpha1.Cluster) {
+				cr.Spec.ForProvider.ClusterConfiguration = ""
+				cr.Spec.ForProvider.ClusterConfigurationURL = "ftp://example.com/cluster-config.yaml"
+			},
+			want: []string{"spec.forProvider.clusterConfigurationUrl"},
+		},
+		"BadName": {
+			reason: "A Cluster whose name pcluster would reject should be rejected.",
+			cr:     func(cr *v1alpha1.Cluster) { cr.SetName("test_cluster") },
+			want:   []string{"metadata.name"},
+		},
+		"BadRegion": {
+			reason: "A Cluster with an invalid region should be rejected.",
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.Region = "us-east" },
+			want:   []string{"spec.forProvider.region"},
+		},
+		"DefaultRegion": {
+			reason: "A Cluster without a region should be admitted, as the ProviderConfig may default it.",
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.Region = "" },
+		},
+		"MalformedConfiguration": {
+			reason: "A Cluster whose configuration is not valid YAML should be rejected.",
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ClusterConfiguration = "Image:\n\tOs: alinux2\n" },
+			want:   []string{"spec.forProvider.clusterConfiguration"},
+		},
+		"NoConfiguration": {
+			reason: "A Cluster without a configuration should be rejected.",
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ClusterConfiguration = "" },
+			want:   []string{"spec.forProvider.clusterConfiguration"},
+		},
+		"BadFileName": {
+			reason: "A Cluster whose configuration file name is a path should be rejected.",
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ClusterConfigurationFileName = "../config.yaml" },
+			want:   []string{"spec.forProvider.clusterConfigurationFileName"},
+		},
+		"BadSubnets": {
+			reason: "A Cluster whose override subnets aren't subnet IDs should be rejected.",
+			cr: func(cr *v1alpha1.Cluster) {
+				cr.Spec.ForProvider.HeadNodeSubnet = "subnet-0123"
+				cr.Spec.ForProvider.ComputeSubnets = []string{"subnet-0123456789abcdef0", "sg-01234567"}
+			},
+			want: []string{"spec.forProvider.headNodeSubnet", "spec.forProvider.computeSubnets[1]"},
+		},
+		"ReservedExtraArgs": {
+			reason: "A Cluster whose extra arguments include an option the provider sets should be rejected.",
+			cr:     func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ExtraArgs = []string{"--cluster-name=other"} },
+			want:   []string{"spec.forProvider.extraArgs"},
+		},
+		"Everything": {
+			reason: "Every invalid field should be reported.",
+			cr: func(cr *v1alpha1.Cluster) {
+				cr.SetName("1test")
+				cr.Spec.ForProvider.Region = "us"
+				cr.Spec.ForProvider.ClusterConfiguration = ""
+			},
+			want: []string{"metadata.name", "spec.forProvider.region", "spec.forProvider.clusterConfiguration"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := makeCluster()
+			if tc.cr != nil {
+				tc.cr(cr)
+			}
+			err := validateCluster(cr)
+			var got []string
+			if err != nil {
+				if !kerrors.IsInvalid(err) {
+					t.Fatalf("\n%s\nvalidateCluster(...): want Invalid error, got %v", tc.reason, err)
+				}
+				for _, cause := range err.(*kerrors.StatusError).ErrStatus.Details.Causes {
+					got = append(got, cause.Field)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nvalidateCluster(...): -want invalid fields, +got invalid fields:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	// The Cluster's region is invalid, as it may be for Clusters created
+	// before regions were validated.
+	invalid := func() *v1alpha1.Cluster {
+		cr := makeCluster()
+		cr.Spec.ForProvider.Region = "us"
+		cr.SetFinalizers([]string{"finalizer.managedresource.crossplane.io"})
+		return cr
+	}
+
+	cases := map[string]struct {
+		reason  string
+		update  func(cr *v1alpha1.Cluster)
+		invalid bool
+	}{
+		"FinalizerRemoved": {
+			reason: "An invalid Cluster whose spec is unchanged should still have its finalizers removed.",
+			update: func(cr *v1alpha1.Cluster) { cr.SetFinalizers(nil) },
+		},
+		"Deleted": {
+			reason: "An invalid Cluster that is being deleted should still be updated.",
+			update: func(cr *v1alpha1.Cluster) {
+				now := metav1.Now()
+				cr.SetDeletionTimestamp(&now)
+				cr.Spec.ForProvider.ClusterConfiguration = "Image:\n  Os: ubuntu2204\n"
+			},
+		},
+		"SpecChanged": {
+			reason:  "A change to an invalid Cluster's spec should be validated.",
+			update:  func(cr *v1alpha1.Cluster) { cr.Spec.ForProvider.ClusterConfiguration = "Image:\n  Os: ubuntu2204\n" },
+			invalid: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			old, cr := invalid(), invalid()
+			tc.update(cr)
+			err := (&validator{}).ValidateUpdate(context.Background(), old, cr)
+			if got := kerrors.IsInvalid(err); got != tc.invalid {
+				t.Errorf("\n%s\nv.ValidateUpdate(...): want invalid %t, got error %v", tc.reason, tc.invalid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	k8sexec "k8s.io/utils/exec"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/clients/pcluster"
+	"github.com/crossplane-contrib/provider-awspcluster/internal/controller/features"
+)
+
+const (
+	imageConfigFileName = "image-config.yaml"
+
+	reasonBuildFailed  event.Reason = "BuildImageFailed"
+	reasonDeleteFailed event.Reason = "DeleteImageFailed"
+
+	errNotImage     = "managed resource is not an Image custom resource"
+	errTrackPCUsage = "cannot track ProviderConfig usage"
+	errGetPC        = "cannot get ProviderConfig"
+	errNewClient    = "cannot create new Service"
+	errWorkingDir   = "cannot use working directory"
+
+	BuildInProgress  ImageBuildStatus = "BUILD_IN_PROGRESS"
+	BuildFailed      ImageBuildStatus = "BUILD_FAILED"
+	BuildComplete    ImageBuildStatus = "BUILD_COMPLETE"
+	DeleteInProgress ImageBuildStatus = "DELETE_IN_PROGRESS"
+	DeleteFailed     ImageBuildStatus = "DELETE_FAILED"
+	DeleteComplete   ImageBuildStatus = "DELETE_COMPLETE"
+
+	errPClusterCliNotFound = "No image or stack associated with ParallelCluster image id"
+)
+
+type ImageBuildStatus = string
+
+// Setup adds a controller that reconciles Image managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := managed.ControllerName(v1alpha1.ImageGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.ImageGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:          mgr.GetClient(),
+			usage:         resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			newExecutorFn: newExecutor,
+			logger:        o.Logger,
+			recorder:      recorder,
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithRecorder(recorder),
+		managed.WithConnectionPublishers(cps...),
+		managed.WithPollInterval(o.PollInterval),
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.Image{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube          client.Client
+	usage         resource.Tracker
+	newExecutorFn func(creds []byte) (k8sexec.Interface, error)
+	logger        logging.Logger
+	recorder      event.Recorder
+}
+
+func newExecutor(creds []byte) (k8sexec.Interface, error) {
+	return k8sexec.New(), nil
+}
+
+// Connect produces an ExternalClient that runs pcluster the same way the
+// Cluster controller does.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	cr, ok := mg.(*v1alpha1.Image)
+	if !ok {
+		return nil, errors.New(errNotImage)
+	}
+
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	pc := &apisv1alpha1.ProviderConfig{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Name: cr.GetProviderConfigReference().Name}, pc); err != nil {
+		return nil, errors.Wrap(err, errGetPC)
+	}
+
+	setup, err := pcluster.Configure(ctx, c.kube, pc.Spec)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := c.newExecutorFn(setup.Credentials)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewClient)
+	}
+	if err := checkWorkingDir(pc.Spec.WorkingDir); err != nil {
+		return nil, err
+	}
+
+	e := &external{env: setup.Env, binary: setup.Binary, awsFiles: setup.Files, workingDir: pc.Spec.WorkingDir, cliDebug: pc.Spec.CLILogLevel == apisv1alpha1.CLILogLevelDebug, executor: svc, logger: c.logger, recorder: c.recorder}
+	if pc.Spec.CommandTimeout != nil {
+		e.timeout = pc.Spec.CommandTimeout.Duration
+	}
+	return e, nil
+}
+
+// An ExternalClient observes, then either builds or deletes an image to
+// ensure it reflects the managed resource's desired state.
+type external struct {
+	env        []string
+	binary     string
+	awsFiles   pcluster.AWSFiles
+	workingDir string
+	timeout    time.Duration
+	executor   k8sexec.Interface
+	logger     logging.Logger
+	recorder   event.Recorder
+
+	// cliDebug runs pcluster with --debug, logging its verbose output.
+	cliDebug bool
+}
+
+// execPcluster runs pcluster in dir. The command's AWS files are written to
+// dir. The external client may be shared by concurrent reconciles, so nothing
+// here may modify it or the process.
+func (c *external) execPcluster(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	env := c.env
+	if !c.awsFiles.Empty() {
+		// Commands that aren't run in a directory of their own get one for
+		// their AWS files.
+		if dir == "" {
+			d, err := createTempDir(c.workingDir, "pcluster")
+			if err != nil {
+				return nil, err
+			}
+			defer os.RemoveAll(d)
+			dir = d
+		}
+		var err error
+		if env, err = c.awsFiles.Write(dir, env); err != nil {
+			return nil, err
+		}
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if c.cliDebug {
+		args = append(args, "--debug")
+	}
+	cmd := c.executor.CommandContext(ctx, c.binary, args...)
+	cmd.SetEnv(env)
+	cmd.SetDir(dir)
+	// pcluster must never wait for input, as nothing would answer. Prompts
+	// read EOF instead.
+	cmd.SetStdin(bytes.NewReader(nil))
+	c.logger.Debug(fmt.Sprintf("executing: %s %s", c.binary, strings.Join(args, " ")))
+	var stdout, stderr bytes.Buffer
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	err := cmd.Run() // blocks
+	if stderr.Len() > 0 {
+		// pcluster logs to stderr, including its debug output.
+		c.logger.Debug("pcluster command stderr", "command", args[0], "output", stderr.String())
+	}
+	output := stdout.Bytes()
+	if err != nil && len(bytes.TrimSpace(output)) == 0 {
+		// The command failed without reporting an error, e.g. with a
+		// traceback, which stderr explains.
+		output = stderr.Bytes()
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("pcluster %s timed out: %w", args[0], ctx.Err())
+	}
+	return output, err
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.Image)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotImage)
+	}
+	args := []string{
+		"describe-image",
+		"--image-id",
+		cr.Name,
+		"--region",
+		cr.Spec.ForProvider.Region,
+	}
+	output, err := c.execPcluster(ctx, "", args...)
+	if err != nil {
+		if isNotFound(output) {
+			return managed.ExternalObservation{ResourceExists: false}, nil
+		}
+		return managed.ExternalObservation{}, fmt.Errorf("failed to run pcluster command: %s %w", output, err)
+	}
+	var describeOutput DescribeImageOutput
+	if err := json.Unmarshal(output, &describeOutput); err != nil {
+		return managed.ExternalObservation{}, fmt.Errorf("failed to unmarshal describe response: %w", err)
+	}
+	setStatus(describeOutput.OutputImage, cr)
+	cr.Status.AtProvider.AmiID = describeOutput.Ec2AmiInfo.AmiID
+
+	// Images cannot be changed once built, so they are always up to date.
+	eo := managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}
+	switch describeOutput.ImageBuildStatus {
+	case BuildInProgress:
+		cr.SetConditions(xpv1.Creating())
+	case BuildComplete:
+		cr.SetConditions(xpv1.Available())
+	case DeleteInProgress:
+		cr.SetConditions(xpv1.Deleting())
+	case BuildFailed, DeleteFailed:
+		// A failed build is kept, rather than rebuilt, so its logs can be
+		// inspected. Delete the Image to retry.
+		cr.SetConditions(xpv1.Unavailable())
+	case DeleteComplete:
+		eo.ResourceExists = false
+	}
+	return eo, nil
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.Image)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotImage)
+	}
+
+	dir, err := createTempDir(c.workingDir, cr.Name)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	defer os.RemoveAll(dir)
+	if err := writeConfigToFile(cr.Spec.ForProvider.ImageConfiguration, filepath.Join(dir, imageConfigFileName)); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	args := []string{
+		"build-image",
+		"--image-configuration",
+		imageConfigFileName,
+		"--image-id",
+		cr.Name,
+		"--region",
+		cr.Spec.ForProvider.Region,
+	}
+	output, err := c.execPcluster(ctx, dir, args...)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonBuildFailed, errors.New(errorMessage(output))))
+		return managed.ExternalCreation{}, fmt.Errorf("failed to build image using pcluster cli: %s %w", output, err)
+	}
+	var buildOutput BuildImageOutput
+	if err := json.Unmarshal(output, &buildOutput); err != nil {
+		return managed.ExternalCreation{}, fmt.Errorf("failed to unmarshal build output: %w", err)
+	}
+	setStatus(buildOutput.Image, cr)
+	cr.SetConditions(xpv1.Creating())
+	return managed.ExternalCreation{}, nil
+}
+
+// Update does nothing, as images cannot be changed once built.
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	return managed.ExternalUpdate{}, nil
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(*v1alpha1.Image)
+	if !ok {
+		return errors.New(errNotImage)
+	}
+
+	args := []string{
+		"delete-image",
+		"--image-id",
+		cr.Name,
+		"--region",
+		cr.Spec.ForProvider.Region,
+	}
+	output, err := c.execPcluster(ctx, "", args...)
+	if err != nil {
+		if isNotFound(output) {
+			return nil
+		}
+		c.recorder.Event(cr, event.Warning(reasonDeleteFailed, errors.New(errorMessage(output))))
+		return fmt.Errorf("failed to delete image using pcluster cli: %s %w", output, err)
+	}
+	var deleteOutput DeleteImageOutput
+	if err := json.Unmarshal(output, &deleteOutput); err != nil {
+		return fmt.Errorf("failed to unmarshal delete output: %w", err)
+	}
+	setStatus(deleteOutput.Image, cr)
+	return nil
+}
+
+// isNotFound returns true if the output of a failed command reports that the
+// image does not exist.
+func isNotFound(cmdOutput []byte) bool {
+	var pErr errorOutput
+	if err := json.Unmarshal(cmdOutput, &pErr); err != nil {
+		return false
+	}
+	return strings.HasPrefix(pErr.Message, errPClusterCliNotFound)
+}
+
+// errorMessage returns the message of a pcluster error, or the trimmed output
+// if it is not a JSON error.
+func errorMessage(cmdOutput []byte) string {
+	var pErr errorOutput
+	if err := json.Unmarshal(cmdOutput, &pErr); err == nil && pErr.Message != "" {
+		return pErr.Message
+	}
+	return strings.TrimSpace(string(cmdOutput))
+}
+
+// createTempDir creates a temporary directory in base, or in the system's
+// temporary directory if base is empty.
+func createTempDir(base, prefix string) (string, error) {
+	dir, err := os.MkdirTemp(base, prefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tmp dir: %w", err)
+	}
+	return dir, nil
+}
+
+// checkWorkingDir returns an error if temporary directories can't be created
+// in dir. An empty dir is the system's temporary directory, which is assumed
+// to be usable.
+func checkWorkingDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	d, err := os.MkdirTemp(dir, "check")
+	if err != nil {
+		return errors.Wrapf(err, "%s %q", errWorkingDir, dir)
+	}
+	return os.Remove(d)
+}
+
+func writeConfigToFile(input string, filePath string) error {
+	if err := os.WriteFile(filePath, []byte(input), 0o600); err != nil {
+		return fmt.Errorf("failed to write to config file: %w", err)
+	}
+	return nil
+}
+
+func setStatus(output OutputImage, image *v1alpha1.Image) {
+	image.Status.AtProvider.ImageID = output.ImageID
+	image.Status.AtProvider.ImageBuildStatus = output.ImageBuildStatus
+	image.Status.AtProvider.CloudformationStackArn = output.CloudformationStackArn
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crossplane-contrib/provider-awspcluster/apis/pcluster/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-awspcluster/apis/v1alpha1"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sexec "k8s.io/utils/exec"
+	fakeexec "k8s.io/utils/exec/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Unlike many Kubernetes projects Crossplane does not use third party testing
+// libraries, per the common Go test review comments. Crossplane encourages the
+// use of table driven unit tests. The tests of the crossplane-runtime project
+// are representative of the testing style Crossplane encourages.
+//
+// https://github.com/golang/go/wiki/TestComments
+// https://github.com/crossplane/crossplane/blob/master/CONTRIBUTING.md#contributing-code
+
+func makeImage() *v1alpha1.Image {
+	return &v1alpha1.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: v1alpha1.ImageSpec{
+			ForProvider: v1alpha1.ImageParameters{
+				Region:             "us-east-1",
+				ImageConfiguration: "Build:\n  InstanceType: c5.xlarge\n  ParentImage: ami-0123456789abcdef0\n",
+			},
+		},
+	}
+}
+
+func readResourceFile(path string, errToReturn error) func() ([]byte, []byte, error) {
+	b, err := os.ReadFile(filepath.Join("resources", path))
+	if err != nil {
+		panic(fmt.Sprintf("couldn't read file: %s", err))
+	}
+
+	return func() ([]byte, []byte, error) {
+		return b, nil, errToReturn
+	}
+}
+
+// runs returns a FakeExec that runs each of the supplied commands once.
+func runs(actions ...fakeexec.FakeAction) *fakeexec.FakeExec {
+	e := &fakeexec.FakeExec{}
+	for _, a := range actions {
+		a := a
+		e.CommandScript = append(e.CommandScript, func(cmd string, args ...string) k8sexec.Cmd {
+			return &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{a}}
+		})
+	}
+	return e
+}
+
+func TestObserve(t *testing.T) {
+	type want struct {
+		o      managed.ExternalObservation
+		status v1alpha1.ImageStatus
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		executor *fakeexec.FakeExec
+		want     want
+	}{
+		"BuildComplete": {
+			reason:   "A built image should be available and report its AMI.",
+			executor: runs(readResourceFile("describeOutput.json", nil)),
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true},
+				status: v1alpha1.ImageStatus{
+					ResourceStatus: xpv1.ResourceStatus{ConditionedStatus: *xpv1.NewConditionedStatus(xpv1.Available())},
+					AtProvider: v1alpha1.ImageObservation{
+						ImageID:                "test",
+						ImageBuildStatus:       BuildComplete,
+						CloudformationStackArn: "arn:aws:cloudformation:us-east-1:123456789012:stack/test/0a1b2c3d-4e5f-6789-0abc-def012345678",
+						AmiID:                  "ami-0a1b2c3d4e5f67890",
+					},
+				},
+			},
+		},
+		"DoesNotExist": {
+			reason:   "An image pcluster does not know of should not exist.",
+			executor: runs(readResourceFile("notFound.json", fmt.Errorf("exit status 1"))),
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := makeImage()
+			e := external{executor: tc.executor, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			got, err := e.Observe(context.Background(), cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, cr.Status, cmpopts.IgnoreFields(xpv1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want status, +got status:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	type want struct {
+		args []string
+		err  error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		executor *fakeexec.FakeExec
+		want     want
+	}{
+		"Built": {
+			reason:   "A successful build-image should return no error.",
+			executor: runs(readResourceFile("buildOutput.json", nil)),
+			want: want{
+				args: []string{"build-image", "--image-configuration", imageConfigFileName, "--image-id", "test", "--region", "us-east-1"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var args []string
+			script := tc.executor.CommandScript[0]
+			tc.executor.CommandScript[0] = func(cmd string, a ...string) k8sexec.Cmd {
+				args = a
+				return script(cmd, a...)
+			}
+			e := external{executor: tc.executor, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			_, err := e.Create(context.Background(), makeImage())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.args, args); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want args, +got args:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestExecPclusterStdin(t *testing.T) {
+	fc := &fakeexec.FakeCmd{RunScript: []fakeexec.FakeAction{readResourceFile("buildOutput.json", nil)}}
+	e := external{executor: &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+		func(cmd string, args ...string) k8sexec.Cmd { return fc },
+	}}, logger: logging.NewNopLogger()}
+	if _, err := e.execPcluster(context.Background(), "", "build-image"); err != nil {
+		t.Fatalf("e.execPcluster(...): %s", err)
+	}
+	if fc.Stdin == nil {
+		t.Fatal("e.execPcluster(...): want stdin set, got nil")
+	}
+	if b, err := io.ReadAll(fc.Stdin); err != nil || len(b) > 0 {
+		t.Errorf("e.execPcluster(...): want empty stdin, got %q, %v", b, err)
+	}
+}
+
+func TestConnectCredentials(t *testing.T) {
+	kube := &test.MockClient{MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+		switch o := obj.(type) {
+		case *apisv1alpha1.ProviderConfig:
+			o.Spec.Credentials = apisv1alpha1.ProviderCredentials{
+				Source: xpv1.CredentialsSourceSecret,
+				CommonCredentialSelectors: xpv1.CommonCredentialSelectors{SecretRef: &xpv1.SecretKeySelector{
+					SecretReference: xpv1.SecretReference{Name: "creds", Namespace: "crossplane-system"},
+					Key:             "credentials",
+				}},
+			}
+		case *corev1.Secret:
+			o.Data = map[string][]byte{"credentials": []byte("[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n")}
+		}
+		return nil
+	})}
+
+	var creds string
+	fc := &fakeexec.FakeCmd{}
+	fc.RunScript = []fakeexec.FakeAction{
+		func() ([]byte, []byte, error) {
+			for _, e := range fc.Env {
+				if strings.HasPrefix(e, "AWS_ACCESS_KEY_ID=") {
+					return nil, nil, errors.New("want no credentials in env")
+				}
+				if f := strings.TrimPrefix(e, "AWS_SHARED_CREDENTIALS_FILE="); f != e {
+					b, err := os.ReadFile(f)
+					creds = string(b)
+					return []byte(`{"image": {}}`), nil, err
+				}
+			}
+			return nil, nil, nil
+		},
+	}
+	c := connector{
+		kube:  kube,
+		usage: resource.TrackerFn(func(_ context.Context, _ resource.Managed) error { return nil }),
+		newExecutorFn: func(_ []byte) (k8sexec.Interface, error) {
+			return &fakeexec.FakeExec{CommandScript: []fakeexec.FakeCommandAction{
+				func(cmd string, args ...string) k8sexec.Cmd { return fc },
+			}}, nil
+		},
+		logger:   logging.NewNopLogger(),
+		recorder: event.NewNopRecorder(),
+	}
+	cr := makeImage()
+	cr.SetProviderConfigReference(&xpv1.Reference{Name: "default"})
+	t.Setenv("TMPDIR", t.TempDir())
+	ec, err := c.Connect(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("c.Connect(...): %s", err)
+	}
+	if err := ec.Delete(context.Background(), cr); err != nil {
+		t.Fatalf("e.Delete(...): %s", err)
+	}
+	if !strings.Contains(creds, "aws_access_key_id = AKIAEXAMPLE") {
+		t.Errorf("e.Delete(...): want delete-image run with the ProviderConfig's credentials, got credentials file %q", creds)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		executor *fakeexec.FakeExec
+		want     want
+	}{
+		"Deleted": {
+			reason:   "A successful delete-image should return no error.",
+			executor: runs(readResourceFile("deleteOutput.json", nil)),
+		},
+		"AlreadyDeleted": {
+			reason:   "Deleting an image that no longer exists should return no error.",
+			executor: runs(readResourceFile("notFound.json", fmt.Errorf("exit status 1"))),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := external{executor: tc.executor, logger: logging.NewNopLogger(), recorder: event.NewNopRecorder()}
+			err := e.Delete(context.Background(), makeImage())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
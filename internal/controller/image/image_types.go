@@ -0,0 +1,37 @@
+package image
+
+// OutputImage holds the fields common to the output of every pcluster image
+// command.
+type OutputImage struct {
+	ImageID                   string `json:"imageId"`
+	ImageBuildStatus          string `json:"imageBuildStatus"`
+	CloudformationStackStatus string `json:"cloudformationStackStatus"`
+	CloudformationStackArn    string `json:"cloudformationStackArn"`
+	Region                    string `json:"region"`
+	Version                   string `json:"version"`
+}
+
+// Ec2AmiInfo is only present once the AMI has been built.
+type Ec2AmiInfo struct {
+	AmiName      string `json:"amiName"`
+	AmiID        string `json:"amiId"`
+	State        string `json:"state"`
+	Architecture string `json:"architecture"`
+}
+
+type DescribeImageOutput struct {
+	OutputImage `json:",inline"`
+	Ec2AmiInfo  Ec2AmiInfo `json:"ec2AmiInfo,omitempty"`
+}
+
+type BuildImageOutput struct {
+	Image OutputImage `json:"image"`
+}
+
+type DeleteImageOutput struct {
+	Image OutputImage `json:"image"`
+}
+
+type errorOutput struct {
+	Message string `json:"message"`
+}